@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"dify-vnext-go/pkg/document"
+	"dify-vnext-go/pkg/egress"
+	"dify-vnext-go/pkg/knowledge"
+	"dify-vnext-go/pkg/secrets"
+)
+
+const kbUsage = `Usage: main kb <command> [flags] [args]
+
+Build and query the knowledge bases used by KnowledgeRetrieval nodes.
+Knowledge bases live in -dir (default $VNEXT_KB_DIR, or ./knowledge).
+
+Commands:
+  create NAME            Create a knowledge base (-embedder none|hash|openai, -model,
+                         -dimensions, -chunk-size, -chunk-overlap)
+  list                   Print the knowledge bases
+  ingest NAME PATH...    Extract, chunk and index files; directories are walked
+                         recursively and unchanged files are skipped
+  docs NAME              Print the documents of a knowledge base
+  delete NAME DOC...     Remove documents by ID, name or source
+  drop NAME              Delete a knowledge base
+  reembed NAME           Recompute all embeddings, optionally with a new -embedder
+  query NAME QUERY       Print ranked chunks (-mode, -top-k, -threshold, -vector-weight)
+
+The openai embedder reads OPENAI_API_KEY from -secrets-file or the environment.
+`
+
+// runKB implements the "kb" subcommand and returns the exit code
+func runKB(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, kbUsage)
+		return 2
+	}
+	cmd := args[0]
+	flags := flag.NewFlagSet("kb "+cmd, flag.ContinueOnError)
+	dir := flags.String("dir", "", "Directory holding knowledge bases (default $VNEXT_KB_DIR or ./knowledge)")
+	secretsFile := flags.String("secrets-file", "", "Dotenv or encrypted secrets file consulted before the environment")
+	allowPrivate := flags.Bool("allow-private", false, "Allow embedding requests to private and loopback addresses")
+	embedder := flags.String("embedder", "", "Embedder: none, hash or openai (create, reembed)")
+	model := flags.String("model", "", "Embedding model (create, reembed)")
+	dimensions := flags.Int("dimensions", 0, "Embedding dimensions (create, reembed)")
+	chunkSize := flags.Int("chunk-size", 1000, "Chunk size in characters (create)")
+	chunkOverlap := flags.Int("chunk-overlap", 100, "Characters shared by neighbouring chunks (create)")
+	mode := flags.String("mode", "", "Search mode: bm25, vector or hybrid (query)")
+	topK := flags.Int("top-k", 4, "Chunks to print (query)")
+	threshold := flags.Float64("threshold", 0, "Minimum score (query)")
+	vectorWeight := flags.Float64("vector-weight", 0, "Weight of the vector score in hybrid mode (query)")
+	full := flags.Bool("full", false, "Print whole chunks instead of a preview (query)")
+	rest, err := parseInterspersed(flags, args[1:])
+	if err != nil {
+		return 2
+	}
+	root := knowledge.Root(*dir)
+
+	var store secrets.Store = secrets.EnvStore{}
+	if *secretsFile != "" {
+		fileStore, err := secrets.LoadFile(*secretsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load secrets: %v\n", err)
+			return 1
+		}
+		store = secrets.Chain{fileStore, secrets.EnvStore{}}
+	}
+	policy := egress.Default
+	if *allowPrivate {
+		policy = &egress.Policy{AllowPrivate: true}
+	}
+	ctx := secrets.WithVault(egress.WithClient(context.Background(), policy.Client(nil)), secrets.NewVault(store))
+
+	usage := func() int {
+		fmt.Fprint(os.Stderr, kbUsage)
+		return 2
+	}
+	fail := func(err error) int {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	switch cmd {
+	case "create":
+		if len(rest) != 1 {
+			return usage()
+		}
+		kb, err := knowledge.Create(root, knowledge.Config{
+			Name:         rest[0],
+			Embedder:     *embedder,
+			Model:        *model,
+			Dimensions:   *dimensions,
+			ChunkSize:    *chunkSize,
+			ChunkOverlap: *chunkOverlap,
+		})
+		if err != nil {
+			return fail(err)
+		}
+		cfg := kb.Config()
+		fmt.Printf("Created knowledge base %s in %s (embedder %s, chunks of %d with %d overlap)\n", cfg.Name, root, cfg.Embedder, cfg.ChunkSize, cfg.ChunkOverlap)
+
+	case "list":
+		if len(rest) != 0 {
+			return usage()
+		}
+		names, err := knowledge.List(root)
+		if err != nil {
+			return fail(err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tEMBEDDER\tDOCUMENTS\tCHUNKS")
+		for _, name := range names {
+			kb, err := knowledge.Open(root, name)
+			if err != nil {
+				fmt.Fprintf(w, "%s\t(%v)\t\t\n", name, err)
+				continue
+			}
+			cfg := kb.Config()
+			embedderName := cfg.Embedder
+			if cfg.Model != "" {
+				embedderName += "/" + cfg.Model
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", name, embedderName, len(kb.Documents()), kb.ChunkCount())
+		}
+		w.Flush()
+
+	case "ingest":
+		if len(rest) < 2 {
+			return usage()
+		}
+		kb, err := knowledge.Open(root, rest[0])
+		if err != nil {
+			return fail(err)
+		}
+		var added, unchanged, failed int
+		for _, path := range rest[1:] {
+			err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() {
+					if p != path && strings.HasPrefix(d.Name(), ".") {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if p != path && (strings.HasPrefix(d.Name(), ".") || !ingestible(p)) {
+					return nil
+				}
+				doc, changed, err := kb.AddFile(ctx, p)
+				switch {
+				case err != nil:
+					failed++
+					fmt.Fprintf(os.Stderr, "Skipped %v\n", err)
+				case changed:
+					added++
+					fmt.Printf("Indexed %s (%s, %d chunks)\n", p, doc.Format, doc.Chunks)
+				default:
+					unchanged++
+				}
+				return nil
+			})
+			if err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "Skipped %v\n", err)
+			}
+		}
+		if added > 0 {
+			if err := kb.Save(); err != nil {
+				return fail(err)
+			}
+		}
+		fmt.Printf("%d indexed, %d unchanged, %d failed; %d documents, %d chunks\n", added, unchanged, failed, len(kb.Documents()), kb.ChunkCount())
+		if failed > 0 {
+			return 1
+		}
+
+	case "docs":
+		if len(rest) != 1 {
+			return usage()
+		}
+		kb, err := knowledge.Open(root, rest[0])
+		if err != nil {
+			return fail(err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tFORMAT\tCHUNKS\tADDED\tSOURCE")
+		for _, d := range kb.Documents() {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", d.ID, d.Name, d.Format, d.Chunks, d.AddedAt.Local().Format("2006-01-02 15:04"), d.Source)
+		}
+		w.Flush()
+
+	case "delete":
+		if len(rest) < 2 {
+			return usage()
+		}
+		kb, err := knowledge.Open(root, rest[0])
+		if err != nil {
+			return fail(err)
+		}
+		for _, key := range rest[1:] {
+			doc, err := kb.DeleteDocument(key)
+			if err != nil {
+				return fail(err)
+			}
+			fmt.Printf("Deleted %s (%s)\n", doc.Name, doc.ID)
+		}
+		if err := kb.Save(); err != nil {
+			return fail(err)
+		}
+
+	case "drop":
+		if len(rest) != 1 {
+			return usage()
+		}
+		if err := knowledge.Remove(root, rest[0]); err != nil {
+			return fail(err)
+		}
+		fmt.Printf("Deleted knowledge base %s\n", rest[0])
+
+	case "reembed":
+		if len(rest) != 1 {
+			return usage()
+		}
+		kb, err := knowledge.Open(root, rest[0])
+		if err != nil {
+			return fail(err)
+		}
+		if *embedder != "" {
+			if err := kb.SetEmbedder(*embedder, *model, *dimensions); err != nil {
+				return fail(err)
+			}
+		}
+		if err := kb.Reembed(ctx); err != nil {
+			return fail(err)
+		}
+		if err := kb.Save(); err != nil {
+			return fail(err)
+		}
+		fmt.Printf("Re-embedded %d chunks with %s\n", kb.ChunkCount(), kb.Config().Embedder)
+
+	case "query":
+		if len(rest) < 2 {
+			return usage()
+		}
+		kb, err := knowledge.Open(root, rest[0])
+		if err != nil {
+			return fail(err)
+		}
+		results, err := kb.Search(ctx, strings.Join(rest[1:], " "), knowledge.SearchOptions{
+			Mode:         *mode,
+			TopK:         *topK,
+			Threshold:    *threshold,
+			VectorWeight: *vectorWeight,
+		})
+		if err != nil {
+			return fail(err)
+		}
+		if len(results) == 0 {
+			fmt.Println("No matching chunks")
+		}
+		for i, r := range results {
+			location := fmt.Sprintf("%s #%d", r.Document.Name, r.Chunk.Index)
+			if r.Chunk.Page > 0 {
+				location += fmt.Sprintf(", page %d", r.Chunk.Page)
+			}
+			if r.Chunk.Section != "" {
+				location += ", " + r.Chunk.Section
+			}
+			fmt.Printf("%d. %.3f (keyword %.3f, vector %.3f)  %s\n", i+1, r.Score, r.KeywordScore, r.VectorScore, location)
+			text := r.Chunk.Text
+			if !*full {
+				text = preview(text, 300)
+			}
+			fmt.Printf("   %s\n\n", strings.ReplaceAll(text, "\n", "\n   "))
+		}
+
+	default:
+		return usage()
+	}
+	return 0
+}
+
+// parseInterspersed parses flags that may follow positional arguments
+// ("kb create docs -embedder hash") and returns the positional arguments
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var rest []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return rest, nil
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+}
+
+// ingestible reports whether a file found while walking a directory has a supported format
+func ingestible(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := f.Read(head)
+	return document.DetectFormat(head[:n], filepath.Base(path), "") != ""
+}
+
+// preview shortens text to about max characters
+func preview(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max]) + "…"
+}
@@ -2,34 +2,183 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"dify-vnext-go/pkg/dsl"
+	"dify-vnext-go/pkg/egress"
 	"dify-vnext-go/pkg/engine"
 	"dify-vnext-go/pkg/nodes"
+	"dify-vnext-go/pkg/secrets"
+	"dify-vnext-go/pkg/tools"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "secrets" {
+		os.Exit(runSecrets(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "kb" {
+		os.Exit(runKB(os.Args[2:]))
+	}
+
 	workflowFile := flag.String("f", "examples/simple.yaml", "Path to workflow YAML file")
+	workflowDir := flag.String("workflows", "", "Directory of workflows callable by name from Workflow nodes")
+	threadID := flag.String("thread", engine.DefaultThreadID, "Thread ID used for checkpoints")
+	checkpointDir := flag.String("checkpoint-dir", "", "Persist checkpoints in this directory (required to resume from another process)")
+	showPending := flag.Bool("pending", false, "Print the pending human input of the thread and exit")
+	resume := flag.Bool("resume", false, "Resume a suspended thread instead of starting a new run")
+	action := flag.String("action", "", "Action chosen when resuming a HumanInput node")
+	answer := flag.String("answer", "", "JSON object with form values when resuming a HumanInput node")
+	cacheDir := flag.String("cache-dir", "", "Persist cached node results in this directory (default: in-memory)")
+	printEvents := flag.Bool("events", false, "Print engine events as JSON lines")
+	listTools := flag.Bool("list-tools", false, "Print the registered tools, including the workflow's tool providers, as function-calling schemas and exit")
+	secretsFile := flag.String("secrets-file", "", "Dotenv or encrypted secrets file consulted before the environment (see `secrets` subcommand)")
+	envFile := flag.String("env-file", "", "Dotenv file overriding the workflow's env variables")
+	envVars := keyValueFlag{}
+	flag.Var(envVars, "env", "Override a workflow env variable as NAME=VALUE (repeatable, wins over -env-file)")
+	blobDir := flag.String("blob-dir", "", "Persist blobs (files and binary outputs) in this directory (default: <checkpoint-dir>/blobs, or in-memory)")
+	files := keyValueFlag{}
+	flag.Var(files, "file", "Pass a file to the workflow as a blob input, as NAME=PATH (repeatable)")
 	flag.Parse()
 
+	// Secrets come from the file first, then the environment. Every value resolved is
+	// redacted from stdout, logs, events, outputs and checkpoints.
+	var store secrets.Store = secrets.EnvStore{}
+	if *secretsFile != "" {
+		fileStore, err := secrets.LoadFile(*secretsFile)
+		if err != nil {
+			log.Fatalf("Failed to load secrets: %v", err)
+		}
+		store = secrets.Chain{fileStore, secrets.EnvStore{}}
+	}
+	vault := secrets.NewVault(store)
+	restoreStdout, err := secrets.RedactStdout(vault)
+	if err != nil {
+		log.Fatalf("Failed to redirect stdout: %v", err)
+	}
+	defer restoreStdout()
+	log.SetOutput(secrets.NewWriter(os.Stderr, vault))
+	fatalf := func(format string, args ...interface{}) {
+		restoreStdout()
+		log.Fatalf(format, args...)
+	}
+
+	if *workflowDir != "" {
+		if err := nodes.RegisterWorkflowDir(*workflowDir); err != nil {
+			fatalf("Failed to register workflows: %v", err)
+		}
+	}
+
 	// 1. Load Workflow Definition
 	wf, err := dsl.Parse(*workflowFile)
 	if err != nil {
-		log.Fatalf("Failed to parse workflow: %v", err)
+		fatalf("Failed to parse workflow: %v", err)
+	}
+
+	policy, err := egress.FromDefinition(wf.Egress)
+	if err != nil {
+		fatalf("Invalid egress policy: %v", err)
+	}
+
+	providerCtx := secrets.WithVault(egress.WithClient(context.Background(), policy.Client(nil)), vault)
+	toolRegistry := tools.Default.Child()
+	closeProviders, err := tools.RegisterProviders(providerCtx, toolRegistry, wf.ToolProviders)
+	if err != nil {
+		fatalf("Failed to register tool providers: %v", err)
+	}
+	defer closeProviders()
+
+	if *listTools {
+		schemas, _ := json.MarshalIndent(toolRegistry.FunctionSchemas(), "", "  ")
+		fmt.Println(string(schemas))
+		return
 	}
 
 	fmt.Printf("Loaded workflow: %s\n", wf.Name)
 
+	envOverrides := map[string]string{}
+	if *envFile != "" {
+		fileVars, err := secrets.LoadDotenv(*envFile)
+		if err != nil {
+			fatalf("Failed to load env file: %v", err)
+		}
+		// A shared dotenv file may hold more than this workflow declares
+		for name, value := range fileVars {
+			if _, ok := wf.Env[name]; ok {
+				envOverrides[name] = value
+			}
+		}
+	}
+	for name, value := range envVars {
+		envOverrides[name] = value
+	}
+	env, err := engine.ResolveEnv(wf.Env, envOverrides)
+	if err != nil {
+		fatalf("Invalid env: %v", err)
+	}
+
 	// 2. Initialize Engine
 	eng := engine.NewEngine(wf)
+	eng.SetEgressPolicy(policy)
+	eng.SetSecrets(vault)
+	eng.SetEnv(env)
+	eng.SetTools(toolRegistry)
 
 	// Initialize Checkpointer
-	cp := engine.NewInMemoryCheckpointer()
+	var cp engine.Checkpointer = engine.NewInMemoryCheckpointer()
+	if *checkpointDir != "" {
+		fileCp, err := engine.NewFileCheckpointer(*checkpointDir)
+		if err != nil {
+			fatalf("Failed to initialize checkpointer: %v", err)
+		}
+		cp = fileCp
+	}
 	eng.SetCheckpointer(cp)
 
+	// Blobs live next to file checkpoints so a resumed run can still read them
+	if *blobDir == "" && *checkpointDir != "" {
+		*blobDir = filepath.Join(*checkpointDir, "blobs")
+	}
+	if *blobDir != "" {
+		blobStore, err := engine.NewFileBlobStore(*blobDir)
+		if err != nil {
+			fatalf("Failed to initialize blob store: %v", err)
+		}
+		eng.SetBlobStore(blobStore)
+	}
+	eng.SetThreadID(*threadID)
+
+	if *cacheDir != "" {
+		diskCache, err := engine.NewDiskCache(*cacheDir)
+		if err != nil {
+			fatalf("Failed to initialize cache: %v", err)
+		}
+		eng.SetCache(diskCache)
+	}
+
+	if *printEvents {
+		eng.SetEventHandler(func(ev engine.Event) {
+			line, _ := json.Marshal(ev)
+			fmt.Printf("[Event] %s\n", line)
+		})
+	}
+
+	if *showPending {
+		pending, err := engine.LoadPending(cp, *threadID)
+		if err != nil {
+			fatalf("Failed to load pending input: %v", err)
+		}
+		printPending(pending)
+		return
+	}
+
 	// 3. Register Nodes
 	// In a real app, this would be dynamic or plugin-based
 	// For MVP, we manually register known node types
@@ -49,12 +198,84 @@ func main() {
 		"topic": "Go Lang", // For research.yaml
 		"query": "Go Lang", // For simple.yaml
 	}
+	for name, path := range files {
+		ref, err := putFile(eng.Blobs(), path)
+		if err != nil {
+			fatalf("Failed to load file %s: %v", name, err)
+		}
+		inputs[name] = ref
+	}
 
-	// 5. Run Workflow
+	// 5. Run (or Resume) Workflow
 	ctx := context.Background()
-	if err := eng.Run(ctx, inputs); err != nil {
-		log.Fatalf("Workflow execution failed: %v", err)
+	if *resume {
+		resumeInput := make(map[string]interface{})
+		if *answer != "" {
+			if err := json.Unmarshal([]byte(*answer), &resumeInput); err != nil {
+				fatalf("Invalid -answer JSON: %v", err)
+			}
+		}
+		if *action != "" {
+			resumeInput["action"] = *action
+		}
+		err = eng.Resume(ctx, resumeInput)
+	} else {
+		err = eng.Run(ctx, inputs)
+	}
+
+	var interrupt *engine.InterruptError
+	if errors.As(err, &interrupt) {
+		printPending(interrupt)
+		if *checkpointDir == "" {
+			fmt.Println("The run was checkpointed in memory only; run with -checkpoint-dir to resume it from another process.")
+			return
+		}
+		fmt.Printf("Resume with: -f %s -thread %s -checkpoint-dir %s -resume -action <id> -answer '<json>'\n", *workflowFile, *threadID, *checkpointDir)
+		return
+	}
+	if err != nil {
+		fatalf("Workflow execution failed: %v", err)
 	}
 
 	fmt.Println("Workflow execution completed successfully.")
 }
+
+func printPending(pending *engine.InterruptError) {
+	record, _ := json.MarshalIndent(map[string]interface{}{
+		"thread_id": pending.ThreadID,
+		"node_id":   pending.NodeID,
+		"request":   pending.Payload,
+	}, "", "  ")
+	fmt.Printf("Workflow suspended, waiting for input:\n%s\n", record)
+}
+
+// keyValueFlag collects repeated NAME=VALUE flags
+type keyValueFlag map[string]string
+
+func (f keyValueFlag) String() string {
+	return ""
+}
+
+func (f keyValueFlag) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected NAME=VALUE, got %q", s)
+	}
+	f[name] = value
+	return nil
+}
+
+// putFile stores a local file as a blob, taking the MIME type from its extension when known
+func putFile(store engine.BlobStore, path string) (engine.BlobRef, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return engine.BlobRef{}, err
+	}
+	defer f.Close()
+	ref, err := store.Put(f, mime.TypeByExtension(filepath.Ext(path)))
+	if err != nil {
+		return engine.BlobRef{}, err
+	}
+	ref.Name = filepath.Base(path)
+	return ref, nil
+}
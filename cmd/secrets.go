@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"dify-vnext-go/pkg/secrets"
+)
+
+const secretsUsage = `Usage: main secrets <command> [-file path] [args]
+
+Manage an encrypted secrets file for use with -secrets-file.
+The file is encrypted with the key in $VNEXT_SECRETS_KEY.
+
+Commands:
+  keygen              Print a new random key for VNEXT_SECRETS_KEY
+  set NAME [VALUE]    Store a secret; the value is read from stdin when omitted
+  delete NAME         Remove a secret
+  list                Print the names of stored secrets
+`
+
+// runSecrets implements the "secrets" subcommand and returns the exit code
+func runSecrets(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, secretsUsage)
+		return 2
+	}
+	cmd := args[0]
+	fs := flag.NewFlagSet("secrets "+cmd, flag.ContinueOnError)
+	file := fs.String("file", "secrets.enc", "Encrypted secrets file")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	rest := fs.Args()
+
+	if cmd == "keygen" {
+		key, err := secrets.GenerateKey()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to generate key: %v\n", err)
+			return 1
+		}
+		fmt.Println(key)
+		return 0
+	}
+
+	key, err := secrets.KeyFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v (generate one with `secrets keygen`)\n", err)
+		return 1
+	}
+	store, err := secrets.LoadEncryptedFile(*file, key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	switch cmd {
+	case "set":
+		if len(rest) < 1 || len(rest) > 2 {
+			fmt.Fprint(os.Stderr, secretsUsage)
+			return 2
+		}
+		value := ""
+		if len(rest) == 2 {
+			value = rest[1]
+		} else {
+			// Reading from stdin keeps the value out of shell history
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				fmt.Fprintf(os.Stderr, "Failed to read value: %v\n", err)
+				return 1
+			}
+			value = strings.TrimRight(line, "\r\n")
+		}
+		store[rest[0]] = value
+	case "delete":
+		if len(rest) != 1 {
+			fmt.Fprint(os.Stderr, secretsUsage)
+			return 2
+		}
+		if _, ok := store[rest[0]]; !ok {
+			fmt.Fprintf(os.Stderr, "No secret named %s\n", rest[0])
+			return 1
+		}
+		delete(store, rest[0])
+	case "list":
+		for _, name := range store.Names() {
+			fmt.Println(name)
+		}
+		return 0
+	default:
+		fmt.Fprint(os.Stderr, secretsUsage)
+		return 2
+	}
+
+	if err := secrets.SaveEncryptedFile(*file, key, store); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save secrets: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,24 @@
+// Shared text helpers for Code nodes. Load with require("./text") and lib_dir: "examples/lib".
+
+// sentences splits text on "." and drops empty fragments
+exports.sentences = function (text) {
+  var parts = text.split(".");
+  var out = [];
+  for (var i = 0; i < parts.length; i++) {
+    var s = parts[i].trim();
+    if (s.length > 0) {
+      out.push(s);
+    }
+  }
+  return out;
+};
+
+// wordCount counts whitespace-separated words
+exports.wordCount = function (text) {
+  var words = text.split(/\s+/);
+  var count = 0;
+  for (var i = 0; i < words.length; i++) {
+    if (words[i].length > 0) count++;
+  }
+  return count;
+};
@@ -2,11 +2,13 @@ module dify-vnext-go
 
 go 1.21
 
-require gopkg.in/yaml.v3 v3.0.1
+require (
+	github.com/dop251/goja v0.0.0-20251103141225-af2ceb9156d7
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/dlclark/regexp2 v1.11.4 // indirect
-	github.com/dop251/goja v0.0.0-20251103141225-af2ceb9156d7 // indirect
 	github.com/go-sourcemap/sourcemap v2.1.3+incompatible // indirect
 	github.com/google/pprof v0.0.0-20230207041349-798e818bf904 // indirect
 	github.com/r3labs/sse/v2 v2.10.0 // indirect
@@ -0,0 +1,210 @@
+// Package document extracts plain text from uploaded files, keeping page and section
+// structure so later steps (splitting, retrieval, citations) can point back into the source.
+package document
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// Supported formats
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatCSV      = "csv"
+	FormatJSON     = "json"
+	FormatDOCX     = "docx"
+	FormatPDF      = "pdf"
+)
+
+// Document is the text extracted from a file
+type Document struct {
+	Format   string
+	Text     string
+	Pages    []Page    // Only for paged formats (PDF)
+	Sections []Section // Headings found in the document, in order
+}
+
+// Page is one page of a paged document. Offset is the byte offset of the page in Document.Text.
+type Page struct {
+	Number int
+	Offset int
+	Text   string
+}
+
+// Section is the text under a heading, up to the next heading of any level.
+// Offset is the byte offset of the heading in Document.Text; Page is 0 for unpaged formats.
+type Section struct {
+	Title  string
+	Level  int
+	Page   int
+	Offset int
+	Text   string
+}
+
+// Extract converts data to text. The format is chosen from the MIME type, falling back
+// to the file name's extension and finally to sniffing the content.
+func Extract(data []byte, name, mimeType string) (*Document, error) {
+	format := DetectFormat(data, name, mimeType)
+	if format == "" {
+		return nil, fmt.Errorf("unsupported document type (name %q, mime type %q)", name, mimeType)
+	}
+	return ExtractFormat(data, format)
+}
+
+// ExtractFormat converts data in a known format to text
+func ExtractFormat(data []byte, format string) (*Document, error) {
+	var (
+		doc *Document
+		err error
+	)
+	switch format {
+	case FormatText:
+		doc = &Document{Text: normalizeNewlines(string(data))}
+	case FormatMarkdown:
+		doc = extractMarkdown(data)
+	case FormatHTML:
+		doc = extractHTML(data)
+	case FormatCSV:
+		doc, err = extractCSV(data)
+	case FormatJSON:
+		doc, err = extractJSON(data)
+	case FormatDOCX:
+		doc, err = extractDOCX(data)
+	case FormatPDF:
+		doc, err = extractPDF(data)
+	default:
+		return nil, fmt.Errorf("unsupported document format: %s", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract %s: %w", format, err)
+	}
+	doc.Format = format
+	return doc, nil
+}
+
+var mimeFormats = map[string]string{
+	"text/plain":            FormatText,
+	"text/markdown":         FormatMarkdown,
+	"text/x-markdown":       FormatMarkdown,
+	"text/html":             FormatHTML,
+	"application/xhtml+xml": FormatHTML,
+	"text/csv":              FormatCSV,
+	"application/json":      FormatJSON,
+	"application/pdf":       FormatPDF,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": FormatDOCX,
+}
+
+var extFormats = map[string]string{
+	".txt":      FormatText,
+	".text":     FormatText,
+	".log":      FormatText,
+	".md":       FormatMarkdown,
+	".markdown": FormatMarkdown,
+	".html":     FormatHTML,
+	".htm":      FormatHTML,
+	".csv":      FormatCSV,
+	".json":     FormatJSON,
+	".docx":     FormatDOCX,
+	".pdf":      FormatPDF,
+}
+
+// DetectFormat returns the format of a file, or "" if it is not supported
+func DetectFormat(data []byte, name, mimeType string) string {
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		if format, ok := mimeFormats[mediaType]; ok && mediaType != "text/plain" {
+			return format
+		}
+	}
+	// text/plain is what most clients send for any text file, so prefer the extension
+	if format, ok := extFormats[strings.ToLower(filepath.Ext(name))]; ok {
+		return format
+	}
+
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	switch {
+	case strings.HasPrefix(string(data), "%PDF-"):
+		return FormatPDF
+	case sniffed == "application/zip" && isDOCX(data):
+		return FormatDOCX
+	case sniffed == "text/html":
+		return FormatHTML
+	case strings.HasPrefix(sniffed, "text/"):
+		return FormatText
+	}
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil && mediaType == "text/plain" {
+		return FormatText
+	}
+	return ""
+}
+
+func normalizeNewlines(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\r", "\n")
+}
+
+// builder assembles extracted text block by block, recording where headings and pages start
+type builder struct {
+	sb       strings.Builder
+	sections []Section
+	pages    []Page
+	page     int
+}
+
+// block appends a paragraph, separated from the previous one by a blank line
+func (b *builder) block(text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	if b.sb.Len() > 0 {
+		b.sb.WriteString("\n\n")
+	}
+	b.sb.WriteString(text)
+}
+
+// heading appends a heading and starts a new section
+func (b *builder) heading(level int, title string) {
+	title = strings.Join(strings.Fields(title), " ")
+	if title == "" {
+		return
+	}
+	if b.sb.Len() > 0 {
+		b.sb.WriteString("\n\n")
+	}
+	b.sections = append(b.sections, Section{Title: title, Level: level, Page: b.page, Offset: b.sb.Len()})
+	b.sb.WriteString(title)
+}
+
+// startPage marks the beginning of a new page
+func (b *builder) startPage(number int) {
+	if b.sb.Len() > 0 {
+		b.sb.WriteString("\n\n")
+	}
+	b.page = number
+	b.pages = append(b.pages, Page{Number: number, Offset: b.sb.Len()})
+}
+
+// document fills in page and section text from the recorded offsets
+func (b *builder) document() *Document {
+	text := b.sb.String()
+	for i := range b.pages {
+		end := len(text)
+		if i+1 < len(b.pages) {
+			end = b.pages[i+1].Offset
+		}
+		b.pages[i].Text = strings.TrimSpace(text[b.pages[i].Offset:end])
+	}
+	for i := range b.sections {
+		end := len(text)
+		if i+1 < len(b.sections) {
+			end = b.sections[i+1].Offset
+		}
+		b.sections[i].Text = strings.TrimSpace(text[b.sections[i].Offset:end])
+	}
+	return &Document{Text: text, Pages: b.pages, Sections: b.sections}
+}
@@ -0,0 +1,142 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// buildDOCX zips the given parts into a DOCX file
+func buildDOCX(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+const docxStyles = `<?xml version="1.0" encoding="UTF-8"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+  <w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/></w:style>
+  <w:style w:type="paragraph" w:styleId="Sub"><w:name w:val="Subsection"/><w:pPr><w:outlineLvl w:val="1"/></w:pPr></w:style>
+  <w:style w:type="character" w:styleId="Heading1Char"><w:name w:val="heading 1"/></w:style>
+</w:styles>`
+
+const docxBody = `<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:body>
+  <w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Getting</w:t></w:r><w:r><w:t xml:space="preserve"> started</w:t></w:r></w:p>
+  <w:p><w:r><w:t xml:space="preserve">Hello </w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t>world</w:t></w:r><w:r><w:tab/><w:t>tabbed</w:t><w:br/><w:t>next line</w:t></w:r></w:p>
+  <w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>First item</w:t></w:r></w:p>
+  <w:p><w:pPr><w:pStyle w:val="Sub"/></w:pPr><w:r><w:t>Prices</w:t></w:r></w:p>
+  <w:tbl>
+    <w:tr><w:tc><w:p><w:r><w:t>Plan</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Price</w:t></w:r></w:p></w:tc></w:tr>
+    <w:tr><w:tc><w:p><w:r><w:t>Pro</w:t></w:r></w:p><w:p><w:r><w:t>(yearly)</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>$10</w:t></w:r></w:p></w:tc></w:tr>
+  </w:tbl>
+  <w:p><w:pPr><w:outlineLvl w:val="0"/></w:pPr><w:r><w:t>Direct outline</w:t></w:r></w:p>
+  <w:p/>
+</w:body>
+</w:document>`
+
+func TestExtractDOCX(t *testing.T) {
+	data := buildDOCX(t, map[string]string{"word/document.xml": docxBody, "word/styles.xml": docxStyles})
+
+	// Sniffed without a name or MIME type
+	if format := DetectFormat(data, "", ""); format != FormatDOCX {
+		t.Fatalf("DetectFormat = %q, want docx", format)
+	}
+	doc, err := Extract(data, "guide.docx", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Getting started\n\nHello world\ttabbed\nnext line\n\n- First item\n\nPrices\n\nPlan | Price\nPro (yearly) | $10\n\nDirect outline"
+	if doc.Text != want {
+		t.Errorf("text = %q, want %q", doc.Text, want)
+	}
+	wantSections := []Section{
+		{Title: "Getting started", Level: 1, Offset: 0, Text: "Getting started\n\nHello world\ttabbed\nnext line\n\n- First item"},
+		{Title: "Prices", Level: 2, Offset: strings.Index(want, "Prices"), Text: "Prices\n\nPlan | Price\nPro (yearly) | $10"},
+		{Title: "Direct outline", Level: 1, Offset: strings.Index(want, "Direct"), Text: "Direct outline"},
+	}
+	if !reflect.DeepEqual(doc.Sections, wantSections) {
+		t.Errorf("sections = %+v, want %+v", doc.Sections, wantSections)
+	}
+	if doc.Pages != nil {
+		t.Errorf("DOCX should have no pages, got %+v", doc.Pages)
+	}
+
+	// Without styles.xml only direct outline levels make headings
+	doc, err = Extract(buildDOCX(t, map[string]string{"word/document.xml": docxBody}), "guide.docx", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Sections) != 1 || doc.Sections[0].Title != "Direct outline" {
+		t.Errorf("sections without styles = %+v", doc.Sections)
+	}
+
+	for name, data := range map[string][]byte{
+		"not a zip":        []byte("PK not really"),
+		"no document.xml":  buildDOCX(t, map[string]string{"word/styles.xml": docxStyles}),
+		"invalid document": buildDOCX(t, map[string]string{"word/document.xml": "<w:document><w:body>"}),
+	} {
+		if _, err := Extract(data, "broken.docx", ""); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestExtractHTML(t *testing.T) {
+	src := `<!DOCTYPE html>
+<html><head><title>Ignored</title><style>p { color: red }</style></head>
+<body>
+<h1>User <em>guide</em></h1>
+<p>Hello &amp; <b>welcome</b>,
+   friends.</p>
+<script>document.write("<p>not text</p>")</script>
+<!-- <p>commented out</p> -->
+<ul><li>One</li><li>Two</li></ul>
+<h2 class="x">Limits</h2>
+<table><tr><th>Plan</th><th>Calls</th></tr><tr><td>Free</td><td>100</td></tr></table>
+<pre>indented
+    code</pre>
+<p>a < b<br>next line</p>
+</body></html>`
+	doc, err := Extract([]byte(src), "page.html", "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Format != FormatHTML {
+		t.Fatalf("format = %q, want html", doc.Format)
+	}
+	want := "User guide\n\nHello & welcome, friends.\n\n- One\n\n- Two\n\nLimits\n\nPlan | Calls\nFree | 100\n\nindented\n    code\n\na < b\nnext line"
+	if doc.Text != want {
+		t.Errorf("text = %q, want %q", doc.Text, want)
+	}
+	wantSections := []Section{
+		{Title: "User guide", Level: 1, Offset: 0, Text: "User guide\n\nHello & welcome, friends.\n\n- One\n\n- Two"},
+		{Title: "Limits", Level: 2, Offset: strings.Index(want, "Limits"), Text: "Limits\n\nPlan | Calls\nFree | 100\n\nindented\n    code\n\na < b\nnext line"},
+	}
+	if !reflect.DeepEqual(doc.Sections, wantSections) {
+		t.Errorf("sections = %+v, want %+v", doc.Sections, wantSections)
+	}
+
+	// Sniffed from the content when neither name nor MIME type help
+	if format := DetectFormat([]byte("<html><body><p>x</p></body></html>"), "upload", ""); format != FormatHTML {
+		t.Errorf("DetectFormat = %q, want html", format)
+	}
+	// Unterminated tags and comments do not lose the text before them
+	if doc, err := Extract([]byte("<p>kept</p><!-- never closed <p>lost"), "x.html", ""); err != nil || doc.Text != "kept" {
+		t.Errorf("unterminated comment: %v, %v", doc, err)
+	}
+}
@@ -0,0 +1,229 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxDOCXPart bounds the decompressed size of a single part, guarding against zip bombs
+const maxDOCXPart = 64 << 20
+
+func isDOCX(data []byte) bool {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return false
+	}
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" {
+			return true
+		}
+	}
+	return false
+}
+
+// extractDOCX reads the paragraphs and tables of word/document.xml. Paragraphs whose style
+// has an outline level (Heading 1-9, Title) become sections; tables are rendered one row
+// per line with cells separated by " | ".
+func extractDOCX(data []byte) (*Document, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not a DOCX file: %w", err)
+	}
+	parts := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		parts[f.Name] = f
+	}
+	docPart, ok := parts["word/document.xml"]
+	if !ok {
+		return nil, fmt.Errorf("not a DOCX file: word/document.xml is missing")
+	}
+
+	headingStyles := map[string]int{}
+	if stylesPart, ok := parts["word/styles.xml"]; ok {
+		styles, err := readZipPart(stylesPart)
+		if err != nil {
+			return nil, err
+		}
+		headingStyles = parseDOCXStyles(styles)
+	}
+
+	doc, err := readZipPart(docPart)
+	if err != nil {
+		return nil, err
+	}
+	return parseDOCXBody(doc, headingStyles)
+}
+
+func readZipPart(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxDOCXPart+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	if len(data) > maxDOCXPart {
+		return nil, fmt.Errorf("%s is too large", f.Name)
+	}
+	return data, nil
+}
+
+// parseDOCXStyles maps paragraph style IDs to heading levels
+func parseDOCXStyles(data []byte) map[string]int {
+	levels := make(map[string]int)
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var (
+		id, name string
+		outline  = -1
+	)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "style":
+				id, name, outline = attr(t, "styleId"), "", -1
+				if attr(t, "type") != "paragraph" {
+					id = ""
+				}
+			case "name":
+				name = attr(t, "val")
+			case "outlineLvl":
+				if lvl, err := strconv.Atoi(attr(t, "val")); err == nil {
+					outline = lvl
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local != "style" || id == "" {
+				continue
+			}
+			lower := strings.ToLower(name)
+			switch {
+			case outline >= 0 && outline < 9:
+				levels[id] = outline + 1
+			case lower == "title":
+				levels[id] = 1
+			case strings.HasPrefix(lower, "heading "):
+				if lvl, err := strconv.Atoi(strings.TrimPrefix(lower, "heading ")); err == nil {
+					levels[id] = lvl
+				}
+			}
+		}
+	}
+	return levels
+}
+
+func parseDOCXBody(data []byte, headingStyles map[string]int) (*Document, error) {
+	var (
+		b        builder
+		para     strings.Builder
+		style    string
+		outline  = -1
+		listItem bool
+		inText   bool
+		tables   int
+		cell     []string // paragraphs of the current table cell
+		row      []string // cells of the current table row
+		table    []string // rows of the current table
+	)
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid document.xml: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				para.Reset()
+				style, outline, listItem = "", -1, false
+			case "pStyle":
+				style = attr(t, "val")
+			case "outlineLvl":
+				if lvl, err := strconv.Atoi(attr(t, "val")); err == nil {
+					outline = lvl
+				}
+			case "numPr":
+				listItem = true
+			case "t":
+				inText = true
+			case "tab":
+				para.WriteByte('\t')
+			case "br", "cr":
+				para.WriteByte('\n')
+			case "tbl":
+				tables++
+				if tables == 1 {
+					table = nil
+				}
+			case "tr":
+				row = nil
+			case "tc":
+				cell = nil
+			}
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text := strings.TrimSpace(para.String())
+				if tables > 0 {
+					if text != "" {
+						cell = append(cell, text)
+					}
+					continue
+				}
+				level := headingStyles[style]
+				if outline >= 0 && outline < 9 {
+					level = outline + 1
+				}
+				switch {
+				case level > 0:
+					b.heading(level, text)
+				case listItem && text != "":
+					b.block("- " + text)
+				default:
+					b.block(text)
+				}
+			case "tc":
+				row = append(row, strings.Join(cell, " "))
+			case "tr":
+				table = append(table, strings.Join(row, " | "))
+			case "tbl":
+				tables--
+				if tables == 0 {
+					b.block(strings.Join(table, "\n"))
+				}
+			}
+		}
+	}
+	return b.document(), nil
+}
+
+// attr returns the value of an attribute by local name, ignoring its namespace
+func attr(el xml.StartElement, local string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,194 @@
+package document
+
+import (
+	"html"
+	"strings"
+)
+
+// blockTags end the current paragraph when opened or closed
+var blockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "header": true, "footer": true,
+	"nav": true, "aside": true, "main": true, "blockquote": true, "ul": true, "ol": true,
+	"li": true, "table": true, "thead": true, "tbody": true, "tfoot": true, "tr": true,
+	"dl": true, "dt": true, "dd": true, "figure": true, "figcaption": true, "form": true,
+	"hr": true, "address": true, "pre": true, "caption": true, "body": true,
+}
+
+// skipTags have content that is not part of the document text
+var skipTags = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "template": true,
+	"svg": true, "iframe": true, "object": true,
+}
+
+// extractHTML converts HTML to paragraphs of text, with h1-h6 as sections. It is a
+// tolerant scanner rather than a full parser: unclosed and unknown tags are ignored.
+func extractHTML(data []byte) *Document {
+	src := string(data)
+	var (
+		b       builder
+		inline  strings.Builder
+		skip    string // tag whose content is being skipped
+		heading int    // level of the open heading, 0 outside headings
+		pre     int
+	)
+	flush := func() {
+		text := inline.String()
+		inline.Reset()
+		if heading > 0 {
+			return
+		}
+		var lines []string
+		for _, line := range strings.Split(text, "\n") {
+			if pre == 0 {
+				line = strings.TrimSpace(line)
+			} else {
+				line = strings.TrimRight(line, " \t")
+			}
+			lines = append(lines, line)
+		}
+		b.block(strings.Join(lines, "\n"))
+	}
+
+	for i := 0; i < len(src); {
+		if src[i] != '<' {
+			end := strings.IndexByte(src[i:], '<')
+			if end < 0 {
+				end = len(src) - i
+			}
+			if skip == "" {
+				text := html.UnescapeString(src[i : i+end])
+				if pre == 0 {
+					text = collapseSpace(text)
+				}
+				inline.WriteString(text)
+			}
+			i += end
+			continue
+		}
+
+		if strings.HasPrefix(src[i:], "<!--") {
+			end := strings.Index(src[i+4:], "-->")
+			if end < 0 {
+				break
+			}
+			i += 4 + end + 3
+			continue
+		}
+		if i+1 >= len(src) || !(isAlnum(src[i+1]) || strings.IndexByte("/!?", src[i+1]) >= 0) {
+			// A lone "<" in text
+			if skip == "" {
+				inline.WriteByte('<')
+			}
+			i++
+			continue
+		}
+		name, closing, end := scanTag(src, i)
+		i = end
+		if name == "" {
+			continue
+		}
+
+		if skip != "" {
+			if closing && name == skip {
+				skip = ""
+			}
+			continue
+		}
+		if skipTags[name] && !closing {
+			skip = name
+			continue
+		}
+
+		switch {
+		case len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6':
+			if !closing {
+				flush()
+				heading = int(name[1] - '0')
+			} else if heading > 0 {
+				title := inline.String()
+				inline.Reset()
+				b.heading(heading, title)
+				heading = 0
+			}
+		case name == "br":
+			inline.WriteString("\n")
+		case name == "td" || name == "th":
+			if !closing && !strings.HasSuffix(inline.String(), "\n") && inline.Len() > 0 {
+				inline.WriteString(" | ")
+			}
+		case name == "tr" && closing:
+			inline.WriteString("\n")
+		case blockTags[name]:
+			if name == "tr" || name == "tbody" || name == "thead" {
+				continue
+			}
+			flush()
+			if name == "pre" {
+				if closing && pre > 0 {
+					pre--
+				} else if !closing {
+					pre++
+				}
+			}
+			if name == "li" && !closing {
+				inline.WriteString("- ")
+			}
+		}
+	}
+	flush()
+	return b.document()
+}
+
+// scanTag reads the tag starting at src[start] and returns its lower-cased name, whether
+// it is a closing tag, and the index just past it. Declarations and processing
+// instructions have an empty name.
+func scanTag(src string, start int) (name string, closing bool, end int) {
+	i := start + 1
+	if i < len(src) && src[i] == '/' {
+		closing = true
+		i++
+	}
+	nameStart := i
+	for i < len(src) && (isAlnum(src[i]) || src[i] == '-' || src[i] == ':') {
+		i++
+	}
+	name = strings.ToLower(src[nameStart:i])
+
+	var quote byte
+	for ; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return name, closing, i + 1
+		}
+	}
+	return name, closing, len(src)
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// collapseSpace replaces runs of whitespace with a single space
+func collapseSpace(s string) string {
+	var sb strings.Builder
+	space := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' {
+			if !space {
+				sb.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,1377 @@
+package document
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// The PDF reader below is deliberately small: it locates objects by scanning the file
+// (which also copes with broken xref tables), decodes Flate/ASCIIHex/ASCII85 streams and
+// object streams, walks the page tree and interprets the text operators of each page's
+// content stream. Text is mapped to Unicode through ToUnicode CMaps, falling back to the
+// font's simple encoding. Layout is approximated: new lines start on vertical moves and
+// large horizontal gaps become spaces.
+
+const (
+	maxPDFStream  = 128 << 20 // Limit on a decoded stream
+	maxPDFDepth   = 32        // Limit on reference chains and nested page trees
+	maxFormDepth  = 8         // Limit on nested form XObjects
+	tjSpaceAdjust = -200      // TJ offsets (thousandths of an em) below this become spaces
+)
+
+type (
+	pdfName    string
+	pdfKeyword string
+	pdfString  []byte
+	pdfArray   []interface{}
+	pdfDict    map[pdfName]interface{}
+	pdfRef     struct{ num, gen int }
+	pdfStream  struct {
+		dict pdfDict
+		raw  []byte
+	}
+)
+
+// Structural tokens produced by the lexer
+type pdfDelim byte
+
+const (
+	delimArrayStart pdfDelim = '['
+	delimArrayEnd   pdfDelim = ']'
+	delimDictStart  pdfDelim = '<'
+	delimDictEnd    pdfDelim = '>'
+)
+
+var errPDFEOF = errors.New("unexpected end of PDF data")
+
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelim(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isPDFSpace(c) {
+			l.pos++
+		} else if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		} else {
+			return
+		}
+	}
+}
+
+// token returns the next token: a number (float64), pdfName, pdfString, pdfKeyword
+// (including true/false/null and operators) or pdfDelim
+func (l *pdfLexer) token() (interface{}, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, errPDFEOF
+	}
+	c := l.data[l.pos]
+	switch c {
+	case '/':
+		l.pos++
+		start := l.pos
+		for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelim(l.data[l.pos]) {
+			l.pos++
+		}
+		return pdfName(decodeNameEscapes(l.data[start:l.pos])), nil
+	case '(':
+		return l.literalString()
+	case '<':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+			l.pos += 2
+			return delimDictStart, nil
+		}
+		return l.hexString()
+	case '>':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '>' {
+			l.pos += 2
+			return delimDictEnd, nil
+		}
+		l.pos++
+		return pdfKeyword(">"), nil
+	case '[':
+		l.pos++
+		return delimArrayStart, nil
+	case ']':
+		l.pos++
+		return delimArrayEnd, nil
+	case '{', '}', ')':
+		l.pos++
+		return pdfKeyword(string(c)), nil
+	}
+
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelim(l.data[l.pos]) {
+		l.pos++
+	}
+	word := string(l.data[start:l.pos])
+	if c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9') {
+		if f, err := strconv.ParseFloat(word, 64); err == nil {
+			return f, nil
+		}
+	}
+	return pdfKeyword(word), nil
+}
+
+func decodeNameEscapes(b []byte) string {
+	if bytes.IndexByte(b, '#') < 0 {
+		return string(b)
+	}
+	var out []byte
+	for i := 0; i < len(b); i++ {
+		if b[i] == '#' && i+2 < len(b) {
+			if v, err := strconv.ParseUint(string(b[i+1:i+3]), 16, 8); err == nil {
+				out = append(out, byte(v))
+				i += 2
+				continue
+			}
+		}
+		out = append(out, b[i])
+	}
+	return string(out)
+}
+
+func (l *pdfLexer) literalString() (pdfString, error) {
+	l.pos++ // (
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfString(out), nil
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return nil, errPDFEOF
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// Line continuation
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for k := 0; k < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; k++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return nil, errPDFEOF
+}
+
+func (l *pdfLexer) hexString() (pdfString, error) {
+	l.pos++ // <
+	var digits []byte
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		if c == '>' {
+			if len(digits)%2 == 1 {
+				digits = append(digits, '0')
+			}
+			out := make([]byte, len(digits)/2)
+			if _, err := hex.Decode(out, digits); err != nil {
+				return nil, fmt.Errorf("invalid hex string: %w", err)
+			}
+			return pdfString(out), nil
+		}
+		if !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	return nil, errPDFEOF
+}
+
+// object parses a complete object, combining "num gen R" into a pdfRef
+func (l *pdfLexer) object() (interface{}, error) {
+	tok, err := l.token()
+	if err != nil {
+		return nil, err
+	}
+	return l.objectFrom(tok)
+}
+
+func (l *pdfLexer) objectFrom(tok interface{}) (interface{}, error) {
+	switch t := tok.(type) {
+	case pdfDelim:
+		switch t {
+		case delimArrayStart:
+			var arr pdfArray
+			for {
+				next, err := l.token()
+				if err != nil {
+					return nil, err
+				}
+				if next == delimArrayEnd {
+					return arr, nil
+				}
+				val, err := l.objectFrom(next)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, val)
+			}
+		case delimDictStart:
+			dict := pdfDict{}
+			for {
+				next, err := l.token()
+				if err != nil {
+					return nil, err
+				}
+				if next == delimDictEnd {
+					return dict, nil
+				}
+				key, ok := next.(pdfName)
+				if !ok {
+					continue // Tolerate junk between entries
+				}
+				val, err := l.object()
+				if err != nil {
+					return nil, err
+				}
+				dict[key] = val
+			}
+		}
+		return nil, fmt.Errorf("unexpected %q", byte(t))
+	case float64:
+		// Look ahead for "gen R"
+		if t == math.Trunc(t) && t >= 0 {
+			save := l.pos
+			gen, err1 := l.token()
+			r, err2 := l.token()
+			if g, ok := gen.(float64); ok && err1 == nil && err2 == nil && r == pdfKeyword("R") {
+				return pdfRef{num: int(t), gen: int(g)}, nil
+			}
+			l.pos = save
+		}
+		return t, nil
+	case pdfKeyword:
+		switch t {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return t, nil
+	}
+	return tok, nil
+}
+
+// pdfFile holds every object found in a PDF
+type pdfFile struct {
+	objects  map[int]interface{}
+	trailers []pdfDict
+}
+
+var objHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+var streamEnd = []byte("endstream")
+
+func parsePDF(data []byte) (*pdfFile, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\r\n "), []byte("%PDF-")) {
+		return nil, fmt.Errorf("not a PDF file")
+	}
+	f := &pdfFile{objects: make(map[int]interface{})}
+
+	skipUntil := 0
+	for _, m := range objHeader.FindAllSubmatchIndex(data, -1) {
+		if m[0] < skipUntil {
+			continue // Inside a stream we already read
+		}
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		l := &pdfLexer{data: data, pos: m[1]}
+		val, err := l.object()
+		if err != nil {
+			continue
+		}
+		if dict, ok := val.(pdfDict); ok {
+			l.skipSpace()
+			if bytes.HasPrefix(data[l.pos:], []byte("stream")) {
+				raw, end := readStreamData(data, l.pos+len("stream"), dict)
+				val = &pdfStream{dict: dict, raw: raw}
+				skipUntil = end
+			}
+		}
+		f.objects[num] = val
+	}
+
+	// Later revisions may store objects in object streams
+	for _, val := range f.objects {
+		if s, ok := val.(*pdfStream); ok && s.dict["Type"] == pdfName("ObjStm") {
+			f.loadObjectStream(s)
+		}
+	}
+
+	for _, loc := range regexp.MustCompile(`trailer\s*<<`).FindAllIndex(data, -1) {
+		l := &pdfLexer{data: data, pos: loc[0] + len("trailer")}
+		if val, err := l.object(); err == nil {
+			if dict, ok := val.(pdfDict); ok {
+				f.trailers = append(f.trailers, dict)
+			}
+		}
+	}
+	for _, val := range f.objects {
+		if s, ok := val.(*pdfStream); ok && s.dict["Type"] == pdfName("XRef") {
+			f.trailers = append(f.trailers, s.dict)
+		}
+	}
+	for _, t := range f.trailers {
+		if _, ok := t["Encrypt"]; ok {
+			return nil, fmt.Errorf("encrypted PDFs are not supported")
+		}
+	}
+	return f, nil
+}
+
+// readStreamData returns the raw bytes of a stream starting after the "stream" keyword,
+// and the offset just past it. A direct /Length is trusted when "endstream" follows it;
+// otherwise the data runs to the next "endstream".
+func readStreamData(data []byte, pos int, dict pdfDict) ([]byte, int) {
+	if pos < len(data) && data[pos] == '\r' {
+		pos++
+	}
+	if pos < len(data) && data[pos] == '\n' {
+		pos++
+	}
+	if n, ok := dict["Length"].(float64); ok && n >= 0 {
+		end := pos + int(n)
+		if end <= len(data) {
+			rest := bytes.TrimLeft(data[end:], "\r\n \t")
+			if bytes.HasPrefix(rest, streamEnd) {
+				return data[pos:end], end
+			}
+		}
+	}
+	idx := bytes.Index(data[pos:], streamEnd)
+	if idx < 0 {
+		return data[pos:], len(data)
+	}
+	raw := data[pos : pos+idx]
+	raw = bytes.TrimSuffix(raw, []byte("\n"))
+	raw = bytes.TrimSuffix(raw, []byte("\r"))
+	return raw, pos + idx
+}
+
+func (f *pdfFile) loadObjectStream(s *pdfStream) {
+	data, err := f.decodeStream(s)
+	if err != nil {
+		return
+	}
+	n, _ := f.resolve(s.dict["N"]).(float64)
+	first, _ := f.resolve(s.dict["First"]).(float64)
+	if int(first) > len(data) {
+		return
+	}
+	header := &pdfLexer{data: data[:int(first)]}
+	for i := 0; i < int(n); i++ {
+		numTok, err1 := header.token()
+		offTok, err2 := header.token()
+		num, ok1 := numTok.(float64)
+		off, ok2 := offTok.(float64)
+		if err1 != nil || err2 != nil || !ok1 || !ok2 {
+			return
+		}
+		if _, exists := f.objects[int(num)]; exists {
+			continue
+		}
+		pos := int(first) + int(off)
+		if pos >= len(data) {
+			continue
+		}
+		l := &pdfLexer{data: data, pos: pos}
+		if val, err := l.object(); err == nil {
+			f.objects[int(num)] = val
+		}
+	}
+}
+
+// resolve follows references
+func (f *pdfFile) resolve(v interface{}) interface{} {
+	for i := 0; i < maxPDFDepth; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = f.objects[ref.num]
+	}
+	return nil
+}
+
+func (f *pdfFile) dict(v interface{}) pdfDict {
+	switch t := f.resolve(v).(type) {
+	case pdfDict:
+		return t
+	case *pdfStream:
+		return t.dict
+	}
+	return nil
+}
+
+func (f *pdfFile) array(v interface{}) pdfArray {
+	a, _ := f.resolve(v).(pdfArray)
+	return a
+}
+
+// decodeStream applies the stream's filters
+func (f *pdfFile) decodeStream(s *pdfStream) ([]byte, error) {
+	var filters, params []interface{}
+	switch t := f.resolve(s.dict["Filter"]).(type) {
+	case pdfName:
+		filters = []interface{}{t}
+		params = []interface{}{s.dict["DecodeParms"]}
+	case pdfArray:
+		filters = t
+		params = f.array(s.dict["DecodeParms"])
+	}
+
+	data := s.raw
+	for i, filter := range filters {
+		var parms pdfDict
+		if i < len(params) {
+			parms = f.dict(params[i])
+		}
+		var err error
+		switch f.resolve(filter) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			data, err = inflate(data)
+			if err == nil {
+				data, err = applyPredictor(data, parms)
+			}
+		case pdfName("ASCIIHexDecode"), pdfName("AHx"):
+			l := &pdfLexer{data: append(append([]byte{'<'}, bytes.TrimSuffix(bytes.TrimSpace(data), []byte(">"))...), '>')}
+			data, err = l.hexString()
+		case pdfName("ASCII85Decode"), pdfName("A85"):
+			data, err = decodeASCII85(data)
+		default:
+			return nil, fmt.Errorf("unsupported stream filter %v", filter)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func inflate(data []byte) ([]byte, error) {
+	var r io.Reader
+	if zr, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+		r = zr
+	} else {
+		// Some writers omit the zlib header
+		r = flate.NewReader(bytes.NewReader(data))
+	}
+	out, err := io.ReadAll(io.LimitReader(r, maxPDFStream+1))
+	if len(out) > maxPDFStream {
+		return nil, fmt.Errorf("stream too large")
+	}
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	// A truncated or corrupt stream tail is common; keep what was decoded
+	return out, nil
+}
+
+// applyPredictor undoes PNG row predictors (Predictor >= 10)
+func applyPredictor(data []byte, parms pdfDict) ([]byte, error) {
+	predictor, _ := parms["Predictor"].(float64)
+	if predictor < 10 {
+		return data, nil
+	}
+	columns := 1
+	if c, ok := parms["Columns"].(float64); ok && c > 0 {
+		columns = int(c)
+	}
+	colors := 1
+	if c, ok := parms["Colors"].(float64); ok && c > 0 {
+		colors = int(c)
+	}
+	bpc := 8
+	if b, ok := parms["BitsPerComponent"].(float64); ok && b > 0 {
+		bpc = int(b)
+	}
+	bpp := (colors*bpc + 7) / 8
+	rowLen := (columns*colors*bpc + 7) / 8
+
+	var out []byte
+	prev := make([]byte, rowLen)
+	for pos := 0; pos+1+rowLen <= len(data); pos += 1 + rowLen {
+		filter := data[pos]
+		row := append([]byte(nil), data[pos+1:pos+1+rowLen]...)
+		for i := range row {
+			var left, up, upLeft byte
+			if i >= bpp {
+				left = row[i-bpp]
+				upLeft = prev[i-bpp]
+			}
+			up = prev[i]
+			switch filter {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func decodeASCII85(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	data = bytes.TrimPrefix(data, []byte("<~"))
+	if i := bytes.Index(data, []byte("~>")); i >= 0 {
+		data = data[:i]
+	}
+	out := make([]byte, 4*len(data)/5+8)
+	n, _, err := ascii85.Decode(out, data, true)
+	if err != nil {
+		return nil, err
+	}
+	return out[:n], nil
+}
+
+// extractPDF returns the text of each page, with the document outline (bookmarks) as sections
+func extractPDF(data []byte) (*Document, error) {
+	f, err := parsePDF(data)
+	if err != nil {
+		return nil, err
+	}
+	pages, catalog := f.pages()
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no pages found")
+	}
+
+	var b builder
+	pageNumbers := make(map[int]int) // page object number -> page number
+	for i, page := range pages {
+		b.startPage(i + 1)
+		if page.ref > 0 {
+			pageNumbers[page.ref] = i + 1
+		}
+		text := f.pageText(page.dict, page.resources)
+		if text != "" {
+			b.sb.WriteString(text)
+		}
+	}
+	doc := b.document()
+	doc.Sections = f.outline(catalog, pageNumbers, doc)
+	return doc, nil
+}
+
+type pdfPage struct {
+	ref       int
+	dict      pdfDict
+	resources pdfDict
+}
+
+// pages walks the page tree in order, passing inherited resources down
+func (f *pdfFile) pages() ([]pdfPage, pdfDict) {
+	var catalog pdfDict
+	for i := len(f.trailers) - 1; i >= 0 && catalog == nil; i-- {
+		catalog = f.dict(f.trailers[i]["Root"])
+	}
+	if catalog == nil {
+		nums := make([]int, 0, len(f.objects))
+		for num := range f.objects {
+			nums = append(nums, num)
+		}
+		sort.Ints(nums)
+		for _, num := range nums {
+			if d, ok := f.objects[num].(pdfDict); ok && d["Type"] == pdfName("Catalog") {
+				catalog = d
+			}
+		}
+	}
+
+	var pages []pdfPage
+	visited := make(map[int]bool)
+	var walk func(node interface{}, resources pdfDict, depth int)
+	walk = func(node interface{}, resources pdfDict, depth int) {
+		if depth > maxPDFDepth {
+			return
+		}
+		ref := 0
+		if r, ok := node.(pdfRef); ok {
+			if visited[r.num] {
+				return
+			}
+			visited[r.num] = true
+			ref = r.num
+		}
+		d := f.dict(node)
+		if d == nil {
+			return
+		}
+		if res := f.dict(d["Resources"]); res != nil {
+			resources = res
+		}
+		if kids := f.array(d["Kids"]); kids != nil || d["Type"] == pdfName("Pages") {
+			for _, kid := range kids {
+				walk(kid, resources, depth+1)
+			}
+			return
+		}
+		pages = append(pages, pdfPage{ref: ref, dict: d, resources: resources})
+	}
+	if catalog != nil {
+		walk(catalog["Pages"], nil, 0)
+	}
+
+	if len(pages) == 0 {
+		// No usable page tree: take page objects in file order
+		nums := make([]int, 0, len(f.objects))
+		for num := range f.objects {
+			nums = append(nums, num)
+		}
+		sort.Ints(nums)
+		for _, num := range nums {
+			if d, ok := f.objects[num].(pdfDict); ok && d["Type"] == pdfName("Page") {
+				pages = append(pages, pdfPage{ref: num, dict: d, resources: f.dict(d["Resources"])})
+			}
+		}
+	}
+	return pages, catalog
+}
+
+func (f *pdfFile) pageText(page pdfDict, resources pdfDict) string {
+	var content []byte
+	switch c := f.resolve(page["Contents"]).(type) {
+	case *pdfStream:
+		content, _ = f.decodeStream(c)
+	case pdfArray:
+		for _, part := range c {
+			if s, ok := f.resolve(part).(*pdfStream); ok {
+				if data, err := f.decodeStream(s); err == nil {
+					content = append(content, data...)
+					content = append(content, '\n')
+				}
+			}
+		}
+	}
+	w := &textWriter{}
+	f.runContent(content, resources, w, 0)
+	return w.String()
+}
+
+// textWriter accumulates page text, avoiding duplicate separators
+type textWriter struct {
+	sb strings.Builder
+}
+
+func (w *textWriter) text(s string) {
+	w.sb.WriteString(s)
+}
+
+func (w *textWriter) space() {
+	s := w.sb.String()
+	if len(s) > 0 && !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+		w.sb.WriteByte(' ')
+	}
+}
+
+func (w *textWriter) newline() {
+	s := w.sb.String()
+	if len(s) > 0 && !strings.HasSuffix(s, "\n\n") {
+		w.sb.WriteByte('\n')
+	}
+}
+
+func (w *textWriter) String() string {
+	lines := strings.Split(w.sb.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.TrimSpace(ligatures.Replace(strings.Join(lines, "\n")))
+}
+
+// ligatures are spelled out so extracted text matches what users search for
+var ligatures = strings.NewReplacer("ﬀ", "ff", "ﬁ", "fi", "ﬂ", "fl", "ﬃ", "ffi", "ﬄ", "ffl")
+
+// runContent interprets the text operators of a content stream
+func (f *pdfFile) runContent(content []byte, resources pdfDict, w *textWriter, depth int) {
+	fonts := f.dict(resources["Font"])
+	cache := make(map[pdfName]*pdfFont)
+	fontFor := func(name pdfName) *pdfFont {
+		if font, ok := cache[name]; ok {
+			return font
+		}
+		font := f.loadFont(fonts[name])
+		cache[name] = font
+		return font
+	}
+
+	var (
+		l        = &pdfLexer{data: content}
+		operands []interface{}
+		font     *pdfFont
+		lineY    float64
+	)
+	num := func(i int) float64 {
+		if i < len(operands) {
+			v, _ := operands[i].(float64)
+			return v
+		}
+		return 0
+	}
+	show := func(s interface{}) {
+		if str, ok := s.(pdfString); ok && font != nil {
+			w.text(font.decode(str))
+		} else if ok {
+			w.text(string(str))
+		}
+	}
+
+	for {
+		tok, err := l.token()
+		if err != nil {
+			return
+		}
+		op, isOp := tok.(pdfKeyword)
+		if !isOp {
+			val, err := l.objectFrom(tok)
+			if err == errPDFEOF {
+				return
+			}
+			if err != nil {
+				continue // Stray delimiter
+			}
+			operands = append(operands, val)
+			continue
+		}
+
+		switch op {
+		case "Tf":
+			if len(operands) >= 1 {
+				if name, ok := operands[0].(pdfName); ok {
+					font = fontFor(name)
+				}
+			}
+		case "Td", "TD":
+			if ty := num(1); math.Abs(ty) > 0.01 {
+				w.newline()
+				lineY += ty
+			} else if num(0) != 0 {
+				w.space()
+			}
+		case "Tm":
+			if y := num(5); math.Abs(y-lineY) > 0.5 {
+				w.newline()
+				lineY = y
+			} else {
+				w.space()
+			}
+		case "T*":
+			w.newline()
+		case "Tj":
+			if len(operands) > 0 {
+				show(operands[0])
+			}
+		case "'":
+			w.newline()
+			if len(operands) > 0 {
+				show(operands[0])
+			}
+		case "\"":
+			w.newline()
+			if len(operands) > 2 {
+				show(operands[2])
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				arr, _ := operands[0].(pdfArray)
+				for _, item := range arr {
+					if n, ok := item.(float64); ok && n < tjSpaceAdjust {
+						w.space()
+					} else {
+						show(item)
+					}
+				}
+			}
+		case "ET":
+			w.space()
+		case "Do":
+			if depth >= maxFormDepth || len(operands) == 0 {
+				break
+			}
+			name, _ := operands[0].(pdfName)
+			xobj, ok := f.resolve(f.dict(resources["XObject"])[name]).(*pdfStream)
+			if !ok || xobj.dict["Subtype"] != pdfName("Form") {
+				break
+			}
+			if data, err := f.decodeStream(xobj); err == nil {
+				formResources := f.dict(xobj.dict["Resources"])
+				if formResources == nil {
+					formResources = resources
+				}
+				f.runContent(data, formResources, w, depth+1)
+			}
+		case "BI":
+			// Inline image: skip the binary data between ID and EI
+			idx := bytes.Index(content[l.pos:], []byte("ID"))
+			if idx < 0 {
+				return
+			}
+			l.pos += idx + 3
+			for l.pos < len(content) {
+				end := bytes.Index(content[l.pos:], []byte("EI"))
+				if end < 0 {
+					return
+				}
+				l.pos += end + 2
+				if isPDFSpace(content[l.pos-3]) && (l.pos >= len(content) || isPDFSpace(content[l.pos])) {
+					break
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+// pdfFont maps character codes in shown strings to Unicode
+type pdfFont struct {
+	toUnicode map[string]string // code bytes -> text, from the ToUnicode CMap
+	codeLens  []int             // code lengths in bytes, shortest first
+	simple    *[256]rune        // single-byte encoding for simple fonts
+}
+
+func (f *pdfFile) loadFont(ref interface{}) *pdfFont {
+	d := f.dict(ref)
+	font := &pdfFont{}
+	if d == nil {
+		font.simple = &winAnsi
+		return font
+	}
+	if cmap, ok := f.resolve(d["ToUnicode"]).(*pdfStream); ok {
+		if data, err := f.decodeStream(cmap); err == nil {
+			font.toUnicode, font.codeLens = parseToUnicode(data)
+		}
+	}
+	if d["Subtype"] == pdfName("Type0") {
+		if len(font.codeLens) == 0 {
+			font.codeLens = []int{2}
+		}
+		return font
+	}
+	font.simple = f.simpleEncoding(d)
+	if len(font.codeLens) == 0 {
+		font.codeLens = []int{1}
+	}
+	return font
+}
+
+func (font *pdfFont) decode(s []byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		matched := false
+		if font.toUnicode != nil {
+			for _, n := range font.codeLens {
+				if i+n <= len(s) {
+					if text, ok := font.toUnicode[string(s[i:i+n])]; ok {
+						sb.WriteString(text)
+						i += n
+						matched = true
+						break
+					}
+				}
+			}
+		}
+		if matched {
+			continue
+		}
+		if font.simple != nil {
+			if r := font.simple[s[i]]; r != 0 {
+				sb.WriteRune(r)
+			}
+			i++
+			continue
+		}
+		i += font.codeLens[0]
+	}
+	return sb.String()
+}
+
+// parseToUnicode reads the bfchar and bfrange mappings of a ToUnicode CMap
+func parseToUnicode(data []byte) (map[string]string, []int) {
+	m := make(map[string]string)
+	lens := make(map[int]bool)
+	l := &pdfLexer{data: data}
+	var operands []interface{}
+	for {
+		tok, err := l.token()
+		if err != nil {
+			break
+		}
+		kw, ok := tok.(pdfKeyword)
+		if !ok {
+			if val, err := l.objectFrom(tok); err == nil {
+				operands = append(operands, val)
+			}
+			continue
+		}
+		switch kw {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				if lo, ok := operands[i].(pdfString); ok && len(lo) > 0 {
+					lens[len(lo)] = true
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					m[string(src)] = utf16BE(dst)
+					lens[len(src)] = true
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 || len(lo) != len(hi) || len(lo) == 0 || len(lo) > 4 {
+					continue
+				}
+				lens[len(lo)] = true
+				start, end := codeValue(lo), codeValue(hi)
+				if end < start || end-start > 0xFFFF {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case pdfString:
+					base := []byte(dst)
+					for code := start; code <= end; code++ {
+						m[string(codeBytes(code, len(lo)))] = utf16BE(base)
+						base = incrementLast(base)
+					}
+				case pdfArray:
+					for k, item := range dst {
+						if s, ok := item.(pdfString); ok && start+uint32(k) <= end {
+							m[string(codeBytes(start+uint32(k), len(lo)))] = utf16BE(s)
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+
+	var codeLens []int
+	for n := range lens {
+		codeLens = append(codeLens, n)
+	}
+	sort.Ints(codeLens)
+	return m, codeLens
+}
+
+func codeValue(b []byte) uint32 {
+	var v uint32
+	for _, c := range b {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
+
+func codeBytes(v uint32, n int) []byte {
+	out := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		out[i] = byte(v)
+		v >>= 8
+	}
+	return out
+}
+
+// incrementLast returns a copy of b with its last byte incremented, as bfrange destinations step
+func incrementLast(b []byte) []byte {
+	out := append([]byte(nil), b...)
+	if len(out) > 0 {
+		out[len(out)-1]++
+	}
+	return out
+}
+
+func utf16BE(b []byte) string {
+	if len(b)%2 == 1 {
+		return string(b)
+	}
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+	}
+	return string(utf16.Decode(units))
+}
+
+// simpleEncoding builds the code-to-rune table of a simple font from its base encoding
+// and /Differences
+func (f *pdfFile) simpleEncoding(font pdfDict) *[256]rune {
+	table := winAnsi
+	var differences pdfArray
+	switch enc := f.resolve(font["Encoding"]).(type) {
+	case pdfName:
+		table = *baseEncoding(enc)
+	case pdfDict:
+		if base, ok := enc["BaseEncoding"].(pdfName); ok {
+			table = *baseEncoding(base)
+		}
+		differences = f.array(enc["Differences"])
+	}
+	code := 0
+	for _, item := range differences {
+		switch v := f.resolve(item).(type) {
+		case float64:
+			code = int(v)
+		case pdfName:
+			if code >= 0 && code < 256 {
+				if r, ok := glyphRune(string(v)); ok {
+					table[code] = r
+				}
+			}
+			code++
+		}
+	}
+	return &table
+}
+
+func baseEncoding(name pdfName) *[256]rune {
+	switch name {
+	case "MacRomanEncoding":
+		return &macRoman
+	case "StandardEncoding":
+		return &standard
+	}
+	return &winAnsi
+}
+
+var winAnsi, macRoman, standard [256]rune
+
+// glyphNames maps glyph names to runes; single-letter names map to themselves
+var glyphNames = map[string]rune{}
+
+func init() {
+	ascii := strings.Fields(`space exclam quotedbl numbersign dollar percent ampersand quotesingle
+		parenleft parenright asterisk plus comma hyphen period slash zero one two three four five
+		six seven eight nine colon semicolon less equal greater question at`)
+	for i, name := range ascii {
+		glyphNames[name] = rune(0x20 + i)
+	}
+	for i, name := range strings.Fields(`bracketleft backslash bracketright asciicircum underscore grave`) {
+		glyphNames[name] = rune(0x5B + i)
+	}
+	for i, name := range strings.Fields(`braceleft bar braceright asciitilde`) {
+		glyphNames[name] = rune(0x7B + i)
+	}
+	latin1 := strings.Fields(`nbspace exclamdown cent sterling currency yen brokenbar section dieresis
+		copyright ordfeminine guillemotleft logicalnot sfthyphen registered macron degree plusminus
+		twosuperior threesuperior acute mu paragraph periodcentered cedilla onesuperior ordmasculine
+		guillemotright onequarter onehalf threequarters questiondown
+		Agrave Aacute Acircumflex Atilde Adieresis Aring AE Ccedilla Egrave Eacute Ecircumflex
+		Edieresis Igrave Iacute Icircumflex Idieresis Eth Ntilde Ograve Oacute Ocircumflex Otilde
+		Odieresis multiply Oslash Ugrave Uacute Ucircumflex Udieresis Yacute Thorn germandbls
+		agrave aacute acircumflex atilde adieresis aring ae ccedilla egrave eacute ecircumflex
+		edieresis igrave iacute icircumflex idieresis eth ntilde ograve oacute ocircumflex otilde
+		odieresis divide oslash ugrave uacute ucircumflex udieresis yacute thorn ydieresis`)
+	for i, name := range latin1 {
+		glyphNames[name] = rune(0xA0 + i)
+	}
+	for name, r := range map[string]rune{
+		"quoteleft": '‘', "quoteright": '’', "quotedblleft": '“', "quotedblright": '”',
+		"quotesinglbase": '‚', "quotedblbase": '„', "guilsinglleft": '‹', "guilsinglright": '›',
+		"bullet": '•', "endash": '–', "emdash": '—', "ellipsis": '…', "dagger": '†',
+		"daggerdbl": '‡', "trademark": '™', "perthousand": '‰', "Euro": '€', "minus": '−',
+		"fi": 'ﬁ', "fl": 'ﬂ', "ff": 'ﬀ', "ffi": 'ﬃ', "ffl": 'ﬄ', "OE": 'Œ', "oe": 'œ',
+		"Scaron": 'Š', "scaron": 'š', "Zcaron": 'Ž', "zcaron": 'ž', "Ydieresis": 'Ÿ',
+		"florin": 'ƒ', "circumflex": 'ˆ', "tilde": '˜', "dotlessi": 'ı', "Lslash": 'Ł',
+		"lslash": 'ł', "fraction": '⁄', "space.alt": ' ', "hyphen.alt": '-', "nonbreakingspace": ' ',
+		"quotesinglleft": '‘', "middot": '·', "softhyphen": '­',
+	} {
+		glyphNames[name] = r
+	}
+
+	for c := 0x20; c < 0x7F; c++ {
+		winAnsi[c] = rune(c)
+		macRoman[c] = rune(c)
+		standard[c] = rune(c)
+	}
+	for c := 0xA0; c <= 0xFF; c++ {
+		winAnsi[c] = rune(c)
+	}
+	for i, r := range []rune("€\x00‚ƒ„…†‡ˆ‰Š‹Œ\x00Ž\x00\x00‘’“”•–—˜™š›œ\x00žŸ") {
+		winAnsi[0x80+i] = r
+	}
+	for i, r := range []rune("ÄÅÇÉÑÖÜáàâäãåçéèêëíìîïñóòôöõúùûü†°¢£§•¶ß®©™´¨≠ÆØ∞±≤≥¥µ∂∑∏π∫ªºΩæø¿¡¬√ƒ≈∆«»… ÀÃÕŒœ–—“”‘’÷◊ÿŸ⁄€‹›ﬁﬂ‡·‚„‰ÂÊÁËÈÍÎÏÌÓÔÒÚÛÙıˆ˜¯˘˙˚¸˝˛ˇ") {
+		macRoman[0x80+i] = r
+	}
+	standard['\''] = '’'
+	standard['`'] = '‘'
+	for c := 0xA0; c <= 0xFF; c++ {
+		standard[c] = winAnsi[c]
+	}
+}
+
+// glyphRune maps a glyph name to a rune, including uniXXXX and uXXXX[XX] names
+func glyphRune(name string) (rune, bool) {
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i] // Variants such as "a.sc"
+	}
+	if r, ok := glyphNames[name]; ok {
+		return r, true
+	}
+	if len(name) == 1 {
+		return rune(name[0]), true
+	}
+	if strings.HasPrefix(name, "uni") && len(name) >= 7 {
+		if v, err := strconv.ParseUint(name[3:7], 16, 32); err == nil {
+			return rune(v), true
+		}
+	}
+	if strings.HasPrefix(name, "u") && len(name) >= 5 && len(name) <= 7 {
+		if v, err := strconv.ParseUint(name[1:], 16, 32); err == nil {
+			return rune(v), true
+		}
+	}
+	return 0, false
+}
+
+// outline converts the document outline (bookmarks) into sections. A section starts where
+// its title appears on its target page, or at the top of the page.
+func (f *pdfFile) outline(catalog pdfDict, pageNumbers map[int]int, doc *Document) []Section {
+	if catalog == nil {
+		return nil
+	}
+	root := f.dict(catalog["Outlines"])
+	if root == nil {
+		return nil
+	}
+
+	var sections []Section
+	visited := make(map[int]bool)
+	var walk func(item interface{}, level int)
+	walk = func(item interface{}, level int) {
+		for steps := 0; item != nil && level <= maxPDFDepth && steps < 10000; steps++ {
+			ref, ok := item.(pdfRef)
+			if !ok || visited[ref.num] {
+				return
+			}
+			visited[ref.num] = true
+			d := f.dict(item)
+			if d == nil {
+				return
+			}
+			title := strings.Join(strings.Fields(pdfTextString(f.resolve(d["Title"]))), " ")
+			if title != "" {
+				page := pageNumbers[f.destPage(catalog, d)]
+				sections = append(sections, Section{Title: title, Level: level, Page: page, Offset: sectionOffset(doc, page, title)})
+			}
+			walk(d["First"], level+1)
+			item = d["Next"]
+		}
+	}
+	walk(root["First"], 1)
+
+	sort.SliceStable(sections, func(i, j int) bool { return sections[i].Offset < sections[j].Offset })
+	for i := range sections {
+		end := len(doc.Text)
+		if i+1 < len(sections) {
+			end = sections[i+1].Offset
+		}
+		if end > sections[i].Offset {
+			sections[i].Text = strings.TrimSpace(doc.Text[sections[i].Offset:end])
+		}
+	}
+	return sections
+}
+
+// destPage returns the object number of the page an outline item points to
+func (f *pdfFile) destPage(catalog pdfDict, item pdfDict) int {
+	dest := item["Dest"]
+	if dest == nil {
+		if action := f.dict(item["A"]); action != nil && action["S"] == pdfName("GoTo") {
+			dest = action["D"]
+		}
+	}
+	for i := 0; i < 4; i++ {
+		switch d := f.resolve(dest).(type) {
+		case pdfArray:
+			if len(d) > 0 {
+				if ref, ok := d[0].(pdfRef); ok {
+					return ref.num
+				}
+			}
+			return 0
+		case pdfDict:
+			dest = d["D"]
+		case pdfName:
+			dest = f.namedDest(catalog, string(d))
+		case pdfString:
+			dest = f.namedDest(catalog, string(d))
+		default:
+			return 0
+		}
+	}
+	return 0
+}
+
+// namedDest looks up a destination in the catalog's Dests dictionary or Names/Dests tree
+func (f *pdfFile) namedDest(catalog pdfDict, name string) interface{} {
+	if dests := f.dict(catalog["Dests"]); dests != nil {
+		if d, ok := dests[pdfName(name)]; ok {
+			return d
+		}
+	}
+	names := f.dict(catalog["Names"])
+	if names == nil {
+		return nil
+	}
+	var search func(node pdfDict, depth int) interface{}
+	search = func(node pdfDict, depth int) interface{} {
+		if node == nil || depth > maxPDFDepth {
+			return nil
+		}
+		pairs := f.array(node["Names"])
+		for i := 0; i+1 < len(pairs); i += 2 {
+			if key, ok := f.resolve(pairs[i]).(pdfString); ok && string(key) == name {
+				return pairs[i+1]
+			}
+		}
+		for _, kid := range f.array(node["Kids"]) {
+			if found := search(f.dict(kid), depth+1); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	return search(f.dict(names["Dests"]), 0)
+}
+
+// pdfTextString decodes a text string, which is UTF-16BE with a BOM or PDFDocEncoding
+func pdfTextString(v interface{}) string {
+	s, ok := v.(pdfString)
+	if !ok {
+		return ""
+	}
+	if len(s) >= 2 && s[0] == 0xFE && s[1] == 0xFF {
+		return utf16BE(s[2:])
+	}
+	var sb strings.Builder
+	for _, c := range s {
+		if r := winAnsi[c]; r != 0 {
+			sb.WriteRune(r)
+		} else if c >= 0x20 || c == '\n' || c == '\t' {
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// sectionOffset finds a heading on its page, ignoring case and whitespace differences
+func sectionOffset(doc *Document, page int, title string) int {
+	if page < 1 || page > len(doc.Pages) {
+		return len(doc.Text)
+	}
+	p := doc.Pages[page-1]
+	start := p.Offset
+	end := len(doc.Text)
+	if page < len(doc.Pages) {
+		end = doc.Pages[page].Offset
+	}
+	words := strings.Fields(title)
+	if len(words) == 0 {
+		return start
+	}
+	re, err := regexp.Compile(`(?is)` + strings.Join(quoteAll(words), `\s+`))
+	if err != nil {
+		return start
+	}
+	if loc := re.FindStringIndex(doc.Text[start:end]); loc != nil {
+		return start + loc[0]
+	}
+	return start
+}
+
+func quoteAll(words []string) []string {
+	out := make([]string, len(words))
+	for i, w := range words {
+		out[i] = regexp.QuoteMeta(w)
+	}
+	return out
+}
@@ -0,0 +1,250 @@
+package document
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF assembles a PDF whose objects are numbered from 1 in order, with a valid xref
+// table and object 1 as the catalog
+func buildPDF(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// pdfStreamObject formats a stream object with a correct /Length
+func pdfStreamObject(dict string, data []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+const helvetica = "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"
+
+// singlePagePDF is a one-page PDF using Helvetica as /F1, with content as its page stream
+func singlePagePDF(contentDict string, content []byte) []byte {
+	return buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>",
+		helvetica,
+		pdfStreamObject(contentDict, content),
+	)
+}
+
+func extractTestPDF(t *testing.T, data []byte) *Document {
+	t.Helper()
+	doc, err := Extract(data, "test.pdf", "application/pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Format != FormatPDF {
+		t.Fatalf("format = %q, want pdf", doc.Format)
+	}
+	return doc
+}
+
+func TestPDFSimpleText(t *testing.T) {
+	doc := extractTestPDF(t, singlePagePDF("", []byte(
+		"BT /F1 12 Tf 72 720 Td (Hello, World!) Tj 0 -14 Td (Parens \\(nested\\) and a \\\\ backslash) Tj\n"+
+			"T* (caf\\351) Tj ET")))
+	want := "Hello, World!\nParens (nested) and a \\ backslash\ncafé"
+	if doc.Text != want {
+		t.Errorf("text = %q, want %q", doc.Text, want)
+	}
+	if len(doc.Pages) != 1 || doc.Pages[0].Number != 1 || doc.Pages[0].Offset != 0 || doc.Pages[0].Text != want {
+		t.Errorf("pages = %+v", doc.Pages)
+	}
+}
+
+func TestPDFFlateStream(t *testing.T) {
+	content := []byte("BT /F1 12 Tf 72 720 Td (Compressed text) Tj ET")
+
+	var zbuf bytes.Buffer
+	zw := zlib.NewWriter(&zbuf)
+	zw.Write(content)
+	zw.Close()
+
+	// Some writers emit raw deflate data without the zlib header
+	var rawBuf bytes.Buffer
+	fw, _ := flate.NewWriter(&rawBuf, flate.BestCompression)
+	fw.Write(content)
+	fw.Close()
+
+	for name, data := range map[string][]byte{"zlib": zbuf.Bytes(), "raw deflate": rawBuf.Bytes()} {
+		t.Run(name, func(t *testing.T) {
+			doc := extractTestPDF(t, singlePagePDF("/Filter /FlateDecode", data))
+			if doc.Text != "Compressed text" {
+				t.Errorf("text = %q", doc.Text)
+			}
+		})
+	}
+
+	t.Run("filter array", func(t *testing.T) {
+		hex := []byte(fmt.Sprintf("%x>", zbuf.Bytes()))
+		doc := extractTestPDF(t, singlePagePDF("/Filter [/ASCIIHexDecode /FlateDecode]", hex))
+		if doc.Text != "Compressed text" {
+			t.Errorf("text = %q", doc.Text)
+		}
+	})
+}
+
+func TestPDFMultiPageOffsets(t *testing.T) {
+	page := func(content string) string {
+		return pdfStreamObject("", []byte("BT /F1 12 Tf 72 720 Td "+content+" ET"))
+	}
+	// Pages inherit resources from the page tree; the second page is blank and the
+	// outline points at the third
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R /Outlines 10 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R 5 0 R] /Count 3 /Resources << /Font << /F1 6 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 7 0 R >>",
+		"<< /Type /Page /Parent 2 0 R >>",
+		"<< /Type /Page /Parent 2 0 R /Contents [8 0 R 9 0 R] >>",
+		helvetica,
+		page("(First page) Tj"),
+		page("(Intro text) Tj 0 -14 Td (Chapter Two) Tj"),
+		page("(Body of chapter two) Tj"),
+		"<< /Type /Outlines /First 11 0 R /Last 11 0 R /Count 1 >>",
+		"<< /Title (Chapter Two) /Parent 10 0 R /Dest [5 0 R /XYZ 0 720 0] >>",
+	)
+	doc := extractTestPDF(t, data)
+
+	wantPages := []string{"First page", "", "Intro text\nChapter Two\nBody of chapter two"}
+	if len(doc.Pages) != len(wantPages) {
+		t.Fatalf("%d pages, want %d: %+v", len(doc.Pages), len(wantPages), doc.Pages)
+	}
+	for i, p := range doc.Pages {
+		if p.Number != i+1 || p.Text != wantPages[i] {
+			t.Errorf("page %d = %+v, want text %q", i+1, p, wantPages[i])
+		}
+		if !strings.HasPrefix(doc.Text[p.Offset:], wantPages[i]) {
+			t.Errorf("page %d offset %d points at %q", p.Number, p.Offset, doc.Text[p.Offset:])
+		}
+	}
+
+	if len(doc.Sections) != 1 {
+		t.Fatalf("sections = %+v, want the outline entry", doc.Sections)
+	}
+	s := doc.Sections[0]
+	if s.Title != "Chapter Two" || s.Level != 1 || s.Page != 3 || s.Text != "Chapter Two\nBody of chapter two" {
+		t.Errorf("section = %+v", s)
+	}
+	if !strings.HasPrefix(doc.Text[s.Offset:], "Chapter Two") {
+		t.Errorf("section offset %d points at %q", s.Offset, doc.Text[s.Offset:])
+	}
+}
+
+func TestPDFTextPositioning(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"TJ kerning joins glyphs", "[(W) 120 (or) -80 (ld)] TJ", "World"},
+		{"TJ wide gap is a space", "[(Hello) -250 (world)] TJ", "Hello world"},
+		{"TJ gaps do not double spaces", "[(Hello ) -900 (world)] TJ", "Hello world"},
+		{"Td on the same line", "(left) Tj 200 0 Td (right) Tj", "left right"},
+		{"Td to a new line", "(top) Tj 0 -14 Td (bottom) Tj", "top\nbottom"},
+		{"Tm on the same baseline", "1 0 0 1 72 700 Tm (a) Tj 1 0 0 1 300 700 Tm (b) Tj", "a b"},
+		{"Tm to a new baseline", "1 0 0 1 72 700 Tm (a) Tj 1 0 0 1 72 680 Tm (b) Tj", "a\nb"},
+		{"quote operators", "(one) Tj (two) ' 0 0 (three) \"", "one\ntwo\nthree"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := []byte("BT /F1 12 Tf " + tt.content + " ET")
+			if doc := extractTestPDF(t, singlePagePDF("", content)); doc.Text != tt.want {
+				t.Errorf("text = %q, want %q", doc.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestPDFToUnicodeCMap(t *testing.T) {
+	cmap := []byte(`/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+1 beginbfchar <0001> <0048> endbfchar
+2 beginbfrange
+<0002> <0003> <0065>
+<0004> <0005> [<006C> <006F>]
+endbfrange
+2 beginbfchar <0006> <D83DDE00> <0008> <FB01> endbfchar
+endcmap
+end end`)
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F0 4 0 R >> >> /Contents 5 0 R >>",
+		"<< /Type /Font /Subtype /Type0 /BaseFont /Custom /Encoding /Identity-H /ToUnicode 6 0 R >>",
+		// 0007 has no mapping and is dropped; 0003 is the second code of the range starting
+		// at e; the fi ligature 0008 maps to is spelled out
+		pdfStreamObject("", []byte("BT /F0 12 Tf <00010002000400040005> Tj 0 -14 Td <00030007000600030008> Tj ET")),
+		pdfStreamObject("", cmap),
+	)
+	doc := extractTestPDF(t, data)
+	if want := "Hello\nf😀ffi"; doc.Text != want {
+		t.Errorf("text = %q, want %q", doc.Text, want)
+	}
+}
+
+func TestPDFMalformedInput(t *testing.T) {
+	content := []byte("BT /F1 12 Tf 72 720 Td (Still readable) Tj ET")
+	valid := singlePagePDF("", content)
+
+	// Every truncation must fail cleanly or return text, never panic
+	for n := 0; n < len(valid); n++ {
+		Extract(valid[:n], "test.pdf", "")
+	}
+
+	// Objects are found by scanning, so a missing xref table and trailer are tolerated
+	xref := bytes.Index(valid, []byte("xref"))
+	if doc, err := Extract(valid[:xref], "test.pdf", ""); err != nil || doc.Text != "Still readable" {
+		t.Errorf("without xref: %v, %v", doc, err)
+	}
+
+	// A stream whose /Length is wrong is read up to endstream
+	wrongLength := bytes.Replace(valid, []byte(fmt.Sprintf("/Length %d", len(content))), []byte("/Length 4000"), 1)
+	if doc, err := Extract(wrongLength, "test.pdf", ""); err != nil || doc.Text != "Still readable" {
+		t.Errorf("wrong /Length: %v, %v", doc, err)
+	}
+
+	errorCases := map[string][]byte{
+		"not a PDF":   []byte("%PS-Adobe-3.0\n"),
+		"header only": []byte("%PDF-1.7\n"),
+		"cyclic page tree": buildPDF(
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [2 0 R] /Count 1 >>",
+		),
+		"encrypted": append(valid[:xref:xref], "trailer\n<< /Root 1 0 R /Encrypt << /Filter /Standard >> >>\n"...),
+	}
+	for name, data := range errorCases {
+		if doc, err := Extract(data, "test.pdf", ""); err == nil {
+			t.Errorf("%s: expected an error, got %q", name, doc.Text)
+		}
+	}
+
+	// Undecodable content is skipped rather than failing the document
+	for name, data := range map[string][]byte{
+		"corrupt flate":      singlePagePDF("/Filter /FlateDecode", []byte("not compressed")),
+		"unsupported filter": singlePagePDF("/Filter /JBIG2Decode", []byte("\x00\x01")),
+		"unbalanced content": singlePagePDF("", []byte("BT /F1 12 Tf [(open) <4869 (oops) ] ) >> TJ")),
+	} {
+		if _, err := Extract(data, "test.pdf", ""); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
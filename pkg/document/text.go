@@ -0,0 +1,94 @@
+package document
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+)
+
+// extractMarkdown keeps the Markdown source as the text and records ATX headings
+// ("## Title") outside fenced code blocks as sections
+func extractMarkdown(data []byte) *Document {
+	text := normalizeNewlines(string(data))
+	var sections []Section
+	inFence := false
+	offset := 0
+	for _, line := range strings.SplitAfter(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		} else if !inFence {
+			if level, title, ok := markdownHeading(trimmed); ok {
+				sections = append(sections, Section{Title: title, Level: level, Offset: offset})
+			}
+		}
+		offset += len(line)
+	}
+	for i := range sections {
+		end := len(text)
+		if i+1 < len(sections) {
+			end = sections[i+1].Offset
+		}
+		sections[i].Text = strings.TrimSpace(text[sections[i].Offset:end])
+	}
+	return &Document{Text: text, Sections: sections}
+}
+
+// markdownHeading parses an ATX heading line
+func markdownHeading(line string) (int, string, bool) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(line) && line[level] != ' ' && line[level] != '\t') {
+		return 0, "", false
+	}
+	title := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(line[level:]), "#"))
+	if title == "" {
+		return 0, "", false
+	}
+	return level, title, true
+}
+
+// extractCSV renders the rows as a Markdown table, which LLMs read more reliably than raw CSV
+func extractCSV(data []byte) (*Document, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	width := 0
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	var sb strings.Builder
+	for i, row := range rows {
+		cells := make([]string, width)
+		for j := range cells {
+			if j < len(row) {
+				cells[j] = strings.ReplaceAll(strings.ReplaceAll(strings.TrimSpace(row[j]), "|", `\|`), "\n", " ")
+			}
+		}
+		sb.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+		if i == 0 {
+			sb.WriteString(strings.Repeat("| --- ", width) + "|\n")
+		}
+	}
+	return &Document{Text: sb.String()}, nil
+}
+
+// extractJSON pretty-prints the document so nested values end up on their own lines
+func extractJSON(data []byte) (*Document, error) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), "", "  "); err != nil {
+		return nil, err
+	}
+	return &Document{Text: buf.String()}, nil
+}
@@ -3,6 +3,7 @@ package dsl
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"gopkg.in/yaml.v3"
 )
@@ -23,6 +24,19 @@ func Parse(filename string) (*WorkflowDefinition, error) {
 	if len(workflow.Nodes) == 0 {
 		return nil, fmt.Errorf("workflow must have at least one node")
 	}
+	for name, def := range workflow.Env {
+		switch def.Type {
+		case "", "string", "number", "boolean":
+		default:
+			return nil, fmt.Errorf("env %s: unknown type '%s'", name, def.Type)
+		}
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve workflow directory: %w", err)
+	}
+	workflow.SetDir(dir)
 
 	return &workflow, nil
 }
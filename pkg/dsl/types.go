@@ -2,11 +2,85 @@ package dsl
 
 // WorkflowDefinition represents the top-level structure of the DSL
 type WorkflowDefinition struct {
-	Name    string           `yaml:"name"`
-	Version string           `yaml:"version"`
-	Memory  MemoryDefinition `yaml:"memory"`
-	Nodes   []NodeDefinition `yaml:"nodes"`
-	Edges   []EdgeDefinition `yaml:"edges"`
+	Name          string                      `yaml:"name"`
+	Version       string                      `yaml:"version"`
+	Memory        MemoryDefinition            `yaml:"memory"`
+	Env           map[string]EnvVarDefinition `yaml:"env,omitempty"`
+	ToolProviders []ToolProviderDefinition    `yaml:"tool_providers,omitempty"`
+	Egress        *EgressDefinition           `yaml:"egress,omitempty"`
+	Nodes         []NodeDefinition            `yaml:"nodes"`
+	Edges         []EdgeDefinition            `yaml:"edges"`
+	Dir           string                      `yaml:"-" json:"-"` // Directory of the file the workflow was loaded from
+}
+
+// SetDir records the directory the workflow was loaded from on the workflow and its nodes,
+// so relative paths in node configs resolve against the file rather than the process CWD
+func (wf *WorkflowDefinition) SetDir(dir string) {
+	wf.Dir = dir
+	for i := range wf.Nodes {
+		wf.Nodes[i].Dir = dir
+	}
+	for i := range wf.ToolProviders {
+		wf.ToolProviders[i].Dir = dir
+	}
+}
+
+// EnvVarDefinition declares a workflow environment variable, addressable as {{ env.NAME }}.
+// Values can be overridden per run; a variable without a default must be supplied.
+type EnvVarDefinition struct {
+	Type        string      `yaml:"type"` // "string" (default), "number" or "boolean"
+	Default     interface{} `yaml:"default,omitempty"`
+	Description string      `yaml:"description,omitempty"`
+}
+
+// EgressDefinition restricts where nodes and tools may send HTTP requests.
+// Private, loopback and link-local addresses are blocked unless allowed here.
+type EgressDefinition struct {
+	AllowHosts       []string `yaml:"allow_hosts,omitempty"` // Exact hosts or "*.example.com"
+	DenyHosts        []string `yaml:"deny_hosts,omitempty"`
+	AllowCIDRs       []string `yaml:"allow_cidrs,omitempty"`
+	DenyCIDRs        []string `yaml:"deny_cidrs,omitempty"`
+	AllowPrivate     bool     `yaml:"allow_private,omitempty"`
+	MaxResponseBytes int64    `yaml:"max_response_bytes,omitempty"`
+}
+
+// ToolProviderDefinition declares a source of tools to register before the run
+type ToolProviderDefinition struct {
+	ID   string `yaml:"id"`   // Provider ID referenced by Tool nodes as provider_id
+	Type string `yaml:"type"` // "openapi", "mcp" or "search"
+
+	// OpenAPI
+	Spec    string          `yaml:"spec,omitempty"`
+	BaseURL string          `yaml:"base_url,omitempty"` // Overrides the spec's first server URL
+	Auth    *AuthDefinition `yaml:"auth,omitempty"`
+
+	// MCP: set Command for a stdio server or URL for a streamable HTTP server
+	Command []string          `yaml:"command,omitempty"`
+	Env     map[string]string `yaml:"env,omitempty"`
+	URL     string            `yaml:"url,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+
+	// Search: registers a "search" tool served by Backend ("serpapi", "searxng", "bing", "tavily"
+	// or "fixture"). URL overrides the backend endpoint; for "fixture" it is the file path,
+	// relative to the workflow file.
+	Backend string `yaml:"backend,omitempty"`
+	APIKey  string `yaml:"api_key,omitempty"`
+	Engine  string `yaml:"engine,omitempty"`
+	Count   int    `yaml:"count,omitempty"`
+
+	Dir string `yaml:"-" json:"-"` // See WorkflowDefinition.SetDir
+}
+
+// AuthDefinition configures credentials sent with every request of a tool provider.
+// Values may reference environment variables as ${NAME}.
+type AuthDefinition struct {
+	Type     string `yaml:"type"` // "bearer", "basic" or "api_key"
+	Token    string `yaml:"token,omitempty"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	Name     string `yaml:"name,omitempty"` // Header or query parameter name for api_key
+	In       string `yaml:"in,omitempty"`   // "header" (default) or "query" for api_key
+	Value    string `yaml:"value,omitempty"`
 }
 
 // MemoryDefinition defines the schema for global memory
@@ -21,6 +95,14 @@ type NodeDefinition struct {
 	Config  map[string]interface{} `yaml:"config"`
 	Inputs  map[string]interface{} `yaml:"inputs"` // Key: InputName, Value: Template/Reference/Complex
 	Outputs map[string]string      `yaml:"outputs"`
+	Cache   *CacheDefinition       `yaml:"cache,omitempty"` // Opt-in result caching for deterministic nodes
+	Dir     string                 `yaml:"-" json:"-"`      // See WorkflowDefinition.SetDir
+}
+
+// CacheDefinition configures result caching for a node
+type CacheDefinition struct {
+	Enabled bool   `yaml:"enabled"`
+	TTL     string `yaml:"ttl,omitempty"` // Go duration, e.g. "1h"; empty means no expiry
 }
 
 // EdgeDefinition defines a connection between nodes
@@ -0,0 +1,306 @@
+// Package egress provides the outbound HTTP client shared by nodes and tools.
+// It enforces a Policy on every request, including redirects: hosts are checked against
+// allow/deny lists before connecting, and the resolved IP address is checked when dialing,
+// so a hostname cannot smuggle a request to a private or metadata address.
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"dify-vnext-go/pkg/dsl"
+)
+
+// DefaultMaxResponseBytes caps response bodies when a policy does not set a limit
+const DefaultMaxResponseBytes = 64 << 20 // 64MB
+
+// ErrResponseTooLarge is returned while reading a body that exceeds the policy limit
+var ErrResponseTooLarge = errors.New("egress: response exceeds size limit")
+
+// Policy decides which destinations outbound requests may reach.
+//
+// Hosts match exactly or, with a "*." prefix, any subdomain. A request is denied when its host
+// is in DenyHosts, or AllowHosts is set and the host is not in it. The resolved IP is then
+// denied when it is in DenyCIDRs, or when it is private, loopback, link-local or unspecified
+// and neither AllowPrivate is set nor the IP is in AllowCIDRs.
+type Policy struct {
+	AllowHosts       []string
+	DenyHosts        []string
+	AllowCIDRs       []*net.IPNet
+	DenyCIDRs        []*net.IPNet
+	AllowPrivate     bool
+	MaxResponseBytes int64 // 0 means DefaultMaxResponseBytes, negative disables the cap
+
+	once      sync.Once
+	transport *http.Transport
+}
+
+// Default is the policy used when none is configured: public addresses only
+var Default = &Policy{}
+
+// DeniedError reports a request blocked by the policy
+type DeniedError struct {
+	URL    string
+	Host   string
+	IP     string
+	Reason string
+}
+
+func (e *DeniedError) Error() string {
+	target := e.Host
+	if e.IP != "" && e.IP != e.Host {
+		target = fmt.Sprintf("%s (%s)", e.Host, e.IP)
+	}
+	return fmt.Sprintf("egress denied: %s: %s", target, e.Reason)
+}
+
+// ParseCIDRs parses CIDR blocks; bare IP addresses are treated as single-address blocks
+func ParseCIDRs(values []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP or CIDR: %s", v)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			v = fmt.Sprintf("%s/%d", v, bits)
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR: %s", v)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// CheckHost applies the host allow/deny lists
+func (p *Policy) CheckHost(host string) error {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if matchHost(p.DenyHosts, host) {
+		return &DeniedError{Host: host, Reason: "host is in the deny list"}
+	}
+	if len(p.AllowHosts) > 0 && !matchHost(p.AllowHosts, host) {
+		return &DeniedError{Host: host, Reason: "host is not in the allow list"}
+	}
+	// Literal IPs can be rejected before dialing
+	if ip := net.ParseIP(host); ip != nil {
+		return p.CheckIP(host, ip)
+	}
+	return nil
+}
+
+// CheckIP applies the CIDR lists and the private address rule to a resolved address
+func (p *Policy) CheckIP(host string, ip net.IP) error {
+	if containsIP(p.DenyCIDRs, ip) {
+		return &DeniedError{Host: host, IP: ip.String(), Reason: "address is in a denied range"}
+	}
+	if containsIP(p.AllowCIDRs, ip) || p.AllowPrivate {
+		return nil
+	}
+	if isInternal(ip) {
+		return &DeniedError{Host: host, IP: ip.String(), Reason: "private, loopback and link-local addresses are blocked"}
+	}
+	return nil
+}
+
+func matchHost(patterns []string, host string) bool {
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if p == host {
+			return true
+		}
+		if strings.HasPrefix(p, "*.") && strings.HasSuffix(host, p[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+var extraInternal, _ = ParseCIDRs([]string{
+	"100.64.0.0/10", // Carrier-grade NAT
+	"0.0.0.0/8",
+	"64:ff9b::/96", // NAT64 can map to internal IPv4 addresses
+})
+
+func isInternal(ip net.IP) bool {
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() ||
+		containsIP(extraInternal, ip)
+}
+
+func (p *Policy) maxResponseBytes() int64 {
+	if p.MaxResponseBytes == 0 {
+		return DefaultMaxResponseBytes
+	}
+	return p.MaxResponseBytes
+}
+
+func (p *Policy) sharedTransport() *http.Transport {
+	p.once.Do(func() {
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			// Control runs after DNS resolution for every address dialed
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				ip := net.ParseIP(host)
+				if ip == nil {
+					return &DeniedError{Host: host, Reason: "unresolved address"}
+				}
+				return p.CheckIP(host, ip)
+			},
+		}
+		p.transport = &http.Transport{
+			// Environment proxies are ignored: the proxy, not the target, would be checked
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		}
+	})
+	return p.transport
+}
+
+// Client returns an HTTP client that enforces the policy. onDeny, if set, is called for
+// every denied request, including denied redirects.
+func (p *Policy) Client(onDeny func(*DeniedError)) *http.Client {
+	return &http.Client{
+		Transport: &roundTripper{policy: p, onDeny: onDeny},
+	}
+}
+
+type roundTripper struct {
+	policy *Policy
+	onDeny func(*DeniedError)
+}
+
+func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := rt.policy.CheckHost(req.URL.Hostname()); err != nil {
+		return nil, rt.denied(req, err)
+	}
+	resp, err := rt.policy.sharedTransport().RoundTrip(req)
+	if err != nil {
+		var denied *DeniedError
+		if errors.As(err, &denied) {
+			return nil, rt.denied(req, denied)
+		}
+		return nil, err
+	}
+	if limit := rt.policy.maxResponseBytes(); limit > 0 {
+		if resp.ContentLength > limit {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%w (%d > %d bytes)", ErrResponseTooLarge, resp.ContentLength, limit)
+		}
+		resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: limit}
+	}
+	return resp, nil
+}
+
+func (rt *roundTripper) denied(req *http.Request, err error) error {
+	var denied *DeniedError
+	if !errors.As(err, &denied) {
+		return err
+	}
+	// Drop credentials and query strings from what gets logged
+	u := *req.URL
+	u.User = nil
+	u.RawQuery = ""
+	denied.URL = u.String()
+	if denied.Host == "" || net.ParseIP(denied.Host) != nil {
+		denied.Host = req.URL.Hostname()
+	}
+	if rt.onDeny != nil {
+		rt.onDeny(denied)
+	}
+	return denied
+}
+
+// limitedBody fails reads once more than remaining bytes have been returned
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, ErrResponseTooLarge
+	}
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n, ErrResponseTooLarge
+	}
+	return n, err
+}
+
+type clientKey struct{}
+
+// WithClient attaches the client outbound requests made under ctx should use
+func WithClient(ctx context.Context, c *http.Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, c)
+}
+
+// ClientFrom returns the client attached to ctx, or a client enforcing the Default policy
+func ClientFrom(ctx context.Context) *http.Client {
+	if ctx != nil {
+		if c, ok := ctx.Value(clientKey{}).(*http.Client); ok {
+			return c
+		}
+	}
+	return Default.Client(nil)
+}
+
+// FromDefinition builds a policy from a workflow's egress section; nil yields Default
+func FromDefinition(def *dsl.EgressDefinition) (*Policy, error) {
+	if def == nil {
+		return Default, nil
+	}
+	allow, err := ParseCIDRs(def.AllowCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := ParseCIDRs(def.DenyCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	return &Policy{
+		AllowHosts:       def.AllowHosts,
+		DenyHosts:        def.DenyHosts,
+		AllowCIDRs:       allow,
+		DenyCIDRs:        deny,
+		AllowPrivate:     def.AllowPrivate,
+		MaxResponseBytes: def.MaxResponseBytes,
+	}, nil
+}
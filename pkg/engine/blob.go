@@ -0,0 +1,273 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrBlobNotFound is returned for blob IDs the store does not hold
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore holds binary data outside memory and checkpoints. Blobs are content addressed:
+// the ID is the hex sha256 of the bytes, so storing the same data twice yields the same ref.
+type BlobStore interface {
+	// Put stores the data read from r; an empty mimeType is detected from the content
+	Put(r io.Reader, mimeType string) (BlobRef, error)
+	// Get opens a blob for reading
+	Get(id string) (io.ReadCloser, error)
+	// Stat returns the ref of a stored blob
+	Stat(id string) (BlobRef, error)
+	// Delete removes a blob; deleting a missing blob is not an error
+	Delete(id string) error
+}
+
+// sniffLen is how much of a blob http.DetectContentType looks at
+const sniffLen = 512
+
+// AsBlobRef converts a value passed between nodes into a BlobRef. Besides BlobRef values it
+// accepts the map form a ref takes after a round trip through JSON, e.g. when a run is
+// resumed from a file checkpoint.
+func AsBlobRef(v interface{}) (BlobRef, bool) {
+	switch t := v.(type) {
+	case BlobRef:
+		return t, t.ID != ""
+	case *BlobRef:
+		if t == nil {
+			return BlobRef{}, false
+		}
+		return *t, t.ID != ""
+	case map[string]interface{}:
+		id, _ := t["blob_id"].(string)
+		if id == "" {
+			return BlobRef{}, false
+		}
+		ref := BlobRef{ID: id}
+		ref.MimeType, _ = t["mime_type"].(string)
+		ref.Name, _ = t["name"].(string)
+		switch size := t["size"].(type) {
+		case float64:
+			ref.Size = int64(size)
+		case int64:
+			ref.Size = size
+		case int:
+			ref.Size = int64(size)
+		}
+		return ref, true
+	}
+	return BlobRef{}, false
+}
+
+// ReadBlob returns the full contents of a blob
+func ReadBlob(store BlobStore, id string) ([]byte, error) {
+	r, err := store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// validBlobID guards file paths built from IDs that may come from workflow data
+func validBlobID(id string) bool {
+	if len(id) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil && strings.ToLower(id) == id
+}
+
+type memoryBlob struct {
+	data     []byte
+	mimeType string
+}
+
+// MemoryBlobStore keeps blobs in memory for the lifetime of the process
+type MemoryBlobStore struct {
+	mu    sync.RWMutex
+	blobs map[string]memoryBlob
+}
+
+// NewMemoryBlobStore creates an empty in-memory blob store
+func NewMemoryBlobStore() *MemoryBlobStore {
+	return &MemoryBlobStore{blobs: make(map[string]memoryBlob)}
+}
+
+// Put stores the blob
+func (s *MemoryBlobStore) Put(r io.Reader, mimeType string) (BlobRef, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return BlobRef{}, fmt.Errorf("failed to read blob: %w", err)
+	}
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	sum := sha256.Sum256(data)
+	id := hex.EncodeToString(sum[:])
+
+	s.mu.Lock()
+	s.blobs[id] = memoryBlob{data: data, mimeType: mimeType}
+	s.mu.Unlock()
+	return BlobRef{ID: id, MimeType: mimeType, Size: int64(len(data))}, nil
+}
+
+// Get opens the blob
+func (s *MemoryBlobStore) Get(id string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	blob, ok := s.blobs[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrBlobNotFound, id)
+	}
+	return io.NopCloser(bytes.NewReader(blob.data)), nil
+}
+
+// Stat returns the blob's ref
+func (s *MemoryBlobStore) Stat(id string) (BlobRef, error) {
+	s.mu.RLock()
+	blob, ok := s.blobs[id]
+	s.mu.RUnlock()
+	if !ok {
+		return BlobRef{}, fmt.Errorf("%w: %s", ErrBlobNotFound, id)
+	}
+	return BlobRef{ID: id, MimeType: blob.mimeType, Size: int64(len(blob.data))}, nil
+}
+
+// Delete removes the blob
+func (s *MemoryBlobStore) Delete(id string) error {
+	s.mu.Lock()
+	delete(s.blobs, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// FileBlobStore keeps each blob as a file under dir, next to a small JSON file holding its
+// MIME type, so blobs outlive the process alongside file checkpoints
+type FileBlobStore struct {
+	dir string
+}
+
+type blobMeta struct {
+	MimeType string `json:"mime_type"`
+}
+
+// NewFileBlobStore creates a blob store in dir
+func NewFileBlobStore(dir string) (*FileBlobStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create blob dir: %w", err)
+	}
+	return &FileBlobStore{dir: dir}, nil
+}
+
+// path shards blobs by the first two hex digits to keep directories small
+func (s *FileBlobStore) path(id string) string {
+	return filepath.Join(s.dir, id[:2], id)
+}
+
+// Put streams the blob to disk, hashing it on the way
+func (s *FileBlobStore) Put(r io.Reader, mimeType string) (BlobRef, error) {
+	tmp, err := os.CreateTemp(s.dir, "blob-*.tmp")
+	if err != nil {
+		return BlobRef{}, fmt.Errorf("failed to write blob: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	head := &prefixBuffer{limit: sniffLen}
+	size, err := io.Copy(io.MultiWriter(tmp, hash, head), r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return BlobRef{}, fmt.Errorf("failed to write blob: %w", err)
+	}
+	if mimeType == "" {
+		mimeType = http.DetectContentType(head.buf)
+	}
+	ref := BlobRef{ID: hex.EncodeToString(hash.Sum(nil)), MimeType: mimeType, Size: size}
+
+	path := s.path(ref.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return BlobRef{}, fmt.Errorf("failed to write blob: %w", err)
+	}
+	meta, _ := json.Marshal(blobMeta{MimeType: mimeType})
+	if err := os.WriteFile(path+".json", meta, 0o644); err != nil {
+		return BlobRef{}, fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return BlobRef{}, fmt.Errorf("failed to write blob: %w", err)
+	}
+	return ref, nil
+}
+
+// Get opens the blob file
+func (s *FileBlobStore) Get(id string) (io.ReadCloser, error) {
+	if !validBlobID(id) {
+		return nil, fmt.Errorf("%w: %s", ErrBlobNotFound, id)
+	}
+	f, err := os.Open(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrBlobNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob: %w", err)
+	}
+	return f, nil
+}
+
+// Stat returns the blob's ref
+func (s *FileBlobStore) Stat(id string) (BlobRef, error) {
+	if !validBlobID(id) {
+		return BlobRef{}, fmt.Errorf("%w: %s", ErrBlobNotFound, id)
+	}
+	info, err := os.Stat(s.path(id))
+	if os.IsNotExist(err) {
+		return BlobRef{}, fmt.Errorf("%w: %s", ErrBlobNotFound, id)
+	}
+	if err != nil {
+		return BlobRef{}, fmt.Errorf("failed to stat blob: %w", err)
+	}
+	ref := BlobRef{ID: id, Size: info.Size()}
+	var meta blobMeta
+	if data, err := os.ReadFile(s.path(id) + ".json"); err == nil && json.Unmarshal(data, &meta) == nil {
+		ref.MimeType = meta.MimeType
+	}
+	return ref, nil
+}
+
+// Delete removes the blob file and its metadata
+func (s *FileBlobStore) Delete(id string) error {
+	if !validBlobID(id) {
+		return nil
+	}
+	if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	os.Remove(s.path(id) + ".json")
+	return nil
+}
+
+// prefixBuffer keeps the first limit bytes written to it
+type prefixBuffer struct {
+	buf   []byte
+	limit int
+}
+
+func (b *prefixBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - len(b.buf); room > 0 {
+		if len(p) < room {
+			room = len(p)
+		}
+		b.buf = append(b.buf, p[:room]...)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,229 @@
+package engine
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"dify-vnext-go/pkg/dsl"
+)
+
+// CacheStore stores node outputs keyed by a hash of the node's type, config and inputs
+type CacheStore interface {
+	// Get returns the cached outputs for key, if present and not expired
+	Get(key string) (map[string]interface{}, bool)
+	// Set stores outputs for key; a zero ttl means the entry never expires
+	Set(key string, outputs map[string]interface{}, ttl time.Duration) error
+}
+
+// CacheKey hashes everything that determines a deterministic node's outputs. Config is
+// hashed as written, so refs must hold the values of the templates it references
+// (see Engine.configRefs).
+func CacheKey(nodeType string, config, inputs, refs map[string]interface{}) (string, error) {
+	// encoding/json sorts map keys, so equal values always produce the same bytes
+	data, err := json.Marshal(map[string]interface{}{
+		"type":   nodeType,
+		"config": config,
+		"inputs": inputs,
+		"refs":   refs,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash node: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// cacheKey computes the CacheKey of a node about to run with the given inputs
+func (e *Engine) cacheKey(nodeDef *dsl.NodeDefinition, inputs map[string]interface{}) (string, error) {
+	refs := make(map[string]interface{})
+	if err := e.configRefs(nodeDef.Config, refs); err != nil {
+		return "", err
+	}
+	return CacheKey(nodeDef.Type, nodeDef.Config, inputs, refs)
+}
+
+// configRefs resolves the templates found in a node's config, so a cached result is not
+// reused after an upstream output, memory value or env variable changes or a secret is
+// rotated. Node output and memory references that do not resolve here are left out: they
+// belong to a sub-workflow and are resolved by the node itself.
+func (e *Engine) configRefs(config interface{}, refs map[string]interface{}) error {
+	switch v := config.(type) {
+	case string:
+		for rest := v; ; {
+			open := strings.Index(rest, "{{")
+			if open == -1 {
+				return nil
+			}
+			close := strings.Index(rest[open:], "}}")
+			if close == -1 {
+				return nil
+			}
+			key := strings.TrimSpace(rest[open+2 : open+close])
+			rest = rest[open+close+2:]
+			val, err := e.resolveKey(key)
+			if err != nil {
+				if strings.HasPrefix(key, "env.") || strings.HasPrefix(key, "secrets.") {
+					return err
+				}
+				continue
+			}
+			refs[key] = val
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			if err := e.configRefs(item, refs); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := e.configRefs(item, refs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type lruEntry struct {
+	key       string
+	outputs   map[string]interface{}
+	expiresAt time.Time
+}
+
+// LRUCache is an in-memory CacheStore that evicts the least recently used entries
+type LRUCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // Front is most recently used
+	entries  map[string]*list.Element
+}
+
+// NewLRUCache creates an in-memory cache holding at most capacity entries
+func NewLRUCache(capacity int) *LRUCache {
+	return &LRUCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Get returns the cached outputs
+func (c *LRUCache) Get(key string) (map[string]interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*lruEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.outputs, true
+}
+
+// Set stores the outputs
+func (c *LRUCache) Set(key string, outputs map[string]interface{}, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &lruEntry{key: key, outputs: outputs}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return nil
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	for c.capacity > 0 && c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+	return nil
+}
+
+// DiskCache is a CacheStore that keeps one JSON file per entry, so results survive across runs
+type DiskCache struct {
+	dir string
+}
+
+type diskEntry struct {
+	ExpiresAt time.Time              `json:"expires_at,omitempty"`
+	Outputs   map[string]interface{} `json:"outputs"`
+}
+
+// NewDiskCache creates a cache that stores entries in dir
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (c *DiskCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// Get returns the cached outputs
+func (c *DiskCache) Get(key string) (map[string]interface{}, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var entry diskEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	if !entry.ExpiresAt.IsZero() && time.Now().After(entry.ExpiresAt) {
+		os.Remove(c.path(key))
+		return nil, false
+	}
+	return entry.Outputs, true
+}
+
+// Set stores the outputs
+func (c *DiskCache) Set(key string, outputs map[string]interface{}, ttl time.Duration) error {
+	entry := diskEntry{Outputs: outputs}
+	if ttl > 0 {
+		entry.ExpiresAt = time.Now().Add(ttl)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	// Write to a temp file first so concurrent readers never see a partial entry
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
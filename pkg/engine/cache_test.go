@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"testing"
+
+	"dify-vnext-go/pkg/dsl"
+	"dify-vnext-go/pkg/secrets"
+)
+
+func TestCacheKey(t *testing.T) {
+	config := map[string]interface{}{"model": "gpt-4o", "temperature": 0.0}
+	inputs := map[string]interface{}{"prompt": "hi", "items": []interface{}{1, 2}}
+
+	key, err := CacheKey("LLM", config, inputs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := CacheKey("LLM", map[string]interface{}{"temperature": 0.0, "model": "gpt-4o"}, inputs, nil)
+	if key != again {
+		t.Errorf("key depends on map order: %s != %s", key, again)
+	}
+
+	for name, other := range map[string][]interface{}{
+		"type":   {"Code", config, inputs, nil},
+		"config": {"LLM", map[string]interface{}{"model": "gpt-4o-mini", "temperature": 0.0}, inputs, nil},
+		"inputs": {"LLM", config, map[string]interface{}{"prompt": "hello", "items": []interface{}{1, 2}}, nil},
+		"refs":   {"LLM", config, inputs, map[string]interface{}{"env.MODEL": "gpt-4o"}},
+	} {
+		k, err := CacheKey(other[0].(string), mapArg(other[1]), mapArg(other[2]), mapArg(other[3]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if k == key {
+			t.Errorf("changing %s did not change the key", name)
+		}
+	}
+
+	if _, err := CacheKey("LLM", map[string]interface{}{"bad": func() {}}, nil, nil); err == nil {
+		t.Error("expected an error for a config that cannot be hashed")
+	}
+}
+
+func TestCacheKeyResolvesConfigRefs(t *testing.T) {
+	wf := &dsl.WorkflowDefinition{
+		Env: map[string]dsl.EnvVarDefinition{"MODEL": {Default: "gpt-4o"}},
+		Nodes: []dsl.NodeDefinition{{
+			ID:   "llm",
+			Type: "LLM",
+			Config: map[string]interface{}{
+				"model":   "{{ env.MODEL }}",
+				"headers": []interface{}{"Bearer {{ secrets.API_TOKEN }}"},
+			},
+		}},
+	}
+	store := secrets.MapStore{"API_TOKEN": "token-1"}
+	e := NewEngine(wf)
+	e.SetSecrets(secrets.NewVault(store))
+	inputs := map[string]interface{}{"prompt": "hi"}
+
+	key, err := e.cacheKey(&wf.Nodes[0], inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := e.cacheKey(&wf.Nodes[0], inputs); again != key {
+		t.Errorf("key is not stable: %s != %s", key, again)
+	}
+
+	e.SetEnv(map[string]interface{}{"MODEL": "gpt-4o-mini"})
+	envKey, err := e.cacheKey(&wf.Nodes[0], inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if envKey == key {
+		t.Error("changing an env variable the config references did not change the key")
+	}
+
+	store["API_TOKEN"] = "token-2"
+	secretKey, err := e.cacheKey(&wf.Nodes[0], inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secretKey == envKey {
+		t.Error("rotating a secret the config references did not change the key")
+	}
+
+	delete(store, "API_TOKEN")
+	if _, err := e.cacheKey(&wf.Nodes[0], inputs); err == nil {
+		t.Error("expected an error for a missing secret")
+	}
+}
+
+func TestCacheKeyResolvesUpstreamRefs(t *testing.T) {
+	wf := &dsl.WorkflowDefinition{
+		Nodes: []dsl.NodeDefinition{
+			{ID: "start", Type: "Start"},
+			{ID: "fetch", Type: "HttpRequest", Config: map[string]interface{}{
+				"url":    "https://example.com/search",
+				"params": map[string]interface{}{"q": "{{ start.query }}", "lang": "{{ memory.lang }}"},
+				// Resolved by the sub-workflow, not by this engine
+				"sub_workflow": map[string]interface{}{"output": "{{ inner.value }}"},
+			}},
+		},
+	}
+	e := NewEngine(wf)
+	e.memory.Set("lang", "en")
+	e.outputs["start"] = map[string]interface{}{"query": "alpha"}
+	node := &wf.Nodes[1]
+
+	key, err := e.cacheKey(node, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e.outputs["start"] = map[string]interface{}{"query": "beta"}
+	queryKey, err := e.cacheKey(node, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queryKey == key {
+		t.Error("changing an upstream output the config references did not change the key")
+	}
+
+	e.memory.Set("lang", "de")
+	if langKey, _ := e.cacheKey(node, nil); langKey == queryKey {
+		t.Error("changing a memory value the config references did not change the key")
+	}
+}
+
+func mapArg(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
@@ -2,11 +2,17 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"dify-vnext-go/pkg/dsl"
+	"dify-vnext-go/pkg/egress"
+	"dify-vnext-go/pkg/secrets"
+	"dify-vnext-go/pkg/tools"
 )
 
 // Engine is the main runtime engine
@@ -16,6 +22,17 @@ type Engine struct {
 	nodes        map[string]Node
 	outputs      map[string]map[string]interface{} // node_id -> outputs
 	checkpointer Checkpointer
+	threadID     string
+	pending      *InterruptError // Set while the run is suspended
+	resume       *resumeTarget   // Where the input supplied on resume goes
+	nested       bool            // Runs a sub-workflow for a node of another engine
+	cache        CacheStore
+	eventHandler EventHandler
+	egress       *egress.Policy         // Applied to outbound HTTP requests made by nodes and tools
+	secrets      *secrets.Vault         // Resolves {{ secrets.NAME }}; resolved values are redacted
+	env          map[string]interface{} // Workflow environment variables, {{ env.NAME }}
+	blobs        BlobStore              // Binary data referenced by BlobRefs in memory and outputs
+	tools        *tools.Registry        // Tools available to Tool nodes: the workflow's tool_providers over the built-ins
 	mu           sync.RWMutex
 }
 
@@ -26,6 +43,13 @@ func NewEngine(wf *dsl.WorkflowDefinition) *Engine {
 		memory:   NewGlobalMemory(),
 		nodes:    make(map[string]Node),
 		outputs:  make(map[string]map[string]interface{}),
+		threadID: DefaultThreadID,
+		cache:    NewLRUCache(1024),
+		egress:   egress.Default,
+		secrets:  secrets.Default,
+		env:      defaultEnv(wf.Env),
+		blobs:    NewMemoryBlobStore(),
+		tools:    tools.Default,
 	}
 }
 
@@ -47,6 +71,65 @@ func (e *Engine) SetCheckpointer(cp Checkpointer) {
 	e.checkpointer = cp
 }
 
+// SetBlobStore sets the store holding the data behind BlobRefs
+func (e *Engine) SetBlobStore(s BlobStore) {
+	e.blobs = s
+}
+
+// Blobs returns the engine's blob store
+func (e *Engine) Blobs() BlobStore {
+	return e.blobs
+}
+
+// SetTools sets the registry Tool nodes look tools up in
+func (e *Engine) SetTools(r *tools.Registry) {
+	e.tools = r
+}
+
+// Tools returns the engine's tool registry
+func (e *Engine) Tools() *tools.Registry {
+	return e.tools
+}
+
+// SetCache sets the store used by nodes that opt into result caching
+func (e *Engine) SetCache(c CacheStore) {
+	e.cache = c
+}
+
+// SetEgressPolicy sets the policy applied to outbound HTTP requests made by nodes and tools
+func (e *Engine) SetEgressPolicy(p *egress.Policy) {
+	e.egress = p
+}
+
+// SetSecrets sets the vault that resolves {{ secrets.NAME }} templates and secret lookups by nodes and tools
+func (e *Engine) SetSecrets(v *secrets.Vault) {
+	e.secrets = v
+}
+
+// Redact replaces resolved secret values in val. Node outputs hold the real values, so
+// callers emitting them outside the engine (e.g. from GetOutputs) should redact first.
+func (e *Engine) Redact(val interface{}) interface{} {
+	return e.secrets.RedactValue(val)
+}
+
+// httpClient returns a client enforcing the egress policy that reports denials as events of nodeID
+func (e *Engine) httpClient(nodeID string) *http.Client {
+	return e.egress.Client(func(d *egress.DeniedError) {
+		fmt.Printf("[%s] %v\n", nodeID, d)
+		e.Emit(EventEgressDenied, nodeID, map[string]interface{}{
+			"url":    d.URL,
+			"host":   d.Host,
+			"ip":     d.IP,
+			"reason": d.Reason,
+		})
+	})
+}
+
+// SetThreadID sets the thread under which the run is checkpointed
+func (e *Engine) SetThreadID(id string) {
+	e.threadID = id
+}
+
 // RegisterNode registers a node implementation
 func (e *Engine) RegisterNode(n Node) {
 	e.nodes[n.ID()] = n
@@ -64,7 +147,9 @@ func (e *Engine) RegisterNodes(nodes map[string]Node) {
 	}
 }
 
-// Run executes the workflow
+// Run executes the workflow.
+// If a node suspends the run (see Interrupt), Run waits for in-flight nodes,
+// checkpoints the state and returns the *InterruptError describing the pending input.
 func (e *Engine) Run(ctx context.Context, initialInputs map[string]interface{}) error {
 	// Initialize memory with inputs
 	for k, v := range initialInputs {
@@ -104,6 +189,13 @@ func (e *Engine) Run(ctx context.Context, initialInputs map[string]interface{})
 	errCh := make(chan error, 1)
 	// Done channel to signal completion
 	doneCh := make(chan struct{})
+	// Suspend channel receives the first node that asked for external input
+	suspendCh := make(chan *InterruptError, 1)
+	// Stop channel releases the coordinator once Run returns
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	// Once halted (guarded by e.mu), no new nodes are started
+	halted := false
 
 	// Start a coordinator goroutine
 	go func() {
@@ -114,16 +206,45 @@ func (e *Engine) Run(ctx context.Context, initialInputs map[string]interface{})
 			select {
 			case <-ctx.Done():
 				return
+			case <-stopCh:
+				return
 			case nodeID := <-readyCh:
+				e.mu.Lock()
+				if halted {
+					e.mu.Unlock()
+					continue
+				}
 				wg.Add(1)
+				e.mu.Unlock()
+
 				go func(id string) {
 					defer wg.Done()
-					if err := e.executeNode(ctx, id); err != nil {
-						select {
-						case errCh <- err:
-						default:
+
+					// Nodes restored from a checkpoint already have outputs and are not re-run
+					e.mu.RLock()
+					_, restored := e.outputs[id]
+					e.mu.RUnlock()
+
+					if !restored {
+						if err := e.executeNode(ctx, id); err != nil {
+							var interrupt *InterruptError
+							if errors.As(err, &interrupt) {
+								e.mu.Lock()
+								halted = true
+								e.mu.Unlock()
+								select {
+								case suspendCh <- interrupt:
+								default:
+									// Another node suspended first; this one asks again on resume
+								}
+								return
+							}
+							select {
+							case errCh <- err:
+							default:
+							}
+							return
 						}
-						return
 					}
 
 					// Node finished, update downstream dependencies
@@ -174,17 +295,149 @@ func (e *Engine) Run(ctx context.Context, initialInputs map[string]interface{})
 		}
 	}()
 
-	// Wait for completion or error
+	// Wait for completion, error or suspension
 	select {
 	case <-doneCh:
 		wg.Wait() // Ensure all workers finish
 		return nil
 	case err := <-errCh:
 		return err
+	case interrupt := <-suspendCh:
+		// Let parallel branches finish so their outputs are part of the checkpoint
+		wg.Wait()
+		e.suspend(interrupt)
+		return interrupt
 	case <-ctx.Done():
 		return ctx.Err()
-	case <-ctx.Done():
-		return ctx.Err()
+	}
+}
+
+// Resume continues a suspended thread from its checkpoint.
+// The input is handed to the node that suspended the run via NodeContext.Resume.
+func (e *Engine) Resume(ctx context.Context, input map[string]interface{}) error {
+	if e.checkpointer == nil {
+		return fmt.Errorf("cannot resume without a checkpointer")
+	}
+
+	pending, err := LoadPending(e.checkpointer, e.threadID)
+	if err != nil {
+		return err
+	}
+
+	state, err := e.checkpointer.Load(e.threadID)
+	if err != nil {
+		return fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	if mem, ok := state["memory"].(map[string]interface{}); ok {
+		for k, v := range mem {
+			e.memory.Set(k, v)
+		}
+	}
+
+	e.mu.Lock()
+	if outputs, ok := state["outputs"].(map[string]interface{}); ok {
+		for id, out := range outputs {
+			if m, ok := out.(map[string]interface{}); ok {
+				e.outputs[id] = m
+			}
+		}
+	}
+	e.pending = nil
+	e.resume = &resumeTarget{path: strings.Split(pending.NodeID, "/"), input: input, subflows: pending.Subflows}
+	e.mu.Unlock()
+
+	fmt.Printf("Resuming thread %s at node %s\n", e.threadID, pending.NodeID)
+	return e.Run(ctx, nil)
+}
+
+// resumeTarget routes the input supplied on resume to the node that suspended, which may
+// sit inside (nested) Workflow nodes
+type resumeTarget struct {
+	path     []string               // Node IDs from this engine down to the suspended node
+	input    map[string]interface{} // Input for the suspended node
+	subflows map[string]interface{} // Sub-engine outputs keyed by node path relative to this engine
+	outputs  map[string]interface{} // Outputs this engine had when it suspended (sub-engines only)
+}
+
+// descend returns the target as seen by the sub-engine of the first node on the path
+func (t *resumeTarget) descend() *resumeTarget {
+	prefix := t.path[0] + "/"
+	child := &resumeTarget{path: t.path[1:], input: t.input, subflows: make(map[string]interface{})}
+	child.outputs, _ = t.subflows[t.path[0]].(map[string]interface{})
+	for k, v := range t.subflows {
+		if strings.HasPrefix(k, prefix) {
+			child.subflows[strings.TrimPrefix(k, prefix)] = v
+		}
+	}
+	return child
+}
+
+// joinNodePath prefixes a path reported by a sub-engine with the node that runs it
+func joinNodePath(nodeID, sub string) string {
+	if sub == "" {
+		return nodeID
+	}
+	return nodeID + "/" + sub
+}
+
+// suspend records the pending input and checkpoints the state. A sub-engine instead
+// attaches its outputs to the interrupt, to be checkpointed by the top-level engine.
+func (e *Engine) suspend(interrupt *InterruptError) {
+	interrupt.ThreadID = e.threadID
+	e.mu.Lock()
+	e.pending = interrupt
+	if e.nested {
+		outputs := make(map[string]interface{}, len(e.outputs))
+		for id, out := range e.outputs {
+			outputs[id] = out
+		}
+		if interrupt.Subflows == nil {
+			interrupt.Subflows = make(map[string]interface{})
+		}
+		interrupt.Subflows[""] = outputs
+	}
+	e.mu.Unlock()
+	if e.nested {
+		return
+	}
+
+	fmt.Printf("Suspending thread %s: node %s is waiting for input\n", e.threadID, interrupt.NodeID)
+	e.saveCheckpoint()
+}
+
+// snapshot captures memory, node outputs and any pending input for checkpointing
+func (e *Engine) snapshot() map[string]interface{} {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	outputs := make(map[string]interface{}, len(e.outputs))
+	for id, out := range e.outputs {
+		outputs[id] = out
+	}
+	state := map[string]interface{}{
+		"memory":  e.memory.GetAll(),
+		"outputs": outputs,
+	}
+	if e.pending != nil {
+		pending := map[string]interface{}{
+			"node_id": e.pending.NodeID,
+			"payload": e.pending.Payload,
+		}
+		if len(e.pending.Subflows) > 0 {
+			pending["subflows"] = e.pending.Subflows
+		}
+		state["pending"] = pending
+	}
+	// Secrets never reach persisted state
+	return e.secrets.RedactMap(state)
+}
+
+func (e *Engine) saveCheckpoint() {
+	if e.checkpointer == nil {
+		return
+	}
+	if err := e.checkpointer.Save(e.threadID, e.snapshot()); err != nil {
+		fmt.Printf("Warning: failed to save checkpoint: %v\n", err)
 	}
 }
 
@@ -217,18 +470,85 @@ func (e *Engine) executeNode(ctx context.Context, nodeID string) error {
 		inputs[k] = val
 	}
 
+	// Serve deterministic nodes from the cache when the same config and inputs were seen before
+	var cacheKey string
+	var cacheTTL time.Duration
+	if nodeDef.Cache != nil && nodeDef.Cache.Enabled && e.cache != nil {
+		key, err := e.cacheKey(nodeDef, inputs)
+		if err != nil {
+			fmt.Printf("Warning: caching disabled for node %s: %v\n", nodeID, err)
+		} else {
+			cacheKey = key
+			if nodeDef.Cache.TTL != "" {
+				if cacheTTL, err = time.ParseDuration(nodeDef.Cache.TTL); err != nil {
+					return fmt.Errorf("invalid cache ttl for node %s: %w", nodeID, err)
+				}
+			}
+			if cached, ok := e.cache.Get(cacheKey); ok {
+				fmt.Printf("Cache hit for node: %s (Type: %s)\n", nodeID, nodeDef.Type)
+				e.Emit(EventCacheHit, nodeID, map[string]interface{}{"key": cacheKey})
+				e.mu.Lock()
+				e.outputs[nodeID] = cached
+				e.mu.Unlock()
+				e.saveCheckpoint()
+				return nil
+			}
+		}
+	}
+
+	// On resume, the suspended node gets the input; a node running the sub-workflow that
+	// contains it gets the rest of the path (see NodeContext.Fork)
+	var resume map[string]interface{}
+	var subResume *resumeTarget
+	e.mu.RLock()
+	if t := e.resume; t != nil && len(t.path) > 0 && t.path[0] == nodeID {
+		if len(t.path) == 1 {
+			resume = t.input
+		} else {
+			subResume = t.descend()
+		}
+	}
+	e.mu.RUnlock()
+
 	// Execute
 	fmt.Printf("Executing node: %s (Type: %s)\n", nodeID, nodeDef.Type)
+	e.Emit(EventNodeStarted, nodeID, map[string]interface{}{"type": nodeDef.Type})
 	outputs, err := nodeImpl.Execute(&NodeContext{
-		Ctx:    ctx,
+		Ctx:    secrets.WithVault(egress.WithClient(ctx, e.httpClient(nodeID)), e.secrets),
 		Memory: e.memory,
 		Inputs: inputs,
 		NodeID: nodeID,
 		Engine: e,
+		Resume: resume,
+		resume: subResume,
 	})
 	if err != nil {
+		var interrupt *InterruptError
+		if errors.As(err, &interrupt) {
+			interrupt.NodeID = joinNodePath(nodeID, interrupt.NodeID)
+			if len(interrupt.Subflows) > 0 {
+				subflows := make(map[string]interface{}, len(interrupt.Subflows))
+				for path, outputs := range interrupt.Subflows {
+					subflows[joinNodePath(nodeID, path)] = outputs
+				}
+				interrupt.Subflows = subflows
+			}
+			return interrupt
+		}
 		return fmt.Errorf("node execution failed: %w", err)
 	}
+	e.Emit(EventNodeFinished, nodeID, map[string]interface{}{"type": nodeDef.Type})
+
+	// Downstream nodes see outputs as produced; secrets are redacted where data leaves the
+	// engine (events, checkpoints, stdout and logs). Outputs carrying a secret are not cached,
+	// since cache stores may persist them.
+	if cacheKey != "" && e.secrets.Contains(outputs) {
+		fmt.Printf("Warning: not caching outputs of node %s because they contain a secret\n", nodeID)
+	} else if cacheKey != "" {
+		if err := e.cache.Set(cacheKey, outputs, cacheTTL); err != nil {
+			fmt.Printf("Warning: failed to cache outputs of node %s: %v\n", nodeID, err)
+		}
+	}
 
 	// Store outputs
 	e.mu.Lock()
@@ -236,17 +556,32 @@ func (e *Engine) executeNode(ctx context.Context, nodeID string) error {
 	e.mu.Unlock()
 
 	// Checkpoint state
-	if e.checkpointer != nil {
-		// For MVP, we use "default" thread ID. In real app, this comes from context or input.
-		// We save the entire memory state.
-		if err := e.checkpointer.Save("default_thread", e.memory.GetAll()); err != nil {
-			fmt.Printf("Warning: failed to save checkpoint: %v\n", err)
-		}
-	}
+	e.saveCheckpoint()
 
 	return nil
 }
 
+// ResolveValue resolves templates in a value against memory and node outputs.
+// Strings, lists and maps are resolved recursively; other types are returned as is.
+func (e *Engine) ResolveValue(val interface{}) (interface{}, error) {
+	return e.resolveValue(val)
+}
+
+// Fork creates an engine for a sub-workflow that runs against the given memory scope
+func (e *Engine) Fork(wf *dsl.WorkflowDefinition, mem Memory) *Engine {
+	child := NewEngine(wf)
+	child.SetMemory(mem)
+	child.cache = e.cache
+	child.eventHandler = e.eventHandler
+	child.egress = e.egress
+	child.secrets = e.secrets
+	child.env = e.inheritEnv(wf)
+	child.blobs = e.blobs
+	child.tools = e.tools
+	child.nested = true
+	return child
+}
+
 func (e *Engine) resolveValue(val interface{}) (interface{}, error) {
 	switch v := val.(type) {
 	case string:
@@ -332,6 +667,19 @@ func (e *Engine) resolveStringTemplate(template string) (interface{}, error) {
 }
 
 func (e *Engine) resolveKey(key string) (interface{}, error) {
+	if strings.HasPrefix(key, "secrets.") {
+		return e.secrets.Lookup(strings.TrimPrefix(key, "secrets."))
+	}
+
+	if strings.HasPrefix(key, "env.") {
+		name := strings.TrimPrefix(key, "env.")
+		val, ok := e.env[name]
+		if !ok {
+			return nil, fmt.Errorf("env variable not declared: %s", name)
+		}
+		return val, nil
+	}
+
 	// Check memory
 	if strings.HasPrefix(key, "memory.") {
 		memKey := strings.TrimPrefix(key, "memory.")
@@ -388,6 +736,7 @@ func (e *Engine) skipNode(nodeID string, adj map[string][]string, inDegree map[s
 	// This node is skipped.
 	// In a real implementation, we might want to record this state.
 	fmt.Printf("Skipping node: %s\n", nodeID)
+	e.Emit(EventNodeSkipped, nodeID, nil)
 
 	(*completedNodes)++
 
@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"dify-vnext-go/pkg/dsl"
+	"dify-vnext-go/pkg/secrets"
+)
+
+// echoNode returns its resolved inputs as outputs
+type echoNode struct{ id string }
+
+func (n echoNode) ID() string   { return n.id }
+func (n echoNode) Type() string { return "Echo" }
+func (n echoNode) Execute(ctx *NodeContext) (map[string]interface{}, error) {
+	return ctx.Inputs, nil
+}
+
+func TestSecretsRedactedOnlyAtSinks(t *testing.T) {
+	wf := &dsl.WorkflowDefinition{
+		Nodes: []dsl.NodeDefinition{
+			{ID: "src", Type: "Echo", Cache: &dsl.CacheDefinition{Enabled: true}, Inputs: map[string]interface{}{
+				"token": "{{ secrets.TOKEN }}",
+				"note":  "a test run",
+			}},
+			{ID: "dst", Type: "Echo", Inputs: map[string]interface{}{"note": "{{ src.note }}"}},
+		},
+		Edges: []dsl.EdgeDefinition{{Source: "src", Target: "dst"}},
+	}
+	e := NewEngine(wf)
+	e.SetSecrets(secrets.NewVault(secrets.MapStore{"TOKEN": "test"}))
+	cache := NewLRUCache(8)
+	e.SetCache(cache)
+	var events []Event
+	e.SetEventHandler(func(ev Event) { events = append(events, ev) })
+	e.RegisterNode(echoNode{"src"})
+	e.RegisterNode(echoNode{"dst"})
+
+	if err := e.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Values passed between nodes are left alone, even where they contain a secret value
+	if note := e.GetOutputs()["dst"]["note"]; note != "a test run" {
+		t.Errorf("dst saw note %q, want it unredacted", note)
+	}
+	if token := e.GetOutputs()["src"]["token"]; token != "test" {
+		t.Errorf("src token = %q, want the resolved secret", token)
+	}
+
+	// Persisted state is redacted
+	outputs := e.snapshot()["outputs"].(map[string]interface{})
+	if src := outputs["src"].(map[string]interface{}); src["token"] != secrets.Redacted || src["note"] != "a "+secrets.Redacted+" run" {
+		t.Errorf("checkpoint state not redacted: %v", src)
+	}
+	if e.Redact("token: test") != "token: "+secrets.Redacted {
+		t.Error("Redact did not replace the secret")
+	}
+
+	// Outputs carrying a secret never reach the cache
+	if len(cache.entries) != 0 {
+		t.Errorf("outputs with a secret were cached: %v", cache.entries)
+	}
+	e.Emit(EventNodeFinished, "src", e.GetOutputs()["src"])
+	if last := events[len(events)-1]; last.Data["note"] != "a "+secrets.Redacted+" run" {
+		t.Errorf("event data not redacted: %v", last.Data)
+	}
+}
@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"dify-vnext-go/pkg/dsl"
+)
+
+// ResolveEnv computes the values of a workflow's env section. Overrides, typically from
+// an env file or the command line, are strings converted to the declared type; variables
+// without an override take their default.
+func ResolveEnv(defs map[string]dsl.EnvVarDefinition, overrides map[string]string) (map[string]interface{}, error) {
+	for name := range overrides {
+		if _, ok := defs[name]; !ok {
+			return nil, fmt.Errorf("env %s is not declared by the workflow", name)
+		}
+	}
+
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	env := make(map[string]interface{}, len(defs))
+	for _, name := range names {
+		def := defs[name]
+		var raw interface{} = def.Default
+		if v, ok := overrides[name]; ok {
+			raw = v
+		}
+		if raw == nil {
+			return nil, fmt.Errorf("env %s has no default and no value was supplied", name)
+		}
+		val, err := convertEnv(def.Type, raw)
+		if err != nil {
+			return nil, fmt.Errorf("env %s: %w", name, err)
+		}
+		env[name] = val
+	}
+	return env, nil
+}
+
+func convertEnv(typ string, raw interface{}) (interface{}, error) {
+	switch typ {
+	case "", "string":
+		if s, ok := raw.(string); ok {
+			return s, nil
+		}
+		return fmt.Sprintf("%v", raw), nil
+	case "number":
+		switch v := raw.(type) {
+		case int:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("expected a number, got %q", v)
+			}
+			return f, nil
+		}
+		return nil, fmt.Errorf("expected a number, got %v", raw)
+	case "boolean":
+		switch v := raw.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("expected a boolean, got %q", v)
+			}
+			return b, nil
+		}
+		return nil, fmt.Errorf("expected a boolean, got %v", raw)
+	default:
+		return nil, fmt.Errorf("unknown type '%s'", typ)
+	}
+}
+
+// defaultEnv returns the declared defaults; variables without a usable default are left
+// unset until SetEnv supplies them
+func defaultEnv(defs map[string]dsl.EnvVarDefinition) map[string]interface{} {
+	env := make(map[string]interface{}, len(defs))
+	for name, def := range defs {
+		if def.Default == nil {
+			continue
+		}
+		if val, err := convertEnv(def.Type, def.Default); err == nil {
+			env[name] = val
+		}
+	}
+	return env
+}
+
+// inheritEnv computes the env of a sub-workflow. Inline sub-workflows without an env
+// section share the parent's; a sub-workflow declaring its own takes the parent's value
+// for each variable both declare and its own defaults for the rest.
+func (e *Engine) inheritEnv(wf *dsl.WorkflowDefinition) map[string]interface{} {
+	if len(wf.Env) == 0 {
+		return e.env
+	}
+	env := defaultEnv(wf.Env)
+	for name, def := range wf.Env {
+		if val, ok := e.env[name]; ok {
+			if converted, err := convertEnv(def.Type, val); err == nil {
+				env[name] = converted
+			}
+		}
+	}
+	return env
+}
+
+// SetEnv sets the values addressable as {{ env.NAME }}
+func (e *Engine) SetEnv(env map[string]interface{}) {
+	e.env = env
+}
@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"time"
+)
+
+// EventType identifies what happened during a run
+type EventType string
+
+const (
+	EventNodeStarted  EventType = "node_started"
+	EventNodeFinished EventType = "node_finished"
+	EventNodeSkipped  EventType = "node_skipped"
+	EventCacheHit     EventType = "cache_hit"
+	EventEgressDenied EventType = "egress_denied"
+)
+
+// Event describes something that happened while running a workflow
+type Event struct {
+	Type   EventType              `json:"type"`
+	NodeID string                 `json:"node_id,omitempty"`
+	Time   time.Time              `json:"time"`
+	Data   map[string]interface{} `json:"data,omitempty"`
+}
+
+// EventHandler receives engine events. It may be called concurrently from node goroutines
+// and while the engine holds internal locks, so it must not call back into the engine.
+type EventHandler func(Event)
+
+// SetEventHandler sets the handler that receives engine events
+func (e *Engine) SetEventHandler(h EventHandler) {
+	e.eventHandler = h
+}
+
+// Emit sends an event to the registered handler, if any
+func (e *Engine) Emit(typ EventType, nodeID string, data map[string]interface{}) {
+	if e.eventHandler == nil {
+		return
+	}
+	e.eventHandler(Event{
+		Type:   typ,
+		NodeID: nodeID,
+		Time:   time.Now(),
+		Data:   e.secrets.RedactMap(data),
+	})
+}
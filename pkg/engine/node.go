@@ -2,6 +2,8 @@ package engine
 
 import (
 	"context"
+
+	"dify-vnext-go/pkg/dsl"
 )
 
 // NodeContext provides context for node execution
@@ -10,7 +12,27 @@ type NodeContext struct {
 	Memory Memory
 	Inputs map[string]interface{}
 	NodeID string
-	Engine *Engine // Reference to the executing engine
+	Engine *Engine                // Reference to the executing engine
+	Resume map[string]interface{} // Input supplied when resuming a run this node suspended
+
+	resume *resumeTarget // Set when resuming a node inside this node's sub-workflow
+}
+
+// Fork creates the engine for a sub-workflow this node runs once per execution, like
+// Engine.Fork. When the run resumes a node that suspended inside that sub-workflow, the
+// child restores the outputs it had at suspension and passes the resume input on, so a
+// HumanInput inside a Workflow node completes instead of asking again.
+func (c *NodeContext) Fork(wf *dsl.WorkflowDefinition, mem Memory) *Engine {
+	child := c.Engine.Fork(wf, mem)
+	if c.resume != nil {
+		child.resume = c.resume
+		for id, out := range c.resume.outputs {
+			if m, ok := out.(map[string]interface{}); ok {
+				child.outputs[id] = m
+			}
+		}
+	}
+	return child
 }
 
 // Node is the interface that all workflow nodes must implement
@@ -1,20 +1,23 @@
 package engine
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 )
 
-// BlobRef represents a reference to a large binary object
-// This avoids passing large data around in memory
+// DefaultThreadID is used when no thread is set on the engine
+const DefaultThreadID = "default_thread"
+
+// BlobRef represents a reference to a large binary object held in the engine's BlobStore.
+// Nodes pass refs through memory and outputs, so checkpoints carry only the reference.
 type BlobRef struct {
 	ID       string `json:"blob_id"`
 	MimeType string `json:"mime_type"`
 	Size     int64  `json:"size"`
-	// In a real system, Data would not be here, or would be a stream/reader.
-	// For MVP, we might store it here or in a separate BlobStore.
-	// Let's keep it simple: Data is here but we encourage passing the BlobRef struct.
-	Data []byte `json:"-"`
+	Name     string `json:"name,omitempty"` // Original filename, if known
 }
 
 // Checkpointer defines the interface for saving and loading workflow state
@@ -25,6 +28,44 @@ type Checkpointer interface {
 	Load(threadID string) (map[string]interface{}, error)
 }
 
+// InterruptError is returned by a node to suspend the run until external input arrives.
+// The engine fills in NodeID and ThreadID and returns it from Run once state is checkpointed.
+type InterruptError struct {
+	// NodeID is the path of the suspended node. A node inside a Workflow node's sub-workflow
+	// is prefixed with the Workflow node's ID, e.g. "review/approve".
+	NodeID   string
+	ThreadID string
+	Payload  map[string]interface{} // What the node is waiting for, e.g. prompt and form schema
+	// Subflows holds the node outputs of each sub-workflow engine on the path, keyed by the
+	// path of the node that runs it, so resuming does not re-run nodes that already finished
+	Subflows map[string]interface{}
+}
+
+func (e *InterruptError) Error() string {
+	return fmt.Sprintf("thread %s suspended: node %s is waiting for input", e.ThreadID, e.NodeID)
+}
+
+// Interrupt suspends the run at the calling node
+func Interrupt(payload map[string]interface{}) error {
+	return &InterruptError{Payload: payload}
+}
+
+// LoadPending returns the pending input request of a suspended thread
+func LoadPending(cp Checkpointer, threadID string) (*InterruptError, error) {
+	state, err := cp.Load(threadID)
+	if err != nil {
+		return nil, err
+	}
+	pending, ok := state["pending"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("thread %s is not waiting for input", threadID)
+	}
+	nodeID, _ := pending["node_id"].(string)
+	payload, _ := pending["payload"].(map[string]interface{})
+	subflows, _ := pending["subflows"].(map[string]interface{})
+	return &InterruptError{NodeID: nodeID, ThreadID: threadID, Payload: payload, Subflows: subflows}, nil
+}
+
 // InMemoryCheckpointer is a simple in-memory implementation of Checkpointer
 type InMemoryCheckpointer struct {
 	mu    sync.RWMutex
@@ -75,3 +116,62 @@ func (c *InMemoryCheckpointer) Load(threadID string) (map[string]interface{}, er
 
 	return loadedState, nil
 }
+
+// FileCheckpointer persists each thread as a JSON file so runs can be resumed by another process
+type FileCheckpointer struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFileCheckpointer creates a checkpointer that stores threads in dir
+func NewFileCheckpointer(dir string) (*FileCheckpointer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint dir: %w", err)
+	}
+	return &FileCheckpointer{dir: dir}, nil
+}
+
+func (c *FileCheckpointer) path(threadID string) string {
+	return filepath.Join(c.dir, threadID+".json")
+}
+
+// Save persists the state
+func (c *FileCheckpointer) Save(threadID string, state map[string]interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	// Write to a temp file first so a crash never leaves a truncated checkpoint
+	tmp := c.path(threadID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, c.path(threadID)); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Load retrieves the state
+func (c *FileCheckpointer) Load(threadID string) (map[string]interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := os.ReadFile(c.path(threadID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("thread not found: %s", threadID)
+		}
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var state map[string]interface{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint: %w", err)
+	}
+	return state, nil
+}
@@ -0,0 +1,99 @@
+package knowledge
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Tokenize lower-cases text and splits it into words. Han, kana and Hangul characters
+// are single tokens, since those scripts do not separate words with spaces.
+func Tokenize(text string) []string {
+	var (
+		tokens []string
+		word   strings.Builder
+	)
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+type posting struct {
+	chunk int
+	tf    int
+}
+
+// bm25Index is an inverted index over chunk texts, rebuilt when a knowledge base is loaded
+type bm25Index struct {
+	postings map[string][]posting
+	lengths  []int
+	avgLen   float64
+}
+
+func newBM25Index(texts []string) *bm25Index {
+	idx := &bm25Index{postings: make(map[string][]posting), lengths: make([]int, len(texts))}
+	total := 0
+	for i, text := range texts {
+		tokens := Tokenize(text)
+		idx.lengths[i] = len(tokens)
+		total += len(tokens)
+		counts := make(map[string]int)
+		for _, t := range tokens {
+			counts[t]++
+		}
+		for t, c := range counts {
+			idx.postings[t] = append(idx.postings[t], posting{chunk: i, tf: c})
+		}
+	}
+	if len(texts) > 0 {
+		idx.avgLen = float64(total) / float64(len(texts))
+	}
+	return idx
+}
+
+// score returns the BM25 score of every chunk matching at least one query term
+func (idx *bm25Index) score(query string) map[int]float64 {
+	scores := make(map[int]float64)
+	n := float64(len(idx.lengths))
+	seen := make(map[string]bool)
+	for _, term := range Tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		postings := idx.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for _, p := range postings {
+			tf := float64(p.tf)
+			norm := 1 - bm25B + bm25B*float64(idx.lengths[p.chunk])/math.Max(idx.avgLen, 1)
+			scores[p.chunk] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+	}
+	return scores
+}
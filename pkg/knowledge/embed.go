@@ -0,0 +1,189 @@
+package knowledge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+
+	"dify-vnext-go/pkg/egress"
+	"dify-vnext-go/pkg/secrets"
+)
+
+// Embedder turns texts into vectors for similarity search
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Embedder names
+const (
+	EmbedderNone   = "none"
+	EmbedderHash   = "hash"
+	EmbedderOpenAI = "openai"
+)
+
+const (
+	defaultHashDimensions = 256
+	defaultOpenAIModel    = "text-embedding-3-small"
+	openAIBatchSize       = 96
+)
+
+// NewEmbedder creates the embedder configured for a knowledge base. "none" returns nil:
+// the knowledge base is searched by keywords only.
+func NewEmbedder(cfg Config) (Embedder, error) {
+	switch cfg.Embedder {
+	case "", EmbedderNone:
+		return nil, nil
+	case EmbedderHash:
+		dims := cfg.Dimensions
+		if dims <= 0 {
+			dims = defaultHashDimensions
+		}
+		return &HashEmbedder{Dimensions: dims}, nil
+	case EmbedderOpenAI:
+		model := cfg.Model
+		if model == "" {
+			model = defaultOpenAIModel
+		}
+		return &OpenAIEmbedder{Model: model, Dimensions: cfg.Dimensions}, nil
+	default:
+		return nil, fmt.Errorf("unknown embedder '%s'", cfg.Embedder)
+	}
+}
+
+// HashEmbedder is a deterministic local embedder: words and word bigrams are hashed into
+// a fixed number of signed buckets. It needs no model or network, which makes it useful
+// for tests and offline use, but it only captures lexical overlap.
+type HashEmbedder struct {
+	Dimensions int
+}
+
+func (e *HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		vec := make([]float32, e.Dimensions)
+		terms := Tokenize(text)
+		add := func(feature string, weight float32) {
+			h := fnv.New64a()
+			h.Write([]byte(feature))
+			sum := h.Sum64()
+			idx := int(sum % uint64(e.Dimensions))
+			if sum&(1<<63) != 0 {
+				weight = -weight
+			}
+			vec[idx] += weight
+		}
+		for j, term := range terms {
+			add(term, 1)
+			if j > 0 {
+				add(terms[j-1]+" "+term, 0.5)
+			}
+		}
+		out[i] = normalize(vec)
+	}
+	return out, nil
+}
+
+// OpenAIEmbedder calls the OpenAI embeddings API with the OPENAI_API_KEY secret.
+// OPENAI_BASE_URL points it at a compatible server.
+type OpenAIEmbedder struct {
+	Model      string
+	Dimensions int // Optional; supported by text-embedding-3 models
+}
+
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	apiKey, err := secrets.Lookup(ctx, "OPENAI_API_KEY")
+	if err != nil {
+		return nil, fmt.Errorf("openai embedder: %w", err)
+	}
+	base := strings.TrimRight(os.Getenv("OPENAI_BASE_URL"), "/")
+	if base == "" {
+		base = "https://api.openai.com/v1"
+	}
+
+	out := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += openAIBatchSize {
+		end := start + openAIBatchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+		payload := map[string]interface{}{"model": e.Model, "input": texts[start:end]}
+		if e.Dimensions > 0 {
+			payload["dimensions"] = e.Dimensions
+		}
+		body, _ := json.Marshal(payload)
+		req, err := http.NewRequestWithContext(ctx, "POST", base+"/embeddings", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+
+		resp, err := egress.ClientFrom(ctx).Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("embedding request failed: %w", err)
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read body: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("OpenAI API error (status %d): %s", resp.StatusCode, string(data))
+		}
+		var parsed struct {
+			Data []struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(data, &parsed); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+		if len(parsed.Data) != end-start {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", end-start, len(parsed.Data))
+		}
+		batch := make([][]float32, end-start)
+		for _, d := range parsed.Data {
+			if d.Index < 0 || d.Index >= len(batch) {
+				return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+			}
+			batch[d.Index] = normalize(d.Embedding)
+		}
+		out = append(out, batch...)
+	}
+	return out, nil
+}
+
+// normalize scales v to unit length so cosine similarity is a dot product
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+	return v
+}
+
+func dot(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
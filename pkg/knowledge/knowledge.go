@@ -0,0 +1,546 @@
+// Package knowledge implements local knowledge bases for retrieval-augmented workflows:
+// documents are extracted, chunked, optionally embedded, and stored on disk; queries are
+// answered with BM25 keyword search, embedding similarity, or a weighted fusion of both.
+package knowledge
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"dify-vnext-go/pkg/document"
+	"dify-vnext-go/pkg/textsplit"
+)
+
+// DirEnv overrides the directory holding knowledge bases
+const DirEnv = "VNEXT_KB_DIR"
+
+// DefaultDir holds knowledge bases when neither a directory nor VNEXT_KB_DIR is given
+const DefaultDir = "knowledge"
+
+const (
+	indexFile           = "kb.json"
+	defaultChunkSize    = 1000
+	defaultChunkOverlap = 100
+)
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Root returns dir, or the directory from VNEXT_KB_DIR, or DefaultDir
+func Root(dir string) string {
+	if dir != "" {
+		return dir
+	}
+	if env := os.Getenv(DirEnv); env != "" {
+		return env
+	}
+	return DefaultDir
+}
+
+// Config describes how a knowledge base chunks and embeds documents. A zero ChunkSize
+// selects 1000 characters with 100 of overlap.
+type Config struct {
+	Name         string    `json:"name"`
+	Embedder     string    `json:"embedder"` // none, hash or openai
+	Model        string    `json:"model,omitempty"`
+	Dimensions   int       `json:"dimensions,omitempty"`
+	ChunkSize    int       `json:"chunk_size"`
+	ChunkOverlap int       `json:"chunk_overlap"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Document is a source ingested into a knowledge base
+type Document struct {
+	ID       string                 `json:"id"`
+	Name     string                 `json:"name"`
+	Source   string                 `json:"source"`
+	Format   string                 `json:"format"`
+	Hash     string                 `json:"hash"` // sha256 of the extracted text
+	Chunks   int                    `json:"chunks"`
+	AddedAt  time.Time              `json:"added_at"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Chunk is a searchable piece of a document. Start and End are byte offsets into the
+// document's extracted text.
+type Chunk struct {
+	ID         string    `json:"id"`
+	DocumentID string    `json:"document_id"`
+	Index      int       `json:"index"`
+	Text       string    `json:"text"`
+	Start      int       `json:"start"`
+	End        int       `json:"end"`
+	Page       int       `json:"page,omitempty"`
+	Section    string    `json:"section,omitempty"`
+	Embedding  []float32 `json:"embedding,omitempty"`
+}
+
+// KnowledgeBase is a named, on-disk collection of chunked documents
+type KnowledgeBase struct {
+	dir string
+
+	mu        sync.RWMutex
+	config    Config
+	documents []Document
+	chunks    []Chunk
+	bm25      *bm25Index
+	embedder  Embedder
+}
+
+type indexData struct {
+	Config    Config     `json:"config"`
+	Documents []Document `json:"documents"`
+	Chunks    []Chunk    `json:"chunks"`
+}
+
+// Create makes a new, empty knowledge base under root
+func Create(root string, cfg Config) (*KnowledgeBase, error) {
+	if !validName.MatchString(cfg.Name) {
+		return nil, fmt.Errorf("invalid knowledge base name %q", cfg.Name)
+	}
+	if cfg.Embedder == "" {
+		cfg.Embedder = EmbedderNone
+	}
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize, cfg.ChunkOverlap = defaultChunkSize, defaultChunkOverlap
+	}
+	if err := (&textsplit.Splitter{ChunkSize: cfg.ChunkSize, ChunkOverlap: cfg.ChunkOverlap}).Validate(); err != nil {
+		return nil, err
+	}
+	embedder, err := NewEmbedder(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cfg.CreatedAt = time.Now().UTC()
+
+	dir := filepath.Join(root, cfg.Name)
+	if _, err := os.Stat(filepath.Join(dir, indexFile)); err == nil {
+		return nil, fmt.Errorf("knowledge base %s already exists", cfg.Name)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create knowledge base: %w", err)
+	}
+	kb := &KnowledgeBase{dir: dir, config: cfg, embedder: embedder, bm25: newBM25Index(nil)}
+	if err := kb.Save(); err != nil {
+		return nil, err
+	}
+	return kb, nil
+}
+
+type cacheEntry struct {
+	modTime time.Time
+	size    int64
+	kb      *KnowledgeBase
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[string]cacheEntry)
+)
+
+// Open loads a knowledge base. Loaded knowledge bases are cached until their file changes,
+// so retrieval nodes in loops do not re-read the index.
+func Open(root, name string) (*KnowledgeBase, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("invalid knowledge base name %q", name)
+	}
+	dir := filepath.Join(root, name)
+	path := filepath.Join(dir, indexFile)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("knowledge base %s not found in %s", name, root)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if entry, ok := cache[path]; ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.kb, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read knowledge base: %w", err)
+	}
+	var idx indexData
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("failed to decode knowledge base %s: %w", name, err)
+	}
+	embedder, err := NewEmbedder(idx.Config)
+	if err != nil {
+		return nil, err
+	}
+	kb := &KnowledgeBase{dir: dir, config: idx.Config, documents: idx.Documents, chunks: idx.Chunks, embedder: embedder}
+	kb.reindex()
+	cache[path] = cacheEntry{modTime: info.ModTime(), size: info.Size(), kb: kb}
+	return kb, nil
+}
+
+// List returns the names of the knowledge bases under root
+func List(root string) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if _, err := os.Stat(filepath.Join(root, e.Name(), indexFile)); e.IsDir() && err == nil {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Remove deletes a knowledge base from disk
+func Remove(root, name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid knowledge base name %q", name)
+	}
+	dir := filepath.Join(root, name)
+	if _, err := os.Stat(filepath.Join(dir, indexFile)); err != nil {
+		return fmt.Errorf("knowledge base %s not found in %s", name, root)
+	}
+	cacheMu.Lock()
+	delete(cache, filepath.Join(dir, indexFile))
+	cacheMu.Unlock()
+	return os.RemoveAll(dir)
+}
+
+// Config returns the knowledge base's settings
+func (kb *KnowledgeBase) Config() Config {
+	kb.mu.RLock()
+	defer kb.mu.RUnlock()
+	return kb.config
+}
+
+// Documents returns the ingested documents in ingestion order
+func (kb *KnowledgeBase) Documents() []Document {
+	kb.mu.RLock()
+	defer kb.mu.RUnlock()
+	return append([]Document(nil), kb.documents...)
+}
+
+// Document finds a document by ID, name or source; a source may also be given as a path
+// relative to the working directory
+func (kb *KnowledgeBase) Document(key string) (Document, bool) {
+	path := fileSource(key)
+	kb.mu.RLock()
+	defer kb.mu.RUnlock()
+	for _, d := range kb.documents {
+		if d.ID == key || d.Name == key || d.Source == key || d.Source == path {
+			return d, true
+		}
+	}
+	return Document{}, false
+}
+
+// ChunkCount returns the number of indexed chunks
+func (kb *KnowledgeBase) ChunkCount() int {
+	kb.mu.RLock()
+	defer kb.mu.RUnlock()
+	return len(kb.chunks)
+}
+
+// Save writes the knowledge base to disk atomically
+func (kb *KnowledgeBase) Save() error {
+	kb.mu.RLock()
+	data, err := json.Marshal(indexData{Config: kb.config, Documents: kb.documents, Chunks: kb.chunks})
+	kb.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode knowledge base: %w", err)
+	}
+
+	path := filepath.Join(kb.dir, indexFile)
+	tmp, err := os.CreateTemp(kb.dir, indexFile+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write knowledge base: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write knowledge base: %w", err)
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write knowledge base: %w", err)
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		cacheMu.Lock()
+		cache[path] = cacheEntry{modTime: info.ModTime(), size: info.Size(), kb: kb}
+		cacheMu.Unlock()
+	}
+	return nil
+}
+
+// DocumentInput is extracted text to ingest
+type DocumentInput struct {
+	Name     string
+	Source   string // Identifies the document; ingesting the same source again replaces it
+	Format   string
+	Text     string
+	Pages    []document.Page
+	Sections []document.Section
+	Metadata map[string]interface{}
+}
+
+// fileSource identifies a file by its absolute path, so the source does not depend on the
+// directory the file was ingested from
+func fileSource(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// AddFile extracts, chunks and indexes a file; see AddText. Call Save to persist the change.
+func (kb *KnowledgeBase) AddFile(ctx context.Context, path string) (Document, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Document{}, false, err
+	}
+	doc, err := document.Extract(data, filepath.Base(path), "")
+	if err != nil {
+		return Document{}, false, fmt.Errorf("%s: %w", path, err)
+	}
+	added, changed, err := kb.AddText(ctx, DocumentInput{
+		Name:     filepath.Base(path),
+		Source:   fileSource(path),
+		Format:   doc.Format,
+		Text:     doc.Text,
+		Pages:    doc.Pages,
+		Sections: doc.Sections,
+	})
+	if err != nil {
+		return Document{}, false, err
+	}
+
+	// Older bases stored the path as given; replace such an entry instead of keeping both
+	if legacy := filepath.ToSlash(filepath.Clean(path)); legacy != added.Source {
+		removed := false
+		kb.mu.Lock()
+		for _, d := range kb.documents {
+			if d.Source == legacy {
+				kb.removeLocked(d.ID)
+				removed = true
+				break
+			}
+		}
+		kb.mu.Unlock()
+		if removed {
+			kb.reindex()
+			changed = true
+		}
+	}
+	return added, changed, nil
+}
+
+// AddText chunks, embeds and indexes a document, replacing an earlier version from the
+// same source. A source whose text has not changed is left as is and reported with
+// added == false. Call Save to persist the change.
+func (kb *KnowledgeBase) AddText(ctx context.Context, in DocumentInput) (doc Document, added bool, err error) {
+	source := in.Source
+	if source == "" {
+		source = in.Name
+	}
+	if source == "" {
+		return Document{}, false, fmt.Errorf("document needs a name or source")
+	}
+	idSum := sha256.Sum256([]byte(source))
+	textSum := sha256.Sum256([]byte(in.Text))
+	doc = Document{
+		ID:       hex.EncodeToString(idSum[:8]),
+		Name:     in.Name,
+		Source:   source,
+		Format:   in.Format,
+		Hash:     hex.EncodeToString(textSum[:]),
+		AddedAt:  time.Now().UTC(),
+		Metadata: in.Metadata,
+	}
+	if doc.Name == "" {
+		doc.Name = filepath.Base(source)
+	}
+	if existing, ok := kb.Document(doc.ID); ok && existing.Hash == doc.Hash {
+		return existing, false, nil
+	}
+
+	kb.mu.RLock()
+	cfg, embedder := kb.config, kb.embedder
+	kb.mu.RUnlock()
+
+	splitter := &textsplit.Splitter{ChunkSize: cfg.ChunkSize, ChunkOverlap: cfg.ChunkOverlap}
+	var pieces []textsplit.Chunk
+	if in.Format == document.FormatMarkdown {
+		pieces, err = splitter.SplitMarkdown(in.Text)
+	} else {
+		pieces, err = splitter.Split(in.Text)
+	}
+	if err != nil {
+		return Document{}, false, err
+	}
+
+	chunks := make([]Chunk, len(pieces))
+	texts := make([]string, len(pieces))
+	for i, p := range pieces {
+		chunks[i] = Chunk{
+			ID:         fmt.Sprintf("%s-%d", doc.ID, i),
+			DocumentID: doc.ID,
+			Index:      i,
+			Text:       p.Text,
+			Start:      p.Start,
+			End:        p.End,
+			Page:       pageAt(in.Pages, p.Start),
+			Section:    sectionAt(in.Sections, p.Headings, p.Start),
+		}
+		texts[i] = p.Text
+	}
+	if embedder != nil && len(texts) > 0 {
+		vectors, err := embedder.Embed(ctx, texts)
+		if err != nil {
+			return Document{}, false, fmt.Errorf("failed to embed %s: %w", doc.Name, err)
+		}
+		for i := range chunks {
+			chunks[i].Embedding = vectors[i]
+		}
+	}
+	doc.Chunks = len(chunks)
+
+	kb.mu.Lock()
+	kb.removeLocked(doc.ID)
+	kb.documents = append(kb.documents, doc)
+	kb.chunks = append(kb.chunks, chunks...)
+	kb.mu.Unlock()
+	kb.reindex()
+	return doc, true, nil
+}
+
+// pageAt returns the page containing a text offset, or 0 for unpaged documents
+func pageAt(pages []document.Page, offset int) int {
+	page := 0
+	for _, p := range pages {
+		if p.Offset > offset {
+			break
+		}
+		page = p.Number
+	}
+	return page
+}
+
+// sectionAt returns the heading a chunk falls under
+func sectionAt(sections []document.Section, headings []string, offset int) string {
+	if len(headings) > 0 {
+		return strings.Join(headings, " > ")
+	}
+	title := ""
+	for _, s := range sections {
+		if s.Offset > offset {
+			break
+		}
+		title = s.Title
+	}
+	return title
+}
+
+// DeleteDocument removes a document by ID, name or source. Call Save to persist the change.
+func (kb *KnowledgeBase) DeleteDocument(key string) (Document, error) {
+	doc, ok := kb.Document(key)
+	if !ok {
+		return Document{}, fmt.Errorf("no document %q in knowledge base %s", key, kb.Config().Name)
+	}
+	kb.mu.Lock()
+	kb.removeLocked(doc.ID)
+	kb.mu.Unlock()
+	kb.reindex()
+	return doc, nil
+}
+
+func (kb *KnowledgeBase) removeLocked(id string) {
+	docs := kb.documents[:0]
+	for _, d := range kb.documents {
+		if d.ID != id {
+			docs = append(docs, d)
+		}
+	}
+	kb.documents = docs
+	chunks := kb.chunks[:0]
+	for _, c := range kb.chunks {
+		if c.DocumentID != id {
+			chunks = append(chunks, c)
+		}
+	}
+	kb.chunks = chunks
+}
+
+// SetEmbedder changes the embedding settings. Existing vectors no longer match, so call
+// Reembed before searching.
+func (kb *KnowledgeBase) SetEmbedder(name, model string, dimensions int) error {
+	cfg := kb.Config()
+	cfg.Embedder, cfg.Model, cfg.Dimensions = name, model, dimensions
+	embedder, err := NewEmbedder(cfg)
+	if err != nil {
+		return err
+	}
+	kb.mu.Lock()
+	kb.config, kb.embedder = cfg, embedder
+	kb.mu.Unlock()
+	return nil
+}
+
+// Reembed recomputes the vectors of every chunk with the current embedder.
+// Call Save to persist the change.
+func (kb *KnowledgeBase) Reembed(ctx context.Context) error {
+	kb.mu.RLock()
+	embedder := kb.embedder
+	texts := make([]string, len(kb.chunks))
+	for i, c := range kb.chunks {
+		texts[i] = c.Text
+	}
+	kb.mu.RUnlock()
+
+	var vectors [][]float32
+	if embedder != nil && len(texts) > 0 {
+		var err error
+		if vectors, err = embedder.Embed(ctx, texts); err != nil {
+			return err
+		}
+	}
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	if len(kb.chunks) != len(texts) {
+		return fmt.Errorf("knowledge base changed while re-embedding")
+	}
+	for i := range kb.chunks {
+		kb.chunks[i].Embedding = nil
+		if vectors != nil {
+			kb.chunks[i].Embedding = vectors[i]
+		}
+	}
+	return nil
+}
+
+// reindex rebuilds the keyword index after the chunks change
+func (kb *KnowledgeBase) reindex() {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	texts := make([]string, len(kb.chunks))
+	for i, c := range kb.chunks {
+		texts[i] = c.Text
+	}
+	kb.bm25 = newBM25Index(texts)
+}
@@ -0,0 +1,289 @@
+package knowledge
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Hello, 世界! Go2 isn't_bad")
+	want := []string{"hello", "世", "界", "go2", "isn", "t", "bad"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize = %q, want %q", got, want)
+	}
+}
+
+func TestBM25Score(t *testing.T) {
+	idx := newBM25Index([]string{
+		"the cat sat",
+		"the dog barked at the cat cat",
+		"birds fly",
+	})
+	scores := idx.score("cat")
+	if len(scores) != 2 || scores[2] != 0 {
+		t.Fatalf("scores = %v, want chunks 0 and 1 only", scores)
+	}
+
+	// Lengths 3, 7 and 2 average 4; "cat" is in 2 of 3 chunks
+	idf := math.Log(1 + (3-2+0.5)/(2+0.5))
+	want := idf * 1 * (bm25K1 + 1) / (1 + bm25K1*(1-bm25B+bm25B*3/4.0))
+	if math.Abs(scores[0]-want) > 1e-9 {
+		t.Errorf("score of chunk 0 = %v, want %v", scores[0], want)
+	}
+
+	// A chunk matching more query terms ranks higher
+	if one := idx.score("sat cat"); one[0] <= one[1] {
+		t.Errorf("chunk with both terms should score higher: %v", one)
+	}
+	if !reflect.DeepEqual(idx.score("cat cat"), scores) {
+		t.Error("repeated query terms should count once")
+	}
+	if rare, common := idx.score("dog")[1], idx.score("the")[1]; rare <= common {
+		t.Errorf("rare term scored %v, common term %v", rare, common)
+	}
+	if len(idx.score("unicorn")) != 0 {
+		t.Error("unknown terms should match nothing")
+	}
+}
+
+func TestHashEmbedder(t *testing.T) {
+	e := &HashEmbedder{Dimensions: 64}
+	vecs, err := e.Embed(context.Background(), []string{
+		"goroutines are cheap threads",
+		"goroutines are cheap threads",
+		"cheap goroutines and threads",
+		"refund policy for customers",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(vecs[0], vecs[1]) {
+		t.Error("embedding is not deterministic")
+	}
+	if norm := dot(vecs[0], vecs[0]); math.Abs(norm-1) > 1e-6 {
+		t.Errorf("embedding is not normalized: |v|^2 = %v", norm)
+	}
+	if similar, unrelated := dot(vecs[0], vecs[2]), dot(vecs[0], vecs[3]); similar <= unrelated {
+		t.Errorf("overlapping texts scored %v, unrelated %v", similar, unrelated)
+	}
+}
+
+// newTestBase creates a knowledge base with a hash embedder holding three small documents
+func newTestBase(t *testing.T) *KnowledgeBase {
+	t.Helper()
+	kb, err := Create(t.TempDir(), Config{Name: "test", Embedder: EmbedderHash, Dimensions: 128, ChunkSize: 200, ChunkOverlap: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for source, text := range map[string]string{
+		"go.md":      "Goroutines are lightweight threads managed by the Go runtime.",
+		"refunds.md": "Refunds are issued within 14 days of the purchase.",
+		"shipping.md": "Orders ship within 2 business days. Shipping is free for orders over 50 dollars, " +
+			"and express shipping is available.",
+	} {
+		if _, _, err := kb.AddText(context.Background(), DocumentInput{Source: source, Text: source + ": " + text}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return kb
+}
+
+func TestSearchModes(t *testing.T) {
+	kb := newTestBase(t)
+	ctx := context.Background()
+
+	bm25, err := kb.Search(ctx, "shipping refunds", SearchOptions{Mode: ModeBM25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bm25) != 2 || bm25[0].Score != 1 {
+		t.Fatalf("bm25 results = %+v, want 2 with the best scaled to 1", bm25)
+	}
+	for _, r := range bm25 {
+		if r.VectorScore != 0 || r.Score != r.KeywordScore {
+			t.Errorf("bm25 mode used vectors: %+v", r)
+		}
+	}
+
+	vector, err := kb.Search(ctx, "goroutines threads", SearchOptions{Mode: ModeVector, TopK: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vector) != 1 || vector[0].Document.Source != "go.md" || vector[0].Score != vector[0].VectorScore {
+		t.Errorf("vector results = %+v, want go.md scored by similarity", vector)
+	}
+
+	// Hybrid is the default with an embedder and mixes both scores by the vector weight
+	hybrid, err := kb.Search(ctx, "refunds within days", SearchOptions{VectorWeight: 0.4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hybrid) == 0 || hybrid[0].Document.Source != "refunds.md" {
+		t.Fatalf("hybrid results = %+v, want refunds.md first", hybrid)
+	}
+	for _, r := range hybrid {
+		if want := 0.4*r.VectorScore + 0.6*r.KeywordScore; math.Abs(r.Score-want) > 1e-9 {
+			t.Errorf("hybrid score %v, want %v", r.Score, want)
+		}
+	}
+
+	if filtered, _ := kb.Search(ctx, "refunds within days", SearchOptions{VectorWeight: 0.4, Threshold: hybrid[0].Score}); len(filtered) != 1 {
+		t.Errorf("threshold kept %d results, want 1", len(filtered))
+	}
+	if _, err := kb.Search(ctx, "x", SearchOptions{Mode: "fuzzy"}); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestAddTextReplacesSource(t *testing.T) {
+	kb := newTestBase(t)
+	ctx := context.Background()
+
+	same, added, err := kb.AddText(ctx, DocumentInput{Source: "refunds.md", Text: "refunds.md: Refunds are issued within 14 days of the purchase."})
+	if err != nil || added {
+		t.Fatalf("unchanged text was re-added (added=%v, err=%v)", added, err)
+	}
+
+	doc, added, err := kb.AddText(ctx, DocumentInput{Source: "refunds.md", Text: "Store credit is offered instead of cash returns."})
+	if err != nil || !added {
+		t.Fatalf("changed text was not added (added=%v, err=%v)", added, err)
+	}
+	if doc.ID != same.ID || len(kb.Documents()) != 3 {
+		t.Errorf("document was not replaced in place: %+v, %d documents", doc, len(kb.Documents()))
+	}
+	if results, _ := kb.Search(ctx, "refunds", SearchOptions{Mode: ModeBM25}); len(results) != 0 {
+		t.Errorf("old chunks are still indexed: %+v", results)
+	}
+	if results, _ := kb.Search(ctx, "store credit", SearchOptions{Mode: ModeBM25}); len(results) != 1 {
+		t.Errorf("new chunks are not indexed: %+v", results)
+	}
+}
+
+func TestAddFileMigratesLegacySource(t *testing.T) {
+	kb := newTestBase(t)
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "notes.md")
+	if err := os.WriteFile(path, []byte("# Notes\n\nChannels connect goroutines."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, err := filepath.Rel(cwd, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Older versions stored the path as given on the command line
+	legacy := filepath.ToSlash(filepath.Clean(rel))
+	if _, _, err := kb.AddText(ctx, DocumentInput{Name: "notes.md", Source: legacy, Text: "old notes"}); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, added, err := kb.AddFile(ctx, rel)
+	if err != nil || !added {
+		t.Fatalf("AddFile: added=%v, err=%v", added, err)
+	}
+	if doc.Source != filepath.ToSlash(path) {
+		t.Errorf("source = %q, want the absolute path %q", doc.Source, path)
+	}
+	for _, d := range kb.Documents() {
+		if d.Source == legacy {
+			t.Errorf("legacy entry was kept: %+v", d)
+		}
+	}
+	if n := len(kb.Documents()); n != 4 {
+		t.Errorf("%d documents, want the 3 originals and the migrated file", n)
+	}
+	if got, ok := kb.Document(rel); !ok || got.ID != doc.ID {
+		t.Errorf("lookup by relative path found %+v", got)
+	}
+
+	if _, added, err := kb.AddFile(ctx, path); err != nil || added {
+		t.Errorf("re-adding by absolute path: added=%v, err=%v", added, err)
+	}
+}
+
+func TestOpenCachesUntilFileChanges(t *testing.T) {
+	root := t.TempDir()
+	created, err := Create(root, Config{Name: "cached"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := created.AddText(context.Background(), DocumentInput{Source: "a", Text: "alpha"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := created.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := Open(root, "cached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := Open(root, "cached"); again != first {
+		t.Error("unchanged knowledge base was loaded again")
+	}
+
+	// A file with the same size but a new modification time is reloaded
+	path := filepath.Join(root, "cached", indexFile)
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	touched, err := Open(root, "cached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if touched == first {
+		t.Error("modified knowledge base was served from the cache")
+	}
+
+	// Saving caches the saved instance
+	if _, _, err := touched.AddText(context.Background(), DocumentInput{Source: "b", Text: "beta"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := touched.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if saved, _ := Open(root, "cached"); saved != touched {
+		t.Error("saved knowledge base was loaded again")
+	}
+
+	// A file whose size changed is reloaded even when its modification time did not
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := Open(root, "cached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded == touched || len(reloaded.Documents()) != 2 {
+		t.Errorf("resized knowledge base was not reloaded (%d documents)", len(reloaded.Documents()))
+	}
+
+	if err := Remove(root, "cached"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(root, "cached"); err == nil {
+		t.Error("removed knowledge base is still served from the cache")
+	}
+}
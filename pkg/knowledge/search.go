@@ -0,0 +1,123 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Search modes
+const (
+	ModeBM25   = "bm25"
+	ModeVector = "vector"
+	ModeHybrid = "hybrid"
+)
+
+const defaultVectorWeight = 0.7
+
+// SearchOptions control a query. Scores are in [0, 1]: BM25 scores are divided by the best
+// score of the query, vector scores are cosine similarities, and hybrid scores are
+// VectorWeight*vector + (1-VectorWeight)*keyword.
+type SearchOptions struct {
+	Mode         string  // bm25, vector or hybrid; defaults to hybrid when the knowledge base has an embedder
+	TopK         int     // Defaults to 4
+	Threshold    float64 // Minimum score
+	VectorWeight float64 // Hybrid mode; defaults to 0.7
+}
+
+// Result is a ranked chunk
+type Result struct {
+	Chunk        Chunk
+	Document     Document
+	Score        float64
+	KeywordScore float64
+	VectorScore  float64
+}
+
+// Search ranks the chunks of the knowledge base against query
+func (kb *KnowledgeBase) Search(ctx context.Context, query string, opts SearchOptions) ([]Result, error) {
+	kb.mu.RLock()
+	name, embedder := kb.config.Name, kb.embedder
+	kb.mu.RUnlock()
+
+	mode := opts.Mode
+	if mode == "" {
+		mode = ModeBM25
+		if embedder != nil {
+			mode = ModeHybrid
+		}
+	}
+	if opts.TopK <= 0 {
+		opts.TopK = 4
+	}
+	weight := opts.VectorWeight
+	if weight <= 0 || weight > 1 {
+		weight = defaultVectorWeight
+	}
+
+	var queryVec []float32
+	switch mode {
+	case ModeBM25:
+	case ModeVector, ModeHybrid:
+		if embedder == nil {
+			return nil, fmt.Errorf("knowledge base %s has no embedder; use bm25 mode or re-embed it", name)
+		}
+		vectors, err := embedder.Embed(ctx, []string{query})
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed query: %w", err)
+		}
+		queryVec = vectors[0]
+	default:
+		return nil, fmt.Errorf("unknown search mode '%s'", mode)
+	}
+
+	kb.mu.RLock()
+	defer kb.mu.RUnlock()
+
+	keyword := map[int]float64{}
+	if mode != ModeVector {
+		keyword = kb.bm25.score(query)
+		best := 0.0
+		for _, s := range keyword {
+			if s > best {
+				best = s
+			}
+		}
+		for i, s := range keyword {
+			keyword[i] = s / best
+		}
+	}
+
+	docs := make(map[string]Document, len(kb.documents))
+	for _, d := range kb.documents {
+		docs[d.ID] = d
+	}
+
+	var results []Result
+	for i, c := range kb.chunks {
+		r := Result{Chunk: c, Document: docs[c.DocumentID], KeywordScore: keyword[i]}
+		if queryVec != nil {
+			if sim := dot(queryVec, c.Embedding); sim > 0 {
+				r.VectorScore = sim
+			}
+		}
+		switch mode {
+		case ModeBM25:
+			r.Score = r.KeywordScore
+		case ModeVector:
+			r.Score = r.VectorScore
+		default:
+			r.Score = weight*r.VectorScore + (1-weight)*r.KeywordScore
+		}
+		if r.Score <= 0 || r.Score < opts.Threshold {
+			continue
+		}
+		results = append(results, r)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > opts.TopK {
+		results = results[:opts.TopK]
+	}
+	return results, nil
+}
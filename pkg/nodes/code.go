@@ -2,49 +2,49 @@ package nodes
 
 import (
 	"dify-vnext-go/pkg/engine"
+	"encoding/json"
 	"fmt"
-	"time"
+	"strings"
 
 	"github.com/dop251/goja"
 )
 
+// CodeNode runs JavaScript. Scripts that define main(inputs) must return an object,
+// which is validated against the node's declared outputs; other scripts return their
+// last expression value (wrapped in "result" unless it is an object).
 type CodeNode struct {
 	BaseNode
-	Code string
+	Code          string
+	Limits        CodeLimits
+	LibDir        string            // Directory require() loads shared JS modules from, relative to the workflow file
+	Outputs       map[string]string // Declared output name -> type
+	LegacyGlobals bool              // Also expose every input as a bare global, as older scripts expect
 }
 
-func NewCodeNode(id string, config map[string]interface{}) *CodeNode {
+// NewCodeNode creates a Code node. dir is the directory of the workflow file, which a
+// relative lib_dir and the default lib directory are resolved against.
+func NewCodeNode(id string, config map[string]interface{}, outputs map[string]string, dir string) *CodeNode {
 	code, _ := config["code"].(string)
+	libDir, _ := config["lib_dir"].(string)
+	if libDir == "" {
+		libDir = defaultLibDir(dir)
+	} else {
+		libDir = resolvePath(dir, libDir)
+	}
+	legacyGlobals, _ := config["legacy_globals"].(bool)
 	return &CodeNode{
-		BaseNode: NewBaseNode(id, "Code"),
-		Code:     code,
+		BaseNode:      NewBaseNode(id, "Code"),
+		Code:          code,
+		Limits:        parseCodeLimits(config),
+		LibDir:        libDir,
+		Outputs:       outputs,
+		LegacyGlobals: legacyGlobals,
 	}
 }
 
 func (n *CodeNode) Execute(ctx *engine.NodeContext) (map[string]interface{}, error) {
 	fmt.Printf("[%s] Executing Code...\n", n.ID())
 
-	vm := goja.New()
-
-	// Inject inputs into JS context
-	// Dify Python injects inputs as a dictionary named 'input' (or similar, let's check docs/code if needed)
-	// But for our examples, we used 'input.security', so we MUST inject 'input' as a map.
-	// Also, we previously injected them as globals. Let's do BOTH for backward compatibility/flexibility.
-
-	vm.Set("input", ctx.Inputs)
-
-	for k, v := range ctx.Inputs {
-		vm.Set(k, v)
-	}
-
-	// Inject helper functions
-	vm.Set("sleep", func(ms int64) {
-		time.Sleep(time.Duration(ms) * time.Millisecond)
-	})
-	vm.Set("print", func(msg interface{}) {
-		fmt.Printf("[%s] JS Log: %v\n", n.ID(), msg)
-	})
-
 	// Determine code to run
 	// 1. Use code from Config (n.Code)
 	// 2. If empty, check if "code" is provided in Inputs (dynamic code)
@@ -61,31 +61,216 @@ func (n *CodeNode) Execute(ctx *engine.NodeContext) (map[string]interface{}, err
 		return nil, fmt.Errorf("no code provided for CodeNode %s", n.ID())
 	}
 
-	// Execute code
-	// We assume the code sets a variable 'output' or returns a value.
-	// For MVP, let's assume the code is just a script that returns an object.
+	instrument := n.Limits.MaxSteps > 0
+	key := scriptKey(n.ID(), codeToRun, instrument)
+
+	// Scripts known to define main() run on pooled runtimes, each execution in a fresh
+	// top-level scope. The first run of a script, legacy scripts and scripts reading inputs
+	// as globals (which would persist) get a fresh runtime.
+	_, hasMain := mainScripts.Load(key)
+	var sb *sandbox
+	var program *goja.Program
+	var err error
+	pooled := hasMain && codeVMPooling && !n.LegacyGlobals
+	if pooled {
+		if sb = acquireVM(key); sb == nil {
+			scoped, err := compileCached(key+":scope", n.ID(), scopeSource(codeToRun), instrument)
+			if err != nil {
+				return nil, fmt.Errorf("code compilation failed: %w", err)
+			}
+			if sb, err = newScopedSandbox(scoped); err != nil {
+				return nil, fmt.Errorf("code execution failed: %w", err)
+			}
+		}
+	} else {
+		if program, err = compileCached(key, n.ID(), codeToRun, instrument); err != nil {
+			return nil, fmt.Errorf("code compilation failed: %w", err)
+		}
+		sb = newSandbox()
+	}
+	vm := sb.vm
+
+	// Inject inputs into JS context as the 'input' map.
+	// Older scripts also read inputs as bare globals; that is opt-in via legacy_globals.
+	vm.Set("input", ctx.Inputs)
+
+	if n.LegacyGlobals {
+		for k, v := range ctx.Inputs {
+			vm.Set(k, v)
+		}
+	}
 
-	// print(codeToRun)
+	// Enforce sandbox limits; the helpers act on this run from here on
+	stop := n.applyLimits(ctx.Ctx, sb)
+	stopped := false
+	defer func() {
+		if !stopped {
+			stop()
+		}
+	}()
 
-	val, err := vm.RunString(codeToRun)
+	var val goja.Value
+	if pooled {
+		val, err = sb.scope(goja.Undefined())
+	} else {
+		val, err = vm.RunProgram(program)
+	}
 	if err != nil {
+		if limitErr := n.limitError(err); limitErr != nil {
+			return nil, limitErr
+		}
 		return nil, fmt.Errorf("code execution failed: %w", err)
 	}
 
-	// Convert result to map
-	// If the result is an object, we return it as outputs.
-	// If it's a primitive, we wrap it in "result".
+	mainFn, ok := goja.AssertFunction(vm.Get("main"))
+	if pooled {
+		mainFn, ok = goja.AssertFunction(val)
+	}
+	var outputs map[string]interface{}
+	if ok {
+		mainScripts.Store(key, true)
+		ret, err := mainFn(goja.Undefined(), vm.Get("input"))
+		if err != nil {
+			if limitErr := n.limitError(err); limitErr != nil {
+				return nil, limitErr
+			}
+			return nil, fmt.Errorf("main() failed: %w", err)
+		}
 
-	export := val.Export()
-	outputs := make(map[string]interface{})
+		// The runtime is healthy; stop the limit watcher before handing it to the next execution
+		stop()
+		stopped = true
+		export := ret.Export()
+		if pooled {
+			releaseVM(key, sb)
+		}
 
-	if m, ok := export.(map[string]interface{}); ok {
+		m, ok := export.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("main() in node %s must return an object, got %s", n.ID(), jsTypeOf(export))
+		}
+		if err := n.validateOutputs(m); err != nil {
+			return nil, err
+		}
 		outputs = m
 	} else {
-		outputs["result"] = export
+		// Legacy contract: the last expression value is the result.
+		// If it is an object, we return it as outputs; a primitive is wrapped in "result".
+		export := val.Export()
+		if m, ok := export.(map[string]interface{}); ok {
+			outputs = m
+		} else {
+			outputs = map[string]interface{}{"result": export}
+		}
+	}
+
+	if n.Limits.MaxOutputBytes > 0 {
+		size := len(fmt.Sprintf("%v", outputs))
+		if encoded, err := json.Marshal(outputs); err == nil {
+			size = len(encoded)
+		}
+		if size > n.Limits.MaxOutputBytes {
+			return nil, &CodeLimitError{NodeID: n.ID(), Limit: LimitOutputSize, Max: n.Limits.MaxOutputBytes}
+		}
 	}
 
 	fmt.Printf("[%s] Code Result: %v\n", n.ID(), outputs)
 
 	return outputs, nil
 }
+
+// validateOutputs checks main()'s return value against the declared outputs.
+// Without declared outputs, any object is accepted.
+func (n *CodeNode) validateOutputs(outputs map[string]interface{}) error {
+	if len(n.Outputs) == 0 {
+		return nil
+	}
+	for name, typ := range n.Outputs {
+		val, ok := outputs[name]
+		if !ok {
+			return fmt.Errorf("main() in node %s did not return declared output %q", n.ID(), name)
+		}
+		if !matchesOutputType(val, typ) {
+			return fmt.Errorf("output %q of node %s must be %s, got %s", name, n.ID(), typ, jsTypeOf(val))
+		}
+	}
+	for name := range outputs {
+		if _, ok := n.Outputs[name]; !ok {
+			return fmt.Errorf("main() in node %s returned undeclared output %q", n.ID(), name)
+		}
+	}
+	return nil
+}
+
+// matchesOutputType reports whether an exported JS value has the declared type.
+// Types follow the DSL: string, number, boolean, object, array/list (optionally
+// with an element type, e.g. "array[string]" or "list<message>"), and any.
+func matchesOutputType(val interface{}, typ string) bool {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+
+	elemType := ""
+	for _, prefix := range []string{"array", "list"} {
+		if strings.HasPrefix(typ, prefix) {
+			elemType = strings.Trim(strings.TrimPrefix(typ, prefix), "[]<>")
+			typ = "array"
+		}
+	}
+
+	if val == nil {
+		return typ == "any"
+	}
+
+	switch typ {
+	case "string":
+		_, ok := val.(string)
+		return ok
+	case "number", "integer":
+		switch val.(type) {
+		case int64, float64, int:
+			return true
+		}
+		return false
+	case "boolean", "bool":
+		_, ok := val.(bool)
+		return ok
+	case "object":
+		_, ok := val.(map[string]interface{})
+		return ok
+	case "array":
+		list, ok := val.([]interface{})
+		if !ok {
+			return false
+		}
+		if elemType == "string" || elemType == "number" || elemType == "boolean" || elemType == "object" {
+			for _, item := range list {
+				if !matchesOutputType(item, elemType) {
+					return false
+				}
+			}
+		}
+		return true
+	default:
+		// "any" and types the validator does not know about
+		return true
+	}
+}
+
+// jsTypeOf names an exported JS value's type for error messages
+func jsTypeOf(val interface{}) string {
+	switch val.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case int64, float64, int:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", val)
+	}
+}
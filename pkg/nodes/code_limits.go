@@ -0,0 +1,238 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/parser"
+)
+
+// Names of the sandbox limits reported in CodeLimitError
+const (
+	LimitTimeout    = "timeout"
+	LimitCallStack  = "max_call_stack"
+	LimitSteps      = "max_steps"
+	LimitOutputSize = "max_output_bytes"
+)
+
+// stepHook is the host function injected into instrumented scripts
+const stepHook = "__vnext_step"
+
+// CodeLimits bounds the resources a CodeNode script may use. Zero disables a limit.
+type CodeLimits struct {
+	Timeout        time.Duration // Wall-clock time per execution
+	MaxCallStack   int           // Maximum JS call depth
+	MaxSteps       int64         // Loop iterations plus function calls
+	MaxOutputBytes int           // Size of the JSON-encoded outputs
+}
+
+// DefaultCodeLimits are applied unless the node config overrides them
+var DefaultCodeLimits = CodeLimits{
+	Timeout:        30 * time.Second,
+	MaxCallStack:   1024,
+	MaxOutputBytes: 1 << 20,
+}
+
+// CodeLimitError is returned when a script exceeds one of its sandbox limits
+type CodeLimitError struct {
+	NodeID string
+	Limit  string      // One of the Limit* constants
+	Max    interface{} // The configured limit
+}
+
+func (e *CodeLimitError) Error() string {
+	return fmt.Sprintf("code node %s exceeded %s limit (%v)", e.NodeID, e.Limit, e.Max)
+}
+
+func parseCodeLimits(config map[string]interface{}) CodeLimits {
+	limits := DefaultCodeLimits
+	limits.Timeout = configDuration(config, "timeout", limits.Timeout)
+	limits.MaxCallStack = configInt(config, "max_call_stack", limits.MaxCallStack)
+	limits.MaxSteps = int64(configInt(config, "max_steps", int(limits.MaxSteps)))
+	limits.MaxOutputBytes = configInt(config, "max_output_bytes", limits.MaxOutputBytes)
+	return limits
+}
+
+// codeRun is the execution a sandbox is serving. The helpers installed in the runtime act
+// on the current run, so pooled runtimes install them only once.
+type codeRun struct {
+	node    *CodeNode
+	parent  context.Context       // The workflow's context
+	ctx     context.Context       // Ends at the timeout or when the workflow is cancelled
+	steps   int64                 // Step hook calls so far
+	modules map[string]goja.Value // require() cache for this execution
+}
+
+// interrupt stops the script, reporting why the run ended
+func (r *codeRun) interrupt(vm *goja.Runtime) {
+	if r.parent.Err() != nil {
+		vm.Interrupt(r.parent.Err())
+	} else {
+		vm.Interrupt(&CodeLimitError{NodeID: r.node.ID(), Limit: LimitTimeout, Max: r.node.Limits.Timeout})
+	}
+}
+
+// installLimits adds the step hook called by instrumented scripts and the interruptible sleep helper
+func (sb *sandbox) installLimits() {
+	sb.vm.Set(stepHook, func() {
+		run := sb.run
+		run.steps++
+		if run.node.Limits.MaxSteps > 0 && run.steps > run.node.Limits.MaxSteps {
+			sb.vm.Interrupt(&CodeLimitError{NodeID: run.node.ID(), Limit: LimitSteps, Max: run.node.Limits.MaxSteps})
+		}
+	})
+
+	sb.vm.Set("sleep", func(ms int64) {
+		// Wake up early when the run ends, and interrupt before returning to the script
+		run := sb.run
+		select {
+		case <-time.After(time.Duration(ms) * time.Millisecond):
+		case <-run.ctx.Done():
+			run.interrupt(sb.vm)
+		}
+	})
+}
+
+// applyLimits starts an execution of n in sb and enforces the node's limits on it.
+// The returned stop function must be called when the script returns.
+func (n *CodeNode) applyLimits(parent context.Context, sb *sandbox) func() {
+	var runCtx context.Context
+	var cancel context.CancelFunc
+	if n.Limits.Timeout > 0 {
+		runCtx, cancel = context.WithTimeout(parent, n.Limits.Timeout)
+	} else {
+		runCtx, cancel = context.WithCancel(parent)
+	}
+
+	if n.Limits.MaxCallStack > 0 {
+		sb.vm.SetMaxCallStackSize(n.Limits.MaxCallStack)
+	}
+
+	run := &codeRun{node: n, parent: parent, ctx: runCtx, modules: make(map[string]goja.Value)}
+	sb.run = run
+
+	// Interrupt the VM when the deadline passes or the workflow is cancelled
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-runCtx.Done():
+			run.interrupt(sb.vm)
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+		// Wait for the watcher so a late interrupt cannot hit a runtime returned to the pool
+		<-exited
+		cancel()
+	}
+}
+
+// limitError converts goja's uncatchable exceptions into a CodeLimitError where applicable
+func (n *CodeNode) limitError(err error) error {
+	var limitErr *CodeLimitError
+	if errors.As(err, &limitErr) {
+		return limitErr
+	}
+	var overflow *goja.StackOverflowError
+	if errors.As(err, &overflow) {
+		return &CodeLimitError{NodeID: n.ID(), Limit: LimitCallStack, Max: n.Limits.MaxCallStack}
+	}
+	return nil
+}
+
+// instrumentSteps inserts a call to the step hook at the start of every loop body and
+// function body, so MaxSteps can stop runaway scripts. Insertions never add lines,
+// so reported line numbers stay accurate (columns on instrumented lines shift).
+func instrumentSteps(src string) (string, error) {
+	program, err := parser.ParseFile(nil, "", src, 0)
+	if err != nil {
+		return "", err
+	}
+
+	// Byte offset -> text to insert there
+	inserts := make(map[int]string)
+	call := stepHook + "();"
+	addBody := func(body ast.Statement) {
+		if body == nil {
+			return
+		}
+		if block, ok := body.(*ast.BlockStatement); ok {
+			inserts[int(block.LeftBrace)] += call // LeftBrace is 1-based, so this is just after "{"
+			return
+		}
+		// Single-statement body: wrap it in a block
+		inserts[int(body.Idx0())-1] += "{" + call
+		inserts[int(body.Idx1())-1] = "}" + inserts[int(body.Idx1())-1]
+	}
+
+	visited := make(map[uintptr]bool)
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.Ptr:
+			if v.IsNil() || visited[v.Pointer()] {
+				return
+			}
+			visited[v.Pointer()] = true
+			switch node := v.Interface().(type) {
+			case *ast.ForStatement:
+				addBody(node.Body)
+			case *ast.ForInStatement:
+				addBody(node.Body)
+			case *ast.ForOfStatement:
+				addBody(node.Body)
+			case *ast.WhileStatement:
+				addBody(node.Body)
+			case *ast.DoWhileStatement:
+				addBody(node.Body)
+			case *ast.FunctionLiteral:
+				addBody(node.Body)
+			case *ast.ArrowFunctionLiteral:
+				if block, ok := node.Body.(*ast.BlockStatement); ok {
+					addBody(block)
+				}
+			}
+			walk(v.Elem())
+		case reflect.Interface:
+			if !v.IsNil() {
+				walk(v.Elem())
+			}
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				walk(v.Field(i))
+			}
+		case reflect.Slice:
+			for i := 0; i < v.Len(); i++ {
+				walk(v.Index(i))
+			}
+		}
+	}
+	walk(reflect.ValueOf(program))
+
+	offsets := make([]int, 0, len(inserts))
+	for off := range inserts {
+		offsets = append(offsets, off)
+	}
+	sort.Ints(offsets)
+
+	var sb strings.Builder
+	prev := 0
+	for _, off := range offsets {
+		sb.WriteString(src[prev:off])
+		sb.WriteString(inserts[off])
+		prev = off
+	}
+	sb.WriteString(src[prev:])
+	return sb.String(), nil
+}
@@ -0,0 +1,153 @@
+package nodes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// Loop iterations create a new CodeNode per item, so compiled programs and idle
+// runtimes are shared package-wide, keyed by node ID and source.
+const (
+	maxCachedPrograms   = 1024
+	maxIdleVMsPerScript = 64
+)
+
+var (
+	programCacheMu sync.Mutex
+	programCache   = make(map[string]*goja.Program)
+
+	codeVMPooling = true // Benchmarks turn this off to compare against fresh runtimes
+
+	vmPoolMu sync.Mutex
+	vmPool   = make(map[string][]*sandbox) // Script key -> idle runtimes with the scoped program loaded
+)
+
+// scriptKey identifies a compiled script; the node ID is part of it because
+// it is the file name in error positions
+func scriptKey(nodeID, src string, instrumented bool) string {
+	sum := sha256.Sum256([]byte(src))
+	key := nodeID + ":" + hex.EncodeToString(sum[:])
+	if instrumented {
+		key += ":steps"
+	}
+	return key
+}
+
+// compileCached compiles src once (instrumented for step counting if asked) and reuses the program afterwards
+func compileCached(key, nodeID, src string, instrument bool) (*goja.Program, error) {
+	programCacheMu.Lock()
+	program, ok := programCache[key]
+	programCacheMu.Unlock()
+	if ok {
+		return program, nil
+	}
+
+	if instrument {
+		instrumented, err := instrumentSteps(src)
+		if err != nil {
+			return nil, err
+		}
+		src = instrumented
+	}
+
+	// Compile under the node ID so errors point at "<node_id>:line:column"
+	program, err := goja.Compile(nodeID, src, false)
+	if err != nil {
+		return nil, err
+	}
+
+	programCacheMu.Lock()
+	if len(programCache) >= maxCachedPrograms {
+		// Dynamic code can produce unbounded distinct sources; start over rather than grow forever
+		programCache = make(map[string]*goja.Program)
+	}
+	programCache[key] = program
+	programCacheMu.Unlock()
+	return program, nil
+}
+
+// sandbox is a runtime with the CodeNode helpers installed. Pooled sandboxes hold the
+// script wrapped in a function (see scopeSource), so every execution runs the top-level
+// code in a fresh scope and gets a fresh main().
+type sandbox struct {
+	vm      *goja.Runtime
+	run     *codeRun        // Execution the sandbox is serving
+	scope   goja.Callable   // Runs the top-level code and returns main; nil unless pooled
+	globals map[string]bool // Global names after the helpers were installed; nil unless pooled
+}
+
+// scopeSource wraps a script so that running it returns a function evaluating the top-level
+// code in its own scope and returning main. The wrapper adds no lines, so line numbers in
+// errors are unchanged (columns on the first line shift).
+func scopeSource(src string) string {
+	return "(function() {" + src + "\n;return typeof main === \"function\" ? main : undefined;\n})"
+}
+
+// mainScripts records the script keys known to define main(), so they can use pooled runtimes
+var mainScripts sync.Map
+
+// newSandbox creates a runtime and installs the helpers, which read the current run
+func newSandbox() *sandbox {
+	sb := &sandbox{vm: goja.New()}
+	sb.installLimits()
+	sb.installRequire()
+	sb.vm.Set("print", func(msg interface{}) {
+		fmt.Printf("[%s] JS Log: %v\n", sb.run.node.ID(), msg)
+	})
+	return sb
+}
+
+// newScopedSandbox creates a sandbox for pooling a script known to define main()
+func newScopedSandbox(program *goja.Program) (*sandbox, error) {
+	sb := newSandbox()
+	sb.globals = map[string]bool{"input": true}
+	for _, name := range sb.vm.GlobalObject().Keys() {
+		sb.globals[name] = true
+	}
+	val, err := sb.vm.RunProgram(program)
+	if err != nil {
+		return nil, err
+	}
+	scope, ok := goja.AssertFunction(val)
+	if !ok {
+		return nil, fmt.Errorf("scoped script did not evaluate to a function")
+	}
+	sb.scope = scope
+	return sb, nil
+}
+
+// acquireVM returns an idle runtime for the script, if any
+func acquireVM(key string) *sandbox {
+	vmPoolMu.Lock()
+	defer vmPoolMu.Unlock()
+	idle := vmPool[key]
+	if len(idle) == 0 {
+		return nil
+	}
+	entry := idle[len(idle)-1]
+	vmPool[key] = idle[:len(idle)-1]
+	return entry
+}
+
+// releaseVM returns a healthy runtime to the pool for the script. Runtimes where the script
+// created globals (e.g. by assigning an undeclared variable) are dropped, since the next
+// execution would see them.
+func releaseVM(key string, entry *sandbox) {
+	for _, name := range entry.vm.GlobalObject().Keys() {
+		if !entry.globals[name] {
+			return
+		}
+	}
+	entry.run = nil
+	entry.vm.ClearInterrupt()
+	vmPoolMu.Lock()
+	defer vmPoolMu.Unlock()
+	if len(vmPool[key]) >= maxIdleVMsPerScript {
+		return
+	}
+	vmPool[key] = append(vmPool[key], entry)
+}
@@ -0,0 +1,416 @@
+package nodes
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// DefaultLibDir is where require() looks for shared JS modules, next to the workflow file,
+// unless the node sets "lib_dir" or VNEXT_LIB_DIR is set
+const DefaultLibDir = "lib"
+
+// defaultLibDir returns VNEXT_LIB_DIR, which is relative to the working directory like any
+// process setting, or DefaultLibDir in the workflow directory
+func defaultLibDir(workflowDir string) string {
+	if dir := os.Getenv("VNEXT_LIB_DIR"); dir != "" {
+		return dir
+	}
+	return resolvePath(workflowDir, DefaultLibDir)
+}
+
+// hostModules are the built-in modules available through require(name)
+var hostModules = map[string]func(vm *goja.Runtime) map[string]interface{}{
+	"base64":   base64Module,
+	"crypto":   cryptoModule,
+	"uuid":     uuidModule,
+	"datetime": datetimeModule,
+	"url":      urlModule,
+	"csv":      csvModule,
+}
+
+// installRequire adds a CommonJS-style require() to the sandbox. Names without a path resolve
+// to host modules; relative paths ("./strings") load JS files from the node's lib_dir.
+func (sb *sandbox) installRequire() {
+	vm := sb.vm
+	var requireFrom func(baseDir string) func(name string) goja.Value
+	requireFrom = func(baseDir string) func(name string) goja.Value {
+		return func(name string) goja.Value {
+			loaded := sb.run.modules
+			libDir := sb.run.node.LibDir
+			from := baseDir // Empty for the top-level require, which resolves from libDir
+			if from == "" {
+				from = libDir
+			}
+			if factory, ok := hostModules[name]; ok {
+				if mod, ok := loaded[name]; ok {
+					return mod
+				}
+				mod := vm.ToValue(factory(vm))
+				loaded[name] = mod
+				return mod
+			}
+
+			path, err := resolveModulePath(libDir, from, name)
+			if err != nil {
+				panic(vm.NewGoError(err))
+			}
+			if mod, ok := loaded[path]; ok {
+				return mod
+			}
+
+			src, err := os.ReadFile(path)
+			if err != nil {
+				panic(vm.NewGoError(fmt.Errorf("cannot load module %q: %w", name, err)))
+			}
+
+			module := vm.NewObject()
+			exports := vm.NewObject()
+			module.Set("exports", exports)
+			// Cache before running so circular requires see the partial exports, as in Node.js
+			loaded[path] = exports
+
+			wrapped := "(function(exports, require, module, __filename, __dirname) {" + string(src) + "\n})"
+			fnVal, err := vm.RunScript(path, wrapped)
+			if err != nil {
+				panic(err)
+			}
+			fn, _ := goja.AssertFunction(fnVal)
+			dir := filepath.Dir(path)
+			if _, err := fn(goja.Undefined(), exports, vm.ToValue(requireFrom(dir)), module, vm.ToValue(path), vm.ToValue(dir)); err != nil {
+				panic(err)
+			}
+
+			result := module.Get("exports")
+			loaded[path] = result
+			return result
+		}
+	}
+
+	vm.Set("require", requireFrom(""))
+}
+
+// resolveModulePath maps a module name to a .js file inside libDir
+func resolveModulePath(libDir, baseDir, name string) (string, error) {
+	if !strings.HasPrefix(name, "./") && !strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf("unknown module %q (use ./name for modules in %s)", name, libDir)
+	}
+	if !strings.HasSuffix(name, ".js") {
+		name += ".js"
+	}
+
+	root, err := filepath.Abs(libDir)
+	if err != nil {
+		return "", err
+	}
+	path, err := filepath.Abs(filepath.Join(baseDir, name))
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(root, path); err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("module %q is outside the lib directory", name)
+	}
+	return path, nil
+}
+
+func base64Module(vm *goja.Runtime) map[string]interface{} {
+	decode := func(enc *base64.Encoding) func(string) string {
+		return func(s string) string {
+			data, err := enc.DecodeString(s)
+			if err != nil {
+				panic(vm.NewGoError(fmt.Errorf("base64 decode: %w", err)))
+			}
+			return string(data)
+		}
+	}
+	return map[string]interface{}{
+		"encode":    func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+		"decode":    decode(base64.StdEncoding),
+		"encodeURL": func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) },
+		"decodeURL": decode(base64.RawURLEncoding),
+	}
+}
+
+func cryptoModule(vm *goja.Runtime) map[string]interface{} {
+	digest := func(newHash func() hash.Hash) func(string) string {
+		return func(s string) string {
+			h := newHash()
+			h.Write([]byte(s))
+			return hex.EncodeToString(h.Sum(nil))
+		}
+	}
+	return map[string]interface{}{
+		"md5":    digest(md5.New),
+		"sha1":   digest(sha1.New),
+		"sha256": digest(sha256.New),
+		"sha512": digest(sha512.New),
+		"hmacSha256": func(key, msg string) string {
+			mac := hmac.New(sha256.New, []byte(key))
+			mac.Write([]byte(msg))
+			return hex.EncodeToString(mac.Sum(nil))
+		},
+		"randomHex": func(n int) string {
+			buf := make([]byte, n)
+			if _, err := rand.Read(buf); err != nil {
+				panic(vm.NewGoError(err))
+			}
+			return hex.EncodeToString(buf)
+		},
+	}
+}
+
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // Version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+func uuidModule(vm *goja.Runtime) map[string]interface{} {
+	return map[string]interface{}{
+		"v4": func() string {
+			id, err := newUUID()
+			if err != nil {
+				panic(vm.NewGoError(err))
+			}
+			return id
+		},
+	}
+}
+
+// namedLayouts lets scripts use readable names instead of Go reference layouts
+var namedLayouts = map[string]string{
+	"RFC3339":  time.RFC3339,
+	"RFC1123":  time.RFC1123,
+	"DateOnly": "2006-01-02",
+	"DateTime": "2006-01-02 15:04:05",
+	"TimeOnly": "15:04:05",
+	"Kitchen":  time.Kitchen,
+}
+
+func datetimeModule(vm *goja.Runtime) map[string]interface{} {
+	layout := func(l string) string {
+		if named, ok := namedLayouts[l]; ok {
+			return named
+		}
+		if l == "" {
+			return time.RFC3339
+		}
+		return l
+	}
+	// toTime accepts RFC 3339 strings and Unix milliseconds (what Date.now() returns)
+	toTime := func(v goja.Value) time.Time {
+		switch x := v.Export().(type) {
+		case int64:
+			return time.UnixMilli(x).UTC()
+		case float64:
+			return time.UnixMilli(int64(x)).UTC()
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, x)
+			if err != nil {
+				panic(vm.NewGoError(fmt.Errorf("datetime: %w", err)))
+			}
+			return t
+		default:
+			panic(vm.NewTypeError("datetime: expected an RFC 3339 string or Unix milliseconds"))
+		}
+	}
+	return map[string]interface{}{
+		"now": func() string { return time.Now().UTC().Format(time.RFC3339Nano) },
+		"format": func(v goja.Value, l string) string {
+			return toTime(v).Format(layout(l))
+		},
+		"parse": func(s, l string) string {
+			t, err := time.Parse(layout(l), s)
+			if err != nil {
+				panic(vm.NewGoError(fmt.Errorf("datetime: %w", err)))
+			}
+			return t.Format(time.RFC3339Nano)
+		},
+		"add": func(v goja.Value, d string) string {
+			dur, err := time.ParseDuration(d)
+			if err != nil {
+				panic(vm.NewGoError(fmt.Errorf("datetime: %w", err)))
+			}
+			return toTime(v).Add(dur).Format(time.RFC3339Nano)
+		},
+		"unix": func(v goja.Value) int64 { return toTime(v).UnixMilli() },
+	}
+}
+
+func urlModule(vm *goja.Runtime) map[string]interface{} {
+	return map[string]interface{}{
+		"parse": func(raw string) map[string]interface{} {
+			u, err := url.Parse(raw)
+			if err != nil {
+				panic(vm.NewGoError(err))
+			}
+			query := make(map[string]interface{})
+			for k, vs := range u.Query() {
+				if len(vs) == 1 {
+					query[k] = vs[0]
+				} else {
+					list := make([]interface{}, len(vs))
+					for i, v := range vs {
+						list[i] = v
+					}
+					query[k] = list
+				}
+			}
+			return map[string]interface{}{
+				"scheme":   u.Scheme,
+				"host":     u.Host,
+				"hostname": u.Hostname(),
+				"port":     u.Port(),
+				"path":     u.Path,
+				"query":    query,
+				"fragment": u.Fragment,
+				"username": u.User.Username(),
+			}
+		},
+		"encodeQuery": func(params map[string]interface{}) string {
+			values := url.Values{}
+			for k, v := range params {
+				if list, ok := v.([]interface{}); ok {
+					for _, item := range list {
+						values.Add(k, fmt.Sprintf("%v", item))
+					}
+					continue
+				}
+				values.Set(k, fmt.Sprintf("%v", v))
+			}
+			return values.Encode()
+		},
+		"escape":   url.QueryEscape,
+		"unescape": func(s string) string { v, _ := url.QueryUnescape(s); return v },
+	}
+}
+
+type csvOptions struct {
+	header    bool
+	delimiter rune
+}
+
+func parseCSVOptions(opts map[string]interface{}) csvOptions {
+	o := csvOptions{delimiter: ','}
+	if h, ok := opts["header"].(bool); ok {
+		o.header = h
+	}
+	if d, ok := opts["delimiter"].(string); ok && d != "" {
+		o.delimiter = []rune(d)[0]
+	}
+	return o
+}
+
+func csvModule(vm *goja.Runtime) map[string]interface{} {
+	return map[string]interface{}{
+		// parse returns an array of rows, or of objects keyed by the header row when {header: true}
+		"parse": func(text string, opts map[string]interface{}) []interface{} {
+			o := parseCSVOptions(opts)
+			r := csv.NewReader(strings.NewReader(text))
+			r.Comma = o.delimiter
+			r.FieldsPerRecord = -1
+			records, err := r.ReadAll()
+			if err != nil {
+				panic(vm.NewGoError(fmt.Errorf("csv: %w", err)))
+			}
+
+			rows := make([]interface{}, 0, len(records))
+			if !o.header {
+				for _, rec := range records {
+					row := make([]interface{}, len(rec))
+					for i, f := range rec {
+						row[i] = f
+					}
+					rows = append(rows, row)
+				}
+				return rows
+			}
+			if len(records) == 0 {
+				return rows
+			}
+			header := records[0]
+			for _, rec := range records[1:] {
+				row := make(map[string]interface{}, len(header))
+				for i, name := range header {
+					if i < len(rec) {
+						row[name] = rec[i]
+					} else {
+						row[name] = ""
+					}
+				}
+				rows = append(rows, row)
+			}
+			return rows
+		},
+		// stringify accepts arrays of arrays, or arrays of objects with {header: true}
+		"stringify": func(rows []interface{}, opts map[string]interface{}) string {
+			o := parseCSVOptions(opts)
+			var buf bytes.Buffer
+			w := csv.NewWriter(&buf)
+			w.Comma = o.delimiter
+
+			var columns []string
+			for i, row := range rows {
+				var rec []string
+				switch r := row.(type) {
+				case []interface{}:
+					for _, f := range r {
+						rec = append(rec, fmt.Sprintf("%v", f))
+					}
+				case map[string]interface{}:
+					if columns == nil {
+						columns = sortedKeys(r)
+						if o.header {
+							w.Write(columns)
+						}
+					}
+					for _, c := range columns {
+						if v, ok := r[c]; ok && v != nil {
+							rec = append(rec, fmt.Sprintf("%v", v))
+						} else {
+							rec = append(rec, "")
+						}
+					}
+				default:
+					panic(vm.NewTypeError(fmt.Sprintf("csv: row %d must be an array or object", i)))
+				}
+				w.Write(rec)
+			}
+			w.Flush()
+			if err := w.Error(); err != nil {
+				panic(vm.NewGoError(err))
+			}
+			return buf.String()
+		},
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
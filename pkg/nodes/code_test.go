@@ -0,0 +1,156 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dify-vnext-go/pkg/dsl"
+	"dify-vnext-go/pkg/engine"
+)
+
+func runCode(t testing.TB, n *CodeNode, inputs map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	out, err := n.Execute(&engine.NodeContext{Ctx: context.Background(), Inputs: inputs, NodeID: n.ID()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestCodeNodeFreshScopePerRun(t *testing.T) {
+	n := NewCodeNode("scope_test", map[string]interface{}{"code": `
+		var runs = (typeof runs === "number" ? runs : 0) + 1;
+		let seen = [];
+		function main(input) {
+			seen.push(input.x);
+			return {runs: runs, seen: seen.length};
+		}
+	`}, nil, "")
+	for i := 0; i < 3; i++ {
+		out := runCode(t, n, map[string]interface{}{"x": i})
+		if fmt.Sprint(out["runs"], out["seen"]) != "1 1" {
+			t.Fatalf("run %d saw state from an earlier run: %v", i, out)
+		}
+	}
+	if len(vmPool[scriptKey(n.ID(), n.Code, false)]) == 0 {
+		t.Error("expected the runtime to be pooled")
+	}
+}
+
+func TestCodeNodeDropsRuntimesWithNewGlobals(t *testing.T) {
+	n := NewCodeNode("global_test", map[string]interface{}{"code": `
+		function main(input) {
+			leaked = (typeof leaked === "number" ? leaked : 0) + 1;
+			return {leaked: leaked};
+		}
+	`}, nil, "")
+	for i := 0; i < 3; i++ {
+		if out := runCode(t, n, nil); fmt.Sprint(out["leaked"]) != "1" {
+			t.Fatalf("run %d saw a global set by an earlier run: %v", i, out)
+		}
+	}
+}
+
+func TestCodeNodeLegacyGlobalsDoNotPersist(t *testing.T) {
+	n := NewCodeNode("legacy_test", map[string]interface{}{
+		"legacy_globals": true,
+		"code":           `function main(input) { return {kind: typeof only_first}; }`,
+	}, nil, "")
+	if out := runCode(t, n, map[string]interface{}{"only_first": 1}); out["kind"] != "number" {
+		t.Fatalf("expected the input as a global, got %v", out)
+	}
+	if out := runCode(t, n, map[string]interface{}{}); out["kind"] != "undefined" {
+		t.Fatalf("input from the previous run is still a global: %v", out)
+	}
+}
+
+func TestCodeNodeLibDirRelativeToWorkflow(t *testing.T) {
+	dir := t.TempDir()
+	for _, lib := range []string{"lib", "shared"} {
+		if err := os.MkdirAll(filepath.Join(dir, lib), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		module := fmt.Sprintf("module.exports = {from: %q};", lib)
+		if err := os.WriteFile(filepath.Join(dir, lib, "where.js"), []byte(module), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	code := `function main(input) { return {from: require("./where").from}; }`
+
+	// The default lib directory and a relative lib_dir are found next to the workflow file,
+	// whatever the working directory
+	for lib, config := range map[string]map[string]interface{}{
+		"lib":    {"code": code},
+		"shared": {"code": code, "lib_dir": "shared"},
+	} {
+		n := NewCodeNode("lib_dir_test_"+lib, config, nil, dir)
+		if out := runCode(t, n, nil); out["from"] != lib {
+			t.Errorf("lib_dir %v loaded %v, want the module in %s", config["lib_dir"], out["from"], lib)
+		}
+	}
+}
+
+// BenchmarkCodeNodeLoopFanOut runs a Code node in every iteration of a wide Loop, with
+// pooled runtimes and with a fresh runtime per execution
+func BenchmarkCodeNodeLoopFanOut(b *testing.B) {
+	const width = 256
+	items := make([]interface{}, width)
+	for i := range items {
+		items[i] = i
+	}
+	loop := NewLoopNode("fan_out", map[string]interface{}{
+		"sub_workflow": map[string]interface{}{
+			"nodes": []interface{}{map[string]interface{}{
+				"id":   "square",
+				"type": "Code",
+				"config": map[string]interface{}{"code": `
+					const helpers = {square: (x) => x * x};
+					function main(input) { return {value: helpers.square(input.item)}; }
+				`},
+				"inputs": map[string]interface{}{"item": "{{ memory.loop_item }}"},
+			}},
+		},
+		"output": "{{ square.value }}",
+	}, "")
+
+	// Node output goes to stdout; keep it out of the benchmark
+	stdout := os.Stdout
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		b.Fatal(err)
+	}
+	os.Stdout = devNull
+	defer func() {
+		os.Stdout = stdout
+		devNull.Close()
+	}()
+
+	for _, mode := range []struct {
+		name   string
+		pooled bool
+	}{{"pooled", true}, {"fresh", false}} {
+		b.Run(mode.name, func(b *testing.B) {
+			codeVMPooling = mode.pooled
+			defer func() { codeVMPooling = true }()
+			parent := engine.NewEngine(&dsl.WorkflowDefinition{})
+			for i := 0; i < b.N; i++ {
+				out, err := loop.Execute(&engine.NodeContext{
+					Ctx:    context.Background(),
+					Memory: engine.NewGlobalMemory(),
+					Inputs: map[string]interface{}{"list": items},
+					NodeID: loop.ID(),
+					Engine: parent,
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+				if results, _ := out["results"].([]interface{}); len(results) != width {
+					b.Fatalf("expected %d results, got %v", width, out)
+				}
+			}
+		})
+	}
+}
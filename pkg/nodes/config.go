@@ -0,0 +1,53 @@
+package nodes
+
+import (
+	"fmt"
+	"time"
+)
+
+// configInt reads an integer from a node config.
+// YAML decodes numbers as int while JSON round-trips (e.g. sub-workflows) produce float64.
+func configInt(config map[string]interface{}, key string, def int) int {
+	switch v := config[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	default:
+		return def
+	}
+}
+
+// configDuration reads a duration from a node config.
+// Strings use Go duration syntax ("5s"); bare numbers are milliseconds.
+func configDuration(config map[string]interface{}, key string, def time.Duration) time.Duration {
+	switch v := config[key].(type) {
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			fmt.Printf("Warning: invalid duration %q for %s, using %s\n", v, key, def)
+			return def
+		}
+		return d
+	case int, int64, float64:
+		return time.Duration(configInt(config, key, 0)) * time.Millisecond
+	default:
+		return def
+	}
+}
+
+// configFloat reads a number from a node config
+func configFloat(config map[string]interface{}, key string, def float64) float64 {
+	switch v := config[key].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	default:
+		return def
+	}
+}
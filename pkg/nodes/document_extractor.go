@@ -0,0 +1,136 @@
+package nodes
+
+import (
+	"dify-vnext-go/pkg/document"
+	"dify-vnext-go/pkg/engine"
+	"fmt"
+	"strings"
+)
+
+const defaultMaxDocumentBytes = 50 << 20 // 50MB
+
+// DocumentExtractorNode turns uploaded files into text. The `file` input is a BlobRef
+// (or a list of them); the format is detected from the MIME type and file name unless
+// configured.
+//
+//	inputs:
+//	  file: "{{ start.report }}"
+//	config:
+//	  format: pdf            # optional: text, markdown, html, csv, json, docx or pdf
+//	  max_bytes: 10485760    # optional, default 50MB
+//
+// Outputs are `text`, `format`, `pages` and `sections` (with offsets into `text`) and
+// `metadata`. For a list of files, `documents` holds one such map per file and `text`
+// joins their texts.
+type DocumentExtractorNode struct {
+	BaseNode
+	Format   string
+	MaxBytes int64
+}
+
+func NewDocumentExtractorNode(id string, config map[string]interface{}) *DocumentExtractorNode {
+	format, _ := config["format"].(string)
+	return &DocumentExtractorNode{
+		BaseNode: NewBaseNode(id, "DocumentExtractor"),
+		Format:   format,
+		MaxBytes: int64(configInt(config, "max_bytes", defaultMaxDocumentBytes)),
+	}
+}
+
+func (n *DocumentExtractorNode) Execute(ctx *engine.NodeContext) (map[string]interface{}, error) {
+	blobs := blobStore(ctx)
+	if blobs == nil {
+		return nil, fmt.Errorf("[%s] no blob store", n.ID())
+	}
+
+	input, ok := ctx.Inputs["file"]
+	if !ok || input == nil {
+		return nil, fmt.Errorf("[%s] missing input 'file'", n.ID())
+	}
+	list, isList := input.([]interface{})
+	if !isList {
+		return n.extract(blobs, input)
+	}
+
+	documents := make([]interface{}, 0, len(list))
+	texts := make([]string, 0, len(list))
+	for _, item := range list {
+		doc, err := n.extract(blobs, item)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, doc)
+		texts = append(texts, doc["text"].(string))
+	}
+	return map[string]interface{}{
+		"text":      strings.Join(texts, "\n\n"),
+		"documents": documents,
+	}, nil
+}
+
+func (n *DocumentExtractorNode) extract(blobs engine.BlobStore, value interface{}) (map[string]interface{}, error) {
+	ref, ok := engine.AsBlobRef(value)
+	if !ok {
+		return nil, fmt.Errorf("[%s] input 'file' is not a file: %v", n.ID(), value)
+	}
+	stat, err := blobs.Stat(ref.ID)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] %w", n.ID(), err)
+	}
+	if n.MaxBytes > 0 && stat.Size > n.MaxBytes {
+		return nil, fmt.Errorf("[%s] %s is %d bytes, larger than max_bytes %d", n.ID(), displayName(ref), stat.Size, n.MaxBytes)
+	}
+	data, err := engine.ReadBlob(blobs, ref.ID)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] %w", n.ID(), err)
+	}
+
+	mimeType := ref.MimeType
+	if mimeType == "" {
+		mimeType = stat.MimeType
+	}
+	var doc *document.Document
+	if n.Format != "" {
+		doc, err = document.ExtractFormat(data, n.Format)
+	} else {
+		doc, err = document.Extract(data, ref.Name, mimeType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("[%s] %s: %w", n.ID(), displayName(ref), err)
+	}
+	fmt.Printf("[%s] Extracted %d characters from %s (%s)\n", n.ID(), len(doc.Text), displayName(ref), doc.Format)
+
+	pages := make([]interface{}, len(doc.Pages))
+	for i, p := range doc.Pages {
+		pages[i] = map[string]interface{}{"page": p.Number, "offset": p.Offset, "text": p.Text}
+	}
+	sections := make([]interface{}, len(doc.Sections))
+	for i, s := range doc.Sections {
+		section := map[string]interface{}{"title": s.Title, "level": s.Level, "offset": s.Offset, "text": s.Text}
+		if s.Page > 0 {
+			section["page"] = s.Page
+		}
+		sections[i] = section
+	}
+	return map[string]interface{}{
+		"text":     doc.Text,
+		"format":   doc.Format,
+		"pages":    pages,
+		"sections": sections,
+		"metadata": map[string]interface{}{
+			"name":       ref.Name,
+			"mime_type":  mimeType,
+			"size":       stat.Size,
+			"page_count": len(doc.Pages),
+			"blob_id":    ref.ID,
+		},
+	}, nil
+}
+
+// displayName names a blob in messages
+func displayName(ref engine.BlobRef) string {
+	if ref.Name != "" {
+		return ref.Name
+	}
+	return ref.ID
+}
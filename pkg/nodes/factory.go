@@ -4,6 +4,7 @@ import (
 	"dify-vnext-go/pkg/dsl"
 	"dify-vnext-go/pkg/engine"
 	"fmt"
+	"path/filepath"
 )
 
 // CreateNode creates a node instance based on the definition
@@ -20,15 +21,40 @@ func CreateNode(def dsl.NodeDefinition) engine.Node {
 	case "HttpRequest":
 		return NewHttpRequestNode(def.ID, def.Config)
 	case "Code":
-		return NewCodeNode(def.ID, def.Config)
+		return NewCodeNode(def.ID, def.Config, def.Outputs, def.Dir)
 	case "Answer":
 		return NewAnswerNode(def.ID, def.Config)
 	case "Tool":
 		return NewToolNode(def.ID, def.Config)
 	case "Loop":
-		return NewLoopNode(def.ID, def.Config)
+		return NewLoopNode(def.ID, def.Config, def.Dir)
+	case "While":
+		return NewWhileNode(def.ID, def.Config, def.Dir)
+	case "Workflow":
+		return NewWorkflowNode(def.ID, def.Config, def.Dir)
+	case "HumanInput":
+		return NewHumanInputNode(def.ID, def.Config)
+	case "DocumentExtractor":
+		return NewDocumentExtractorNode(def.ID, def.Config)
+	case "TextSplitter":
+		return NewTextSplitterNode(def.ID, def.Config)
+	case "KnowledgeRetrieval":
+		return NewKnowledgeRetrievalNode(def.ID, def.Config, def.Dir)
+	case "QuestionClassifier":
+		return NewQuestionClassifierNode(def.ID, def.Config)
+	case "ParameterExtractor":
+		return NewParameterExtractorNode(def.ID, def.Config)
 	default:
 		fmt.Printf("Unknown node type: %s\n", def.Type)
 		return nil
 	}
 }
+
+// resolvePath resolves a relative path from a node config against dir, the directory of the
+// workflow file. Without a dir (workflows built in code) it stays relative to the working directory.
+func resolvePath(dir, path string) string {
+	if path != "" && !filepath.IsAbs(path) && dir != "" {
+		return filepath.Join(dir, path)
+	}
+	return path
+}
@@ -1,16 +1,56 @@
 package nodes
 
 import (
+	"bytes"
+	"context"
+	"dify-vnext-go/pkg/egress"
 	"dify-vnext-go/pkg/engine"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
 )
 
+const (
+	defaultHTTPTimeout   = 30 * time.Second
+	defaultMaxRedirects  = 10
+	defaultMaxHTTPResult = 10 << 20 // 10MB
+)
+
+// HttpRequestNode sends an HTTP request. URL, headers, params, body and auth may contain
+// templates, which are resolved when the node runs.
+//
+//	config:
+//	  method: POST
+//	  url: "https://api.example.com/items/{{ start.id }}"
+//	  headers: { X-Trace: "{{ start.trace_id }}" }
+//	  params: { verbose: "true" }
+//	  body:
+//	    type: json            # json, form, multipart or raw
+//	    data: { name: "{{ start.name }}" }
+//	  auth: { type: bearer, token: "..." }
+//	  timeout: 10s
+//	  follow_redirects: true
+//	  max_redirects: 5
 type HttpRequestNode struct {
 	BaseNode
-	Method string
-	URL    string
+	Method          string
+	URL             string
+	Headers         map[string]interface{}
+	Params          map[string]interface{}
+	Body            map[string]interface{}
+	Auth            map[string]interface{}
+	Timeout         time.Duration
+	FollowRedirects bool
+	MaxRedirects    int
+	MaxResponseSize int64
 }
 
 func NewHttpRequestNode(id string, config map[string]interface{}) *HttpRequestNode {
@@ -19,50 +59,352 @@ func NewHttpRequestNode(id string, config map[string]interface{}) *HttpRequestNo
 	if method == "" {
 		method = "GET"
 	}
+	headers, _ := config["headers"].(map[string]interface{})
+	params, _ := config["params"].(map[string]interface{})
+	body, _ := config["body"].(map[string]interface{})
+	auth, _ := config["auth"].(map[string]interface{})
+	follow := true
+	if v, ok := config["follow_redirects"].(bool); ok {
+		follow = v
+	}
 	return &HttpRequestNode{
-		BaseNode: NewBaseNode(id, "HttpRequest"),
-		Method:   method,
-		URL:      url,
+		BaseNode:        NewBaseNode(id, "HttpRequest"),
+		Method:          strings.ToUpper(method),
+		URL:             url,
+		Headers:         headers,
+		Params:          params,
+		Body:            body,
+		Auth:            auth,
+		Timeout:         configDuration(config, "timeout", defaultHTTPTimeout),
+		FollowRedirects: follow,
+		MaxRedirects:    configInt(config, "max_redirects", defaultMaxRedirects),
+		MaxResponseSize: int64(configInt(config, "max_response_bytes", defaultMaxHTTPResult)),
 	}
 }
 
 func (n *HttpRequestNode) Execute(ctx *engine.NodeContext) (map[string]interface{}, error) {
-	// Resolve URL if it contains templates (simple implementation)
-	// In a real implementation, we'd resolve all config fields.
-	// For MVP, let's assume URL might be passed in inputs if not in config,
-	// or we just use the config.
+	// Only the configured fields are templates. Inputs were resolved by the engine already,
+	// so resolving them again would expand templates in upstream values (e.g. a secret
+	// reference in an LLM's answer).
+	resolved, err := n.resolveConfig(ctx, map[string]interface{}{
+		"url":     n.URL,
+		"headers": n.Headers,
+		"params":  n.Params,
+		"body":    n.Body,
+		"auth":    n.Auth,
+	})
+	if err != nil {
+		return nil, err
+	}
+	rawURL := fmt.Sprintf("%v", resolved["url"])
+	// A url input overrides the configured URL
+	if val, ok := ctx.Inputs["url"]; ok && val != nil {
+		rawURL = fmt.Sprintf("%v", val)
+	}
+	headers, _ := resolved["headers"].(map[string]interface{})
+	params, _ := resolved["params"].(map[string]interface{})
+	body, _ := resolved["body"].(map[string]interface{})
+	auth, _ := resolved["auth"].(map[string]interface{})
 
-	url := n.URL
-	// Check if URL is dynamic from inputs
-	if val, ok := ctx.Inputs["url"]; ok {
-		url = fmt.Sprintf("%v", val)
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %q: %w", rawURL, err)
+	}
+	if len(params) > 0 {
+		q := u.Query()
+		for _, k := range sortedKeys(params) {
+			addValues(q, k, params[k])
+		}
+		u.RawQuery = q.Encode()
 	}
 
-	fmt.Printf("[%s] Making HTTP Request: %s %s\n", n.ID(), n.Method, url)
+	reqBody, contentType, err := encodeRequestBody(body, blobStore(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("[%s] %w", n.ID(), err)
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx.Ctx, n.Timeout)
+	defer cancel()
 
-	req, err := http.NewRequest(n.Method, url, nil)
+	fmt.Printf("[%s] Making HTTP Request: %s %s\n", n.ID(), n.Method, u.Redacted())
+
+	req, err := http.NewRequestWithContext(reqCtx, n.Method, u.String(), reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for k, v := range headers {
+		if v != nil {
+			req.Header.Set(k, fmt.Sprintf("%v", v))
+		}
+	}
+	if err := applyHTTPAuth(req, auth); err != nil {
+		return nil, fmt.Errorf("[%s] %w", n.ID(), err)
+	}
 
-	// Add headers if needed (omitted for MVP)
-
-	client := &http.Client{}
+	// Copy the egress client so the redirect policy stays local to this node
+	client := *egress.ClientFrom(ctx.Ctx)
+	client.CheckRedirect = n.checkRedirect
 	resp, err := client.Do(req)
 	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("request timed out after %s: %w", n.Timeout, err)
+		}
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	data, err := io.ReadAll(io.LimitReader(resp.Body, n.MaxResponseSize+1))
 	if err != nil {
 		return nil, fmt.Errorf("failed to read body: %w", err)
 	}
+	if int64(len(data)) > n.MaxResponseSize {
+		return nil, fmt.Errorf("[%s] response exceeds %d bytes", n.ID(), n.MaxResponseSize)
+	}
 
 	fmt.Printf("[%s] Response Status: %s\n", n.ID(), resp.Status)
 
-	return map[string]interface{}{
+	respHeaders := make(map[string]interface{}, len(resp.Header))
+	for k, v := range resp.Header {
+		respHeaders[strings.ToLower(k)] = strings.Join(v, ", ")
+	}
+	outputs := map[string]interface{}{
 		"status_code": resp.StatusCode,
-		"body":        string(body),
-	}, nil
+		"headers":     respHeaders,
+		"body":        "",
+	}
+
+	mediaType := resp.Header.Get("Content-Type")
+	if mediaType == "" && len(data) > 0 {
+		mediaType = http.DetectContentType(data)
+	}
+	if isTextMedia(mediaType) {
+		outputs["body"] = string(data)
+		var parsed interface{}
+		if len(data) > 0 && json.Unmarshal(data, &parsed) == nil {
+			outputs["json"] = parsed
+		}
+	} else if len(data) > 0 {
+		// Binary payloads are stored as a blob and passed on by reference
+		blobs := blobStore(ctx)
+		if blobs == nil {
+			return nil, fmt.Errorf("[%s] no blob store for binary response", n.ID())
+		}
+		ref, err := blobs.Put(bytes.NewReader(data), mediaType)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] failed to store response: %w", n.ID(), err)
+		}
+		if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+			ref.Name = params["filename"]
+		} else if name := path.Base(resp.Request.URL.Path); path.Ext(name) != "" {
+			ref.Name = name
+		}
+		outputs["file"] = ref
+	}
+	return outputs, nil
+}
+
+// resolveConfig resolves templates in config fields against the running workflow
+func (n *HttpRequestNode) resolveConfig(ctx *engine.NodeContext, fields map[string]interface{}) (map[string]interface{}, error) {
+	if ctx.Engine == nil {
+		return fields, nil
+	}
+	resolved, err := ctx.Engine.ResolveValue(fields)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to resolve config: %w", n.ID(), err)
+	}
+	return resolved.(map[string]interface{}), nil
+}
+
+func (n *HttpRequestNode) checkRedirect(req *http.Request, via []*http.Request) error {
+	if !n.FollowRedirects {
+		// Return the redirect response itself
+		return http.ErrUseLastResponse
+	}
+	if len(via) > n.MaxRedirects {
+		return fmt.Errorf("stopped after %d redirects", n.MaxRedirects)
+	}
+	return nil
+}
+
+// encodeRequestBody builds the request body from the body config: {type, data, content_type}
+func encodeRequestBody(body map[string]interface{}, blobs engine.BlobStore) (io.Reader, string, error) {
+	if body == nil {
+		return nil, "", nil
+	}
+	bodyType, _ := body["type"].(string)
+	data := body["data"]
+	contentType, _ := body["content_type"].(string)
+
+	switch bodyType {
+	case "", "none":
+		return nil, "", nil
+	case "json":
+		// A string is assumed to be JSON already
+		if s, ok := data.(string); ok {
+			return strings.NewReader(s), "application/json", nil
+		}
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode JSON body: %w", err)
+		}
+		return bytes.NewReader(encoded), "application/json", nil
+	case "form":
+		fields, ok := data.(map[string]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf("form body data must be a map")
+		}
+		form := url.Values{}
+		for _, k := range sortedKeys(fields) {
+			addValues(form, k, fields[k])
+		}
+		return strings.NewReader(form.Encode()), "application/x-www-form-urlencoded", nil
+	case "multipart":
+		fields, ok := data.(map[string]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf("multipart body data must be a map")
+		}
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		for _, k := range sortedKeys(fields) {
+			if err := writeMultipartField(w, blobs, k, fields[k]); err != nil {
+				return nil, "", err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return nil, "", err
+		}
+		return &buf, w.FormDataContentType(), nil
+	case "raw":
+		if contentType == "" {
+			contentType = "text/plain"
+		}
+		return strings.NewReader(fmt.Sprintf("%v", data)), contentType, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported body type: %s", bodyType)
+	}
+}
+
+// writeMultipartField writes blobs as file parts and everything else as form fields
+func writeMultipartField(w *multipart.Writer, blobs engine.BlobStore, name string, value interface{}) error {
+	blob, ok := engine.AsBlobRef(value)
+	if !ok {
+		return w.WriteField(name, formValue(value))
+	}
+	if blobs == nil {
+		return fmt.Errorf("multipart field %s: no blob store", name)
+	}
+	data, err := blobs.Get(blob.ID)
+	if err != nil {
+		return fmt.Errorf("multipart field %s: %w", name, err)
+	}
+	defer data.Close()
+
+	filename := blob.Name
+	if filename == "" {
+		filename = blob.ID
+		if exts, _ := mime.ExtensionsByType(blob.MimeType); len(exts) > 0 {
+			filename += exts[0]
+		}
+	}
+	h := make(map[string][]string)
+	h["Content-Disposition"] = []string{fmt.Sprintf(`form-data; name=%q; filename=%q`, name, filename)}
+	h["Content-Type"] = []string{blob.MimeType}
+	part, err := w.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, data)
+	return err
+}
+
+// blobStore returns the blob store of the running engine, if any
+func blobStore(ctx *engine.NodeContext) engine.BlobStore {
+	if ctx.Engine == nil {
+		return nil
+	}
+	return ctx.Engine.Blobs()
+}
+
+func applyHTTPAuth(req *http.Request, auth map[string]interface{}) error {
+	if auth == nil {
+		return nil
+	}
+	str := func(key string) string {
+		if v, ok := auth[key]; ok && v != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return ""
+	}
+	switch str("type") {
+	case "", "none":
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+str("token"))
+	case "basic":
+		req.SetBasicAuth(str("username"), str("password"))
+	case "api_key":
+		name := str("name")
+		if name == "" {
+			name = "X-API-Key"
+		}
+		if str("in") == "query" {
+			q := req.URL.Query()
+			q.Set(name, str("value"))
+			req.URL.RawQuery = q.Encode()
+		} else {
+			req.Header.Set(name, str("value"))
+		}
+	default:
+		return fmt.Errorf("unsupported auth type: %s", str("type"))
+	}
+	return nil
+}
+
+// addValues adds a scalar or each element of a list under key
+func addValues(values url.Values, key string, v interface{}) {
+	if list, ok := v.([]interface{}); ok {
+		for _, item := range list {
+			values.Add(key, formValue(item))
+		}
+		return
+	}
+	if v != nil {
+		values.Add(key, formValue(v))
+	}
+}
+
+func formValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case map[string]interface{}, []interface{}:
+		encoded, _ := json.Marshal(val)
+		return string(encoded)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+// isTextMedia reports whether a Content-Type is returned as a string body
+func isTextMedia(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	switch {
+	case mediaType == "":
+		return true
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript",
+		"application/x-www-form-urlencoded", "application/x-ndjson", "application/yaml":
+		return true
+	}
+	return false
 }
@@ -0,0 +1,44 @@
+package nodes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"dify-vnext-go/pkg/dsl"
+	"dify-vnext-go/pkg/egress"
+	"dify-vnext-go/pkg/engine"
+	"dify-vnext-go/pkg/secrets"
+)
+
+func TestHttpRequestDoesNotResolveTemplatesInInputs(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query().Get("k")
+	}))
+	defer srv.Close()
+
+	eng := engine.NewEngine(&dsl.WorkflowDefinition{})
+	eng.SetSecrets(secrets.NewVault(secrets.MapStore{"OPENAI_API_KEY": "sk-very-secret"}))
+	local := egress.WithClient(context.Background(), (&egress.Policy{AllowPrivate: true}).Client(nil))
+	templated := srv.URL + "/?k={{secrets.OPENAI_API_KEY}}"
+
+	// An upstream value is sent as written
+	n := NewHttpRequestNode("fetch", map[string]interface{}{})
+	if _, err := n.Execute(&engine.NodeContext{Ctx: local, Engine: eng, NodeID: n.ID(), Inputs: map[string]interface{}{"url": templated}}); err != nil {
+		t.Fatal(err)
+	}
+	if got != "{{secrets.OPENAI_API_KEY}}" {
+		t.Errorf("url input was expanded: server got k=%q", got)
+	}
+
+	// Templates in the configured URL still resolve
+	n = NewHttpRequestNode("fetch", map[string]interface{}{"url": templated})
+	if _, err := n.Execute(&engine.NodeContext{Ctx: local, Engine: eng, NodeID: n.ID(), Inputs: map[string]interface{}{}}); err != nil {
+		t.Fatal(err)
+	}
+	if got != "sk-very-secret" {
+		t.Errorf("configured url was not resolved: server got k=%q", got)
+	}
+}
@@ -0,0 +1,102 @@
+package nodes
+
+import (
+	"fmt"
+
+	"dify-vnext-go/pkg/engine"
+)
+
+// HumanAction is a choice offered to the person answering a HumanInput node
+type HumanAction struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+// HumanInputNode suspends the workflow until a person answers.
+// The chosen action becomes the _branch_id, so edges can route on it via source_handle.
+type HumanInputNode struct {
+	BaseNode
+	Actions []HumanAction
+	Form    map[string]interface{} // Field name -> schema ({type, description, required})
+}
+
+func NewHumanInputNode(id string, config map[string]interface{}) *HumanInputNode {
+	node := &HumanInputNode{
+		BaseNode: NewBaseNode(id, "HumanInput"),
+	}
+
+	if actions, ok := config["actions"].([]interface{}); ok {
+		for _, a := range actions {
+			m, ok := a.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			actionID, _ := m["id"].(string)
+			label, _ := m["label"].(string)
+			if label == "" {
+				label = actionID
+			}
+			node.Actions = append(node.Actions, HumanAction{ID: actionID, Label: label})
+		}
+	}
+
+	if form, ok := config["form"].(map[string]interface{}); ok {
+		node.Form = form
+	}
+
+	return node
+}
+
+func (n *HumanInputNode) Execute(ctx *engine.NodeContext) (map[string]interface{}, error) {
+	if ctx.Resume == nil {
+		prompt, _ := ctx.Inputs["prompt"].(string)
+		fmt.Printf("[%s] Waiting for human input: %s\n", n.ID(), prompt)
+
+		actions := make([]interface{}, len(n.Actions))
+		for i, a := range n.Actions {
+			actions[i] = map[string]interface{}{"id": a.ID, "label": a.Label}
+		}
+		return nil, engine.Interrupt(map[string]interface{}{
+			"prompt":  prompt,
+			"form":    n.Form,
+			"actions": actions,
+		})
+	}
+
+	action, _ := ctx.Resume["action"].(string)
+	if len(n.Actions) > 0 {
+		allowed := false
+		for _, a := range n.Actions {
+			if a.ID == action {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, fmt.Errorf("action %q is not allowed for node %s", action, n.ID())
+		}
+	}
+
+	outputs := map[string]interface{}{
+		"action": action,
+	}
+	for field, schema := range n.Form {
+		val, ok := ctx.Resume[field]
+		if !ok {
+			if s, _ := schema.(map[string]interface{}); s != nil {
+				if required, _ := s["required"].(bool); required {
+					return nil, fmt.Errorf("missing required field %q for node %s", field, n.ID())
+				}
+			}
+			// Optional fields left empty are still outputs, so templates referencing them resolve
+		}
+		outputs[field] = val
+	}
+
+	fmt.Printf("[%s] Human input received: action=%s\n", n.ID(), action)
+
+	if action != "" {
+		outputs["_branch_id"] = action
+	}
+	return outputs, nil
+}
@@ -3,6 +3,7 @@ package nodes
 import (
 	"dify-vnext-go/pkg/engine"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -17,6 +18,60 @@ type Condition struct {
 	Value    string
 }
 
+// Evaluate checks the condition against the given input string.
+// gt, gte, lt and lte compare numerically and are false when either side is not a number.
+func (c Condition) Evaluate(input string) bool {
+	switch c.Operator {
+	case "equals":
+		return input == c.Value
+	case "not_equals":
+		return input != c.Value
+	case "contains":
+		return strings.Contains(input, c.Value)
+	case "not_contains":
+		return !strings.Contains(input, c.Value)
+	case "starts_with":
+		return strings.HasPrefix(input, c.Value)
+	case "ends_with":
+		return strings.HasSuffix(input, c.Value)
+	case "empty":
+		return strings.TrimSpace(input) == ""
+	case "not_empty":
+		return strings.TrimSpace(input) != ""
+	case "gt", "gte", "lt", "lte":
+		a, errA := strconv.ParseFloat(strings.TrimSpace(input), 64)
+		b, errB := strconv.ParseFloat(strings.TrimSpace(c.Value), 64)
+		if errA != nil || errB != nil {
+			return false
+		}
+		switch c.Operator {
+		case "gt":
+			return a > b
+		case "gte":
+			return a >= b
+		case "lt":
+			return a < b
+		default:
+			return a <= b
+		}
+	default:
+		// default to equals
+		return input == c.Value
+	}
+}
+
+// conditionString stringifies a compared value; YAML may decode `value: 3` as an int
+// or `value: true` as a bool
+func conditionString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", v)
+}
+
 func NewIfElseNode(id string, config map[string]interface{}) *IfElseNode {
 	// Parse config for conditions
 	// For MVP, we'll assume a simple "variable", "operator", "value" in config
@@ -25,7 +80,7 @@ func NewIfElseNode(id string, config map[string]interface{}) *IfElseNode {
 
 	v, _ := config["variable"].(string)
 	op, _ := config["operator"].(string)
-	val, _ := config["value"].(string)
+	val := conditionString(config["value"])
 
 	return &IfElseNode{
 		BaseNode: NewBaseNode(id, "IfElse"),
@@ -55,16 +110,7 @@ func (n *IfElseNode) Execute(ctx *engine.NodeContext) (map[string]interface{}, e
 	inputStr := fmt.Sprintf("%v", inputVal)
 	cond := n.Conditions[0]
 
-	result := false
-	switch cond.Operator {
-	case "equals":
-		result = inputStr == cond.Value
-	case "contains":
-		result = strings.Contains(inputStr, cond.Value)
-	default:
-		// default to equals
-		result = inputStr == cond.Value
-	}
+	result := cond.Evaluate(inputStr)
 
 	fmt.Printf("[%s] Condition: '%s' %s '%s' ? %v\n", n.ID(), inputStr, cond.Operator, cond.Value, result)
 
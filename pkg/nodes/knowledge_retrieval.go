@@ -0,0 +1,141 @@
+package nodes
+
+import (
+	"dify-vnext-go/pkg/engine"
+	"dify-vnext-go/pkg/knowledge"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// KnowledgeRetrievalNode searches local knowledge bases (see `vnext kb`) for the `query`
+// input.
+//
+//	inputs:
+//	  query: "{{ start.question }}"
+//	config:
+//	  knowledge_base: handbook    # a name or a list of names
+//	  kb_dir: ./knowledge         # optional; defaults to VNEXT_KB_DIR or knowledge/ next to the workflow file
+//	  mode: hybrid                # bm25, vector or hybrid; default hybrid if the base has an embedder
+//	  top_k: 4
+//	  score_threshold: 0.2        # optional, scores are 0..1
+//	  vector_weight: 0.7          # hybrid mode: weight of the vector score
+//
+// Outputs `result` (chunks with content, score and source metadata, best first),
+// `context` (the chunk texts joined for a prompt) and `count`.
+type KnowledgeRetrievalNode struct {
+	BaseNode
+	KnowledgeBases []string
+	Dir            string // Directory holding the bases; empty means VNEXT_KB_DIR
+	Options        knowledge.SearchOptions
+}
+
+// NewKnowledgeRetrievalNode creates a KnowledgeRetrieval node. dir is the directory of the
+// workflow file, which a relative kb_dir and the default knowledge directory are resolved against.
+func NewKnowledgeRetrievalNode(id string, config map[string]interface{}, dir string) *KnowledgeRetrievalNode {
+	var bases []string
+	switch v := config["knowledge_base"].(type) {
+	case string:
+		bases = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				bases = append(bases, s)
+			}
+		}
+	}
+	kbDir, _ := config["kb_dir"].(string)
+	if kbDir == "" && os.Getenv(knowledge.DirEnv) == "" {
+		kbDir = knowledge.DefaultDir
+	}
+	mode, _ := config["mode"].(string)
+	return &KnowledgeRetrievalNode{
+		BaseNode:       NewBaseNode(id, "KnowledgeRetrieval"),
+		KnowledgeBases: bases,
+		Dir:            resolvePath(dir, kbDir),
+		Options: knowledge.SearchOptions{
+			Mode:         mode,
+			TopK:         configInt(config, "top_k", 4),
+			Threshold:    configFloat(config, "score_threshold", 0),
+			VectorWeight: configFloat(config, "vector_weight", 0),
+		},
+	}
+}
+
+func (n *KnowledgeRetrievalNode) Execute(ctx *engine.NodeContext) (map[string]interface{}, error) {
+	if len(n.KnowledgeBases) == 0 {
+		return nil, fmt.Errorf("[%s] missing config 'knowledge_base'", n.ID())
+	}
+	input, ok := ctx.Inputs["query"]
+	if !ok || input == nil {
+		return nil, fmt.Errorf("[%s] missing input 'query'", n.ID())
+	}
+	query, ok := input.(string)
+	if !ok {
+		query = fmt.Sprintf("%v", input)
+	}
+
+	root := knowledge.Root(n.Dir)
+	type hit struct {
+		knowledge.Result
+		base string
+	}
+	var results []hit
+	for _, name := range n.KnowledgeBases {
+		kb, err := knowledge.Open(root, name)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] %w", n.ID(), err)
+		}
+		found, err := kb.Search(ctx.Ctx, query, n.Options)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] %s: %w", n.ID(), name, err)
+		}
+		for _, r := range found {
+			results = append(results, hit{r, name})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > n.Options.TopK {
+		results = results[:n.Options.TopK]
+	}
+	fmt.Printf("[%s] Retrieved %d chunks from %s\n", n.ID(), len(results), strings.Join(n.KnowledgeBases, ", "))
+
+	items := make([]interface{}, len(results))
+	texts := make([]string, len(results))
+	for i, r := range results {
+		metadata := map[string]interface{}{
+			"knowledge_base": r.base,
+			"format":         r.Document.Format,
+			"keyword_score":  r.KeywordScore,
+			"vector_score":   r.VectorScore,
+		}
+		for k, v := range r.Document.Metadata {
+			metadata[k] = v
+		}
+		item := map[string]interface{}{
+			"content":       r.Chunk.Text,
+			"score":         r.Score,
+			"document_id":   r.Document.ID,
+			"document_name": r.Document.Name,
+			"source":        r.Document.Source,
+			"chunk_index":   r.Chunk.Index,
+			"start":         r.Chunk.Start,
+			"end":           r.Chunk.End,
+			"metadata":      metadata,
+		}
+		if r.Chunk.Page > 0 {
+			item["page"] = r.Chunk.Page
+		}
+		if r.Chunk.Section != "" {
+			item["section"] = r.Chunk.Section
+		}
+		items[i] = item
+		texts[i] = r.Chunk.Text
+	}
+	return map[string]interface{}{
+		"result":  items,
+		"context": strings.Join(texts, "\n\n"),
+		"count":   len(results),
+	}, nil
+}
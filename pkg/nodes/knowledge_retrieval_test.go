@@ -0,0 +1,31 @@
+package nodes
+
+import (
+	"path/filepath"
+	"testing"
+
+	"dify-vnext-go/pkg/knowledge"
+)
+
+func TestKnowledgeRetrievalDirRelativeToWorkflow(t *testing.T) {
+	t.Setenv(knowledge.DirEnv, "")
+	wfDir := t.TempDir()
+	for _, tt := range []struct {
+		kbDir, want string
+	}{
+		{"", filepath.Join(wfDir, knowledge.DefaultDir)},
+		{"bases", filepath.Join(wfDir, "bases")},
+		{"/srv/kb", "/srv/kb"},
+	} {
+		n := NewKnowledgeRetrievalNode("retrieve", map[string]interface{}{"knowledge_base": "handbook", "kb_dir": tt.kbDir}, wfDir)
+		if n.Dir != tt.want {
+			t.Errorf("kb_dir %q resolved to %q, want %q", tt.kbDir, n.Dir, tt.want)
+		}
+	}
+
+	// VNEXT_KB_DIR replaces the default
+	t.Setenv(knowledge.DirEnv, "/srv/kb")
+	if n := NewKnowledgeRetrievalNode("retrieve", map[string]interface{}{"knowledge_base": "handbook"}, wfDir); knowledge.Root(n.Dir) != "/srv/kb" {
+		t.Errorf("VNEXT_KB_DIR ignored: %q", knowledge.Root(n.Dir))
+	}
+}
@@ -2,12 +2,16 @@ package nodes
 
 import (
 	"bytes"
+	"dify-vnext-go/pkg/egress"
 	"dify-vnext-go/pkg/engine"
+	"dify-vnext-go/pkg/secrets"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strings"
 )
 
 type LLMNode struct {
@@ -27,13 +31,28 @@ func NewLLMNode(id string, config map[string]interface{}) *LLMNode {
 }
 
 type OpenAIRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
+	Model          string                 `json:"model"`
+	Messages       []Message              `json:"messages"`
+	Temperature    *float64               `json:"temperature,omitempty"`
+	ResponseFormat map[string]interface{} `json:"response_format,omitempty"`
+	Tools          []interface{}          `json:"tools,omitempty"`
+	ToolChoice     interface{}            `json:"tool_choice,omitempty"`
 }
 
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// ToolCall is a function call requested by the model
+type ToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
 }
 
 type OpenAIResponse struct {
@@ -47,22 +66,62 @@ type OpenAIResponse struct {
 
 func (n *LLMNode) Execute(ctx *engine.NodeContext) (map[string]interface{}, error) {
 	prompt, _ := ctx.Inputs["prompt"].(string)
-	fmt.Printf("[%s] Calling OpenAI (%s) with prompt: %s\n", n.ID(), n.Model, prompt)
 
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
+	model, err := resolveModel(ctx, n.Model)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("[%s] Calling OpenAI (%s) with prompt: %s\n", n.ID(), model, prompt)
+
+	msg, err := chatCompletion(ctx, OpenAIRequest{
+		Model: model,
+		Messages: []Message{
+			{Role: "user", Content: prompt},
+		},
+	})
+	if err == errNoAPIKey {
 		// Fallback to mock if no key provided, for safety/testing without cost
 		fmt.Printf("[%s] WARNING: OPENAI_API_KEY not set. Using Mock response.\n", n.ID())
 		return map[string]interface{}{
-			"response": fmt.Sprintf("Mock response (No Key) from %s: %s", n.Model, prompt),
+			"response": fmt.Sprintf("Mock response (No Key) from %s: %s", model, prompt),
 		}, nil
 	}
+	if err != nil {
+		return nil, err
+	}
 
-	reqBody := OpenAIRequest{
-		Model: n.Model,
-		Messages: []Message{
-			{Role: "user", Content: prompt},
-		},
+	content := msg.Content
+	fmt.Printf("[%s] OpenAI Response: %s...\n", n.ID(), content[:min(len(content), 50)])
+
+	return map[string]interface{}{
+		"response": content,
+	}, nil
+}
+
+// errNoAPIKey is returned by chatCompletion when OPENAI_API_KEY is not set; nodes fall
+// back to a mock result instead of failing
+var errNoAPIKey = errors.New("OPENAI_API_KEY not set")
+
+// resolveModel resolves a templated model name, e.g. {{ env.MODEL }}
+func resolveModel(ctx *engine.NodeContext, model string) (string, error) {
+	resolved, err := ctx.Engine.ResolveValue(model)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve model: %w", err)
+	}
+	return fmt.Sprintf("%v", resolved), nil
+}
+
+// chatCompletion sends a chat completion request with the OPENAI_API_KEY secret through
+// the node's egress client and returns the first choice. OPENAI_BASE_URL points it at a
+// compatible server.
+func chatCompletion(ctx *engine.NodeContext, reqBody OpenAIRequest) (*Message, error) {
+	apiKey := secrets.Get(ctx.Ctx, "OPENAI_API_KEY")
+	if apiKey == "" {
+		return nil, errNoAPIKey
+	}
+	baseURL := strings.TrimRight(os.Getenv("OPENAI_BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -70,7 +129,7 @@ func (n *LLMNode) Execute(ctx *engine.NodeContext) (map[string]interface{}, erro
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx.Ctx, "POST", baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -78,8 +137,7 @@ func (n *LLMNode) Execute(ctx *engine.NodeContext) (map[string]interface{}, erro
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+apiKey)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := egress.ClientFrom(ctx.Ctx).Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -102,13 +160,7 @@ func (n *LLMNode) Execute(ctx *engine.NodeContext) (map[string]interface{}, erro
 	if len(openAIResp.Choices) == 0 {
 		return nil, fmt.Errorf("no choices returned from OpenAI")
 	}
-
-	content := openAIResp.Choices[0].Message.Content
-	fmt.Printf("[%s] OpenAI Response: %s...\n", n.ID(), content[:min(len(content), 50)])
-
-	return map[string]interface{}{
-		"response": content,
-	}, nil
+	return &openAIResp.Choices[0].Message, nil
 }
 
 func min(a, b int) int {
@@ -2,6 +2,7 @@ package nodes
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 
@@ -11,43 +12,81 @@ import (
 
 type LoopNode struct {
 	BaseNode
-	SubWorkflow  *dsl.WorkflowDefinition
-	ParentEngine *engine.Engine // We need reference to parent engine to copy registry.
-	// Actually we can't pass ParentEngine easily via constructor unless we change main.
-	// Alternative: Pass it in Context? No, NodeContext has Memory but not Engine.
-	// Let's assume we can get registry from somewhere or just require it to be passed.
-	// Wait, NodeContext doesn't have Engine.
-	// We might need to update NodeContext to include the Engine or Registry.
+	SubWorkflow *dsl.WorkflowDefinition
+	Output      interface{} // Template selecting each iteration's result; nil keeps raw outputs
+	Reducer     string      // Optional built-in reducer applied to the results
+	Separator   string      // Separator used by the "concat" reducer
 }
 
-// We need a way to get the registry.
-// Let's update NodeContext in pkg/engine/node.go to include the Engine or Registry.
+func NewLoopNode(id string, config map[string]interface{}, dir string) *LoopNode {
+	subWf, err := parseSubWorkflow(config, dir)
+	if err != nil {
+		fmt.Printf("Error: %v in LoopNode config\n", err)
+		return &LoopNode{BaseNode: NewBaseNode(id, "Loop")}
+	}
+
+	separator, ok := config["separator"].(string)
+	if !ok {
+		separator = "\n"
+	}
+	reducer, _ := config["reducer"].(string)
+	return &LoopNode{
+		BaseNode:    NewBaseNode(id, "Loop"),
+		SubWorkflow: subWf,
+		Output:      config["output"],
+		Reducer:     reducer,
+		Separator:   separator,
+	}
+}
 
-func NewLoopNode(id string, config map[string]interface{}) *LoopNode {
-	// Parse sub_workflow from config
+// parseSubWorkflow reads the inline "sub_workflow" definition from a node config.
+// dir is the directory of the enclosing workflow file, inherited by the sub-workflow's nodes.
+func parseSubWorkflow(config map[string]interface{}, dir string) (*dsl.WorkflowDefinition, error) {
 	subWfMap, ok := config["sub_workflow"]
 	if !ok {
-		fmt.Printf("Error: sub_workflow missing in LoopNode config\n")
-		return &LoopNode{BaseNode: NewBaseNode(id, "Loop")}
+		return nil, fmt.Errorf("sub_workflow missing")
 	}
 
 	// Convert map to JSON then to struct (hacky but effective for dynamic map)
 	jsonBytes, err := json.Marshal(subWfMap)
 	if err != nil {
-		fmt.Printf("Error marshaling sub_workflow: %v\n", err)
-		return &LoopNode{BaseNode: NewBaseNode(id, "Loop")}
+		return nil, fmt.Errorf("failed to marshal sub_workflow: %w", err)
 	}
 
 	var subWf dsl.WorkflowDefinition
 	if err := json.Unmarshal(jsonBytes, &subWf); err != nil {
-		fmt.Printf("Error unmarshaling sub_workflow: %v\n", err)
-		return &LoopNode{BaseNode: NewBaseNode(id, "Loop")}
+		return nil, fmt.Errorf("failed to unmarshal sub_workflow: %w", err)
 	}
+	subWf.SetDir(dir)
 
-	return &LoopNode{
-		BaseNode:    NewBaseNode(id, "Loop"),
-		SubWorkflow: &subWf,
+	return &subWf, nil
+}
+
+// newSubEngine creates a child engine for a sub-workflow with fresh node instances.
+func newSubEngine(parent *engine.Engine, wf *dsl.WorkflowDefinition, mem engine.Memory) *engine.Engine {
+	return withNodes(parent.Fork(wf, mem), wf)
+}
+
+// withNodes registers the sub-workflow's nodes on a forked engine.
+// CreateNode creates NEW instances, which is what iterations need for isolation.
+func withNodes(subEngine *engine.Engine, wf *dsl.WorkflowDefinition) *engine.Engine {
+	for _, nodeDef := range wf.Nodes {
+		nodeInstance := CreateNode(nodeDef)
+		if nodeInstance != nil {
+			subEngine.RegisterNode(nodeInstance)
+		}
 	}
+	return subEngine
+}
+
+// rejectInterrupt turns a HumanInput suspending inside a Loop or While sub-workflow into a
+// plain error: an iteration cannot be resumed, so the run would ask again on every resume
+func rejectInterrupt(kind string, err error) error {
+	var interrupt *engine.InterruptError
+	if errors.As(err, &interrupt) {
+		return fmt.Errorf("node %s waits for human input, which is not supported inside %s sub-workflows; run it in a Workflow node or outside the %s", interrupt.NodeID, kind, kind)
+	}
+	return err
 }
 
 func (n *LoopNode) Execute(ctx *engine.NodeContext) (map[string]interface{}, error) {
@@ -70,6 +109,13 @@ func (n *LoopNode) Execute(ctx *engine.NodeContext) (map[string]interface{}, err
 		return nil, fmt.Errorf("input 'list' must be an array, got %T", listInput)
 	}
 
+	if n.Reducer != "" {
+		// Fail before spawning iterations rather than after all of them have run
+		if _, err := reduceResults(n.Reducer, nil, n.Separator); err != nil {
+			return nil, err
+		}
+	}
+
 	fmt.Printf("[%s] Starting Loop over %d items...\n", n.ID(), len(items))
 
 	// 2. Prepare Concurrency
@@ -83,39 +129,31 @@ func (n *LoopNode) Execute(ctx *engine.NodeContext) (map[string]interface{}, err
 		go func(index int, val interface{}) {
 			defer wg.Done()
 
-			// Create Sub-Engine
-			subEngine := engine.NewEngine(n.SubWorkflow)
-
-			// Register nodes for the sub-workflow
-			for _, nodeDef := range n.SubWorkflow.Nodes {
-				nodeInstance := CreateNode(nodeDef)
-				if nodeInstance != nil {
-					subEngine.RegisterNode(nodeInstance)
-				}
-			}
-
-			// Also register nodes from parent engine if needed (e.g. shared tools?)
-			// For now, let's assume sub-workflow is self-contained or uses standard nodes.
-			// If we want to share stateful nodes, we might need to copy.
-			// But CreateNode creates NEW instances, which is correct for Loop (isolation).
-
 			// Inject Loop Item into Memory using Child Scope
 			childMem := ctx.Memory.NewChild()
 			childMem.Set("loop_item", val)
 
-			// Set child memory scope to sub-engine
-			subEngine.SetMemory(childMem)
+			// Create Sub-Engine bound to the child memory scope
+			subEngine := newSubEngine(ctx.Engine, n.SubWorkflow, childMem)
 
 			// Run Sub-Workflow
 			// We pass empty inputs because we already populated the memory scope.
 			if err := subEngine.Run(ctx.Ctx, nil); err != nil {
-				errCh <- fmt.Errorf("iteration %d failed: %w", index, err)
+				errCh <- fmt.Errorf("iteration %d failed: %w", index, rejectInterrupt("Loop", err))
 				return
 			}
 
 			// Collect Results
-			outputs := subEngine.GetOutputs()
-			results[index] = outputs
+			if n.Output == nil {
+				results[index] = subEngine.GetOutputs()
+				return
+			}
+			result, err := subEngine.ResolveValue(n.Output)
+			if err != nil {
+				errCh <- fmt.Errorf("iteration %d output failed: %w", index, err)
+				return
+			}
+			results[index] = result
 		}(i, item)
 	}
 
@@ -129,7 +167,16 @@ func (n *LoopNode) Execute(ctx *engine.NodeContext) (map[string]interface{}, err
 
 	fmt.Printf("[%s] Loop completed.\n", n.ID())
 
-	return map[string]interface{}{
+	outputs := map[string]interface{}{
 		"results": results,
-	}, nil
+	}
+	if n.Reducer != "" {
+		reduced, err := reduceResults(n.Reducer, results, n.Separator)
+		if err != nil {
+			return nil, err
+		}
+		outputs["reduced"] = reduced
+	}
+
+	return outputs, nil
 }
@@ -0,0 +1,369 @@
+package nodes
+
+import (
+	"dify-vnext-go/pkg/engine"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ExtractorParameter is a value a ParameterExtractor pulls out of text
+type ExtractorParameter struct {
+	Name        string
+	Type        string // string, number, integer, boolean, object, array, array[string], array[number] or array[object]
+	Description string
+	Required    bool
+	Enum        []interface{}
+}
+
+// ParameterExtractorNode asks the LLM to extract typed parameters from the `text` input,
+// either through a forced tool call (mode: tool_call, the default) or a JSON response
+// (mode: json, for servers without tool calling).
+//
+//	config:
+//	  model: gpt-4o-mini
+//	  mode: tool_call
+//	  parameters:
+//	    - name: city
+//	      type: string
+//	      description: City the user asks about
+//	      required: true
+//	    - name: unit
+//	      type: string
+//	      enum: [celsius, fahrenheit]
+//	  instructions: ...      # optional extra guidance for the model
+//
+// Each parameter becomes an output of the same name, coerced to its type (nil when missing
+// or invalid). `_is_success` is false and `_reason` explains why when a required parameter
+// is missing or a value does not match its type or enum. Without an API key every
+// parameter gets a typed placeholder and `_reason` says the result is a mock.
+type ParameterExtractorNode struct {
+	BaseNode
+	Model        string
+	Mode         string
+	Parameters   []ExtractorParameter
+	Instructions string
+}
+
+func NewParameterExtractorNode(id string, config map[string]interface{}) *ParameterExtractorNode {
+	model, _ := config["model"].(string)
+	if model == "" {
+		model = "gpt-3.5-turbo"
+	}
+	mode, _ := config["mode"].(string)
+	if mode == "" {
+		mode = "tool_call"
+	}
+	instructions, _ := config["instructions"].(string)
+	node := &ParameterExtractorNode{
+		BaseNode:     NewBaseNode(id, "ParameterExtractor"),
+		Model:        model,
+		Mode:         mode,
+		Instructions: instructions,
+	}
+
+	if params, ok := config["parameters"].([]interface{}); ok {
+		for _, p := range params {
+			m, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			param := ExtractorParameter{}
+			param.Name, _ = m["name"].(string)
+			param.Type, _ = m["type"].(string)
+			param.Description, _ = m["description"].(string)
+			param.Required, _ = m["required"].(bool)
+			param.Enum, _ = m["enum"].([]interface{})
+			if param.Type == "" {
+				param.Type = "string"
+			}
+			node.Parameters = append(node.Parameters, param)
+		}
+	}
+	return node
+}
+
+func (n *ParameterExtractorNode) Execute(ctx *engine.NodeContext) (map[string]interface{}, error) {
+	if len(n.Parameters) == 0 {
+		return nil, fmt.Errorf("[%s] missing config 'parameters'", n.ID())
+	}
+	properties := make(map[string]interface{}, len(n.Parameters))
+	required := []string{}
+	for _, p := range n.Parameters {
+		if p.Name == "" || strings.HasPrefix(p.Name, "_") {
+			return nil, fmt.Errorf("[%s] invalid parameter name %q", n.ID(), p.Name)
+		}
+		schema, err := parameterSchema(p)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] %w", n.ID(), err)
+		}
+		properties[p.Name] = schema
+		if p.Required {
+			required = append(required, p.Name)
+		}
+	}
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+
+	input, ok := ctx.Inputs["text"]
+	if !ok || input == nil {
+		return nil, fmt.Errorf("[%s] missing input 'text'", n.ID())
+	}
+	text, ok := input.(string)
+	if !ok {
+		text = fmt.Sprintf("%v", input)
+	}
+
+	model, err := resolveModel(ctx, n.Model)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("[%s] Extracting %d parameters with %s (%s)\n", n.ID(), len(n.Parameters), model, n.Mode)
+
+	system := "Fill in the requested parameters from the user's text. Leave out parameters you cannot determine from it."
+	if n.Instructions != "" {
+		system += "\n\n" + n.Instructions
+	}
+	temperature := 0.0
+	req := OpenAIRequest{
+		Model:       model,
+		Temperature: &temperature,
+	}
+	switch n.Mode {
+	case "tool_call":
+		req.Tools = []interface{}{map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        "extract_parameters",
+				"description": "Record the parameters found in the text",
+				"parameters":  schema,
+			},
+		}}
+		req.ToolChoice = map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "extract_parameters"}}
+	case "json":
+		schemaJSON, _ := json.MarshalIndent(schema, "", "  ")
+		system += "\n\nRespond with a single JSON object matching this JSON Schema:\n" + string(schemaJSON)
+		req.ResponseFormat = map[string]interface{}{"type": "json_object"}
+	default:
+		return nil, fmt.Errorf("[%s] unknown mode '%s'", n.ID(), n.Mode)
+	}
+	req.Messages = []Message{
+		{Role: "system", Content: system},
+		{Role: "user", Content: text},
+	}
+
+	msg, err := chatCompletion(ctx, req)
+	if err == errNoAPIKey {
+		fmt.Printf("[%s] WARNING: OPENAI_API_KEY not set. Using Mock extraction.\n", n.ID())
+		result := n.result(n.mockValues(), "")
+		result["_reason"] = "mock extraction (no API key)"
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("[%s] %w", n.ID(), err)
+	}
+
+	raw := msg.Content
+	if n.Mode == "tool_call" {
+		if len(msg.ToolCalls) == 0 {
+			return n.result(nil, "model did not call extract_parameters"), nil
+		}
+		raw = msg.ToolCalls[0].Function.Arguments
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(stripCodeFence(raw)), &values); err != nil {
+		return n.result(nil, fmt.Sprintf("model returned invalid JSON: %v", err)), nil
+	}
+
+	var problems []string
+	outputs := make(map[string]interface{}, len(values))
+	for _, p := range n.Parameters {
+		value, present := values[p.Name]
+		if !present || value == nil || value == "" {
+			if p.Required {
+				problems = append(problems, fmt.Sprintf("missing required parameter '%s'", p.Name))
+			}
+			continue
+		}
+		coerced, err := coerceParameter(p, value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("parameter '%s': %v", p.Name, err))
+			continue
+		}
+		outputs[p.Name] = coerced
+	}
+	result := n.result(outputs, strings.Join(problems, "; "))
+	fmt.Printf("[%s] Extracted %d of %d parameters (success: %v)\n", n.ID(), len(outputs), len(n.Parameters), result["_is_success"])
+	return result, nil
+}
+
+// result sets every parameter output (nil when not extracted) plus _is_success and _reason
+func (n *ParameterExtractorNode) result(values map[string]interface{}, reason string) map[string]interface{} {
+	out := make(map[string]interface{}, len(n.Parameters)+2)
+	for _, p := range n.Parameters {
+		out[p.Name] = values[p.Name]
+	}
+	out["_is_success"] = values != nil && reason == ""
+	out["_reason"] = reason
+	return out
+}
+
+// mockValues returns a placeholder of the right type for every parameter, so workflows
+// run end to end without an API key (a Loop over an array parameter gets one item)
+func (n *ParameterExtractorNode) mockValues() map[string]interface{} {
+	values := make(map[string]interface{}, len(n.Parameters))
+	for _, p := range n.Parameters {
+		if len(p.Enum) > 0 {
+			values[p.Name] = p.Enum[0]
+			continue
+		}
+		switch p.Type {
+		case "string":
+			values[p.Name] = "mock " + p.Name
+		case "number":
+			values[p.Name] = 0.0
+		case "integer":
+			values[p.Name] = 0
+		case "boolean":
+			values[p.Name] = false
+		case "object":
+			values[p.Name] = map[string]interface{}{}
+		case "array[number]":
+			values[p.Name] = []interface{}{0.0}
+		case "array[object]":
+			values[p.Name] = []interface{}{map[string]interface{}{}}
+		default:
+			values[p.Name] = []interface{}{"mock " + p.Name}
+		}
+	}
+	return values
+}
+
+// parameterSchema converts a parameter to the JSON Schema given to the model
+func parameterSchema(p ExtractorParameter) (map[string]interface{}, error) {
+	schema := map[string]interface{}{}
+	switch p.Type {
+	case "string", "number", "integer", "boolean", "object", "array":
+		schema["type"] = p.Type
+	case "array[string]", "array[number]", "array[object]":
+		schema["type"] = "array"
+		schema["items"] = map[string]interface{}{"type": strings.TrimSuffix(strings.TrimPrefix(p.Type, "array["), "]")}
+	default:
+		return nil, fmt.Errorf("parameter '%s' has unknown type '%s'", p.Name, p.Type)
+	}
+	if p.Description != "" {
+		schema["description"] = p.Description
+	}
+	if len(p.Enum) > 0 {
+		schema["enum"] = p.Enum
+	}
+	return schema, nil
+}
+
+// coerceParameter converts an extracted value to the parameter's type, accepting the
+// string forms models often produce ("3", "true"), and checks the enum
+func coerceParameter(p ExtractorParameter, value interface{}) (interface{}, error) {
+	var out interface{}
+	switch p.Type {
+	case "string":
+		switch v := value.(type) {
+		case string:
+			out = v
+		case float64, bool:
+			out = fmt.Sprintf("%v", v)
+		default:
+			return nil, fmt.Errorf("expected string, got %T", value)
+		}
+	case "number", "integer":
+		f, err := toFloat(value)
+		if err != nil {
+			return nil, err
+		}
+		out = f
+		if p.Type == "integer" {
+			if f != math.Trunc(f) {
+				return nil, fmt.Errorf("expected integer, got %v", f)
+			}
+			out = int(f)
+		}
+	case "boolean":
+		switch v := value.(type) {
+		case bool:
+			out = v
+		case string:
+			b, err := strconv.ParseBool(strings.ToLower(strings.TrimSpace(v)))
+			if err != nil {
+				return nil, fmt.Errorf("expected boolean, got %q", v)
+			}
+			out = b
+		default:
+			return nil, fmt.Errorf("expected boolean, got %T", value)
+		}
+	case "object":
+		if _, ok := value.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("expected object, got %T", value)
+		}
+		out = value
+	default: // Arrays
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected array, got %T", value)
+		}
+		if p.Type != "array" {
+			item := ExtractorParameter{Type: strings.TrimSuffix(strings.TrimPrefix(p.Type, "array["), "]")}
+			coerced := make([]interface{}, len(list))
+			for i, v := range list {
+				c, err := coerceParameter(item, v)
+				if err != nil {
+					return nil, fmt.Errorf("item %d: %w", i, err)
+				}
+				coerced[i] = c
+			}
+			list = coerced
+		}
+		out = list
+	}
+
+	if len(p.Enum) > 0 {
+		for _, allowed := range p.Enum {
+			if fmt.Sprintf("%v", allowed) == fmt.Sprintf("%v", out) {
+				return out, nil
+			}
+		}
+		return nil, fmt.Errorf("%v is not one of %v", out, p.Enum)
+	}
+	return out, nil
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("expected number, got %q", v)
+		}
+		return f, nil
+	default:
+		return 0, fmt.Errorf("expected number, got %T", value)
+	}
+}
+
+// stripCodeFence removes a Markdown code fence around a JSON answer
+func stripCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```")
+	if nl := strings.IndexByte(s, '\n'); nl >= 0 {
+		s = s[nl+1:] // Language tag
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+}
@@ -0,0 +1,224 @@
+package nodes
+
+import (
+	"dify-vnext-go/pkg/engine"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// QuestionClass is a category a QuestionClassifier can choose
+type QuestionClass struct {
+	ID          string
+	Description string
+	Examples    []string
+}
+
+// QuestionClassifierNode asks the LLM to put the `query` input into one of the configured
+// classes. The chosen class id becomes the _branch_id, so edges route on it via source_handle.
+//
+//	config:
+//	  model: gpt-4o-mini
+//	  classes:
+//	    - id: billing
+//	      description: Charges, refunds, invoices and subscriptions
+//	      examples: ["I was charged twice"]
+//	    - id: technical
+//	      description: Errors, outages and how-to questions about the product
+//	  fallback: general      # optional: chosen when the model cannot decide
+//	  instructions: ...      # optional extra guidance for the model
+//
+// Outputs `class_id`, `confidence` (0..1, as estimated by the model) and `reason`.
+type QuestionClassifierNode struct {
+	BaseNode
+	Model        string
+	Classes      []QuestionClass
+	Fallback     string
+	Instructions string
+}
+
+func NewQuestionClassifierNode(id string, config map[string]interface{}) *QuestionClassifierNode {
+	model, _ := config["model"].(string)
+	if model == "" {
+		model = "gpt-3.5-turbo"
+	}
+	fallback, _ := config["fallback"].(string)
+	instructions, _ := config["instructions"].(string)
+	node := &QuestionClassifierNode{
+		BaseNode:     NewBaseNode(id, "QuestionClassifier"),
+		Model:        model,
+		Fallback:     fallback,
+		Instructions: instructions,
+	}
+
+	if classes, ok := config["classes"].([]interface{}); ok {
+		for _, c := range classes {
+			m, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			class := QuestionClass{}
+			class.ID, _ = m["id"].(string)
+			class.Description, _ = m["description"].(string)
+			if examples, ok := m["examples"].([]interface{}); ok {
+				for _, e := range examples {
+					class.Examples = append(class.Examples, fmt.Sprintf("%v", e))
+				}
+			}
+			node.Classes = append(node.Classes, class)
+		}
+	}
+	return node
+}
+
+func (n *QuestionClassifierNode) Execute(ctx *engine.NodeContext) (map[string]interface{}, error) {
+	if len(n.Classes) == 0 {
+		return nil, fmt.Errorf("[%s] missing config 'classes'", n.ID())
+	}
+	ids := make([]interface{}, 0, len(n.Classes)+1)
+	for _, c := range n.Classes {
+		if c.ID == "" {
+			return nil, fmt.Errorf("[%s] every class needs an id", n.ID())
+		}
+		ids = append(ids, c.ID)
+	}
+	if n.Fallback != "" && n.class(n.Fallback) == nil {
+		ids = append(ids, n.Fallback)
+	}
+
+	input, ok := ctx.Inputs["query"]
+	if !ok || input == nil {
+		return nil, fmt.Errorf("[%s] missing input 'query'", n.ID())
+	}
+	query, ok := input.(string)
+	if !ok {
+		query = fmt.Sprintf("%v", input)
+	}
+
+	model, err := resolveModel(ctx, n.Model)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("[%s] Classifying with %s into %d classes\n", n.ID(), model, len(ids))
+
+	temperature := 0.0
+	msg, err := chatCompletion(ctx, OpenAIRequest{
+		Model: model,
+		Messages: []Message{
+			{Role: "system", Content: n.systemPrompt()},
+			{Role: "user", Content: query},
+		},
+		Temperature: &temperature,
+		ResponseFormat: map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":   "classification",
+				"strict": true,
+				"schema": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"class_id":   map[string]interface{}{"type": "string", "enum": ids},
+						"confidence": map[string]interface{}{"type": "number"},
+						"reason":     map[string]interface{}{"type": "string"},
+					},
+					"required":             []string{"class_id", "confidence", "reason"},
+					"additionalProperties": false,
+				},
+			},
+		},
+	})
+	if err == errNoAPIKey {
+		fmt.Printf("[%s] WARNING: OPENAI_API_KEY not set. Using Mock classification.\n", n.ID())
+		return n.result(n.defaultClass(), 0, "mock classification (no API key)")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("[%s] %w", n.ID(), err)
+	}
+
+	var answer struct {
+		ClassID    string  `json:"class_id"`
+		Confidence float64 `json:"confidence"`
+		Reason     string  `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(msg.Content)), &answer); err != nil {
+		// Servers without structured output may answer with the bare class id
+		answer.ClassID = strings.Trim(strings.TrimSpace(msg.Content), "\"'`.")
+	}
+	classID := n.match(answer.ClassID)
+	if classID == "" {
+		if n.Fallback == "" {
+			return nil, fmt.Errorf("[%s] model chose unknown class %q", n.ID(), answer.ClassID)
+		}
+		classID, answer.Confidence = n.Fallback, 0
+		answer.Reason = fmt.Sprintf("model chose unknown class %q", answer.ClassID)
+	}
+	if answer.Confidence < 0 {
+		answer.Confidence = 0
+	} else if answer.Confidence > 1 {
+		answer.Confidence = 1
+	}
+	fmt.Printf("[%s] Class: %s (confidence %.2f)\n", n.ID(), classID, answer.Confidence)
+	return n.result(classID, answer.Confidence, answer.Reason)
+}
+
+func (n *QuestionClassifierNode) result(classID string, confidence float64, reason string) (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"class_id":   classID,
+		"confidence": confidence,
+		"reason":     reason,
+		"_branch_id": classID,
+	}, nil
+}
+
+func (n *QuestionClassifierNode) systemPrompt() string {
+	var sb strings.Builder
+	sb.WriteString("Classify the user's message into exactly one of these classes.\n\n")
+	for _, c := range n.Classes {
+		fmt.Fprintf(&sb, "- %s", c.ID)
+		if c.Description != "" {
+			fmt.Fprintf(&sb, ": %s", c.Description)
+		}
+		sb.WriteString("\n")
+		for _, e := range c.Examples {
+			fmt.Fprintf(&sb, "  Example: %q\n", e)
+		}
+	}
+	if n.Fallback != "" && n.class(n.Fallback) == nil {
+		fmt.Fprintf(&sb, "- %s: anything that fits none of the classes above\n", n.Fallback)
+	}
+	if n.Instructions != "" {
+		fmt.Fprintf(&sb, "\n%s\n", n.Instructions)
+	}
+	sb.WriteString("\nRespond with JSON: {\"class_id\": one of the class ids, \"confidence\": a number from 0 to 1, \"reason\": one short sentence}.")
+	return sb.String()
+}
+
+func (n *QuestionClassifierNode) class(id string) *QuestionClass {
+	for i := range n.Classes {
+		if n.Classes[i].ID == id {
+			return &n.Classes[i]
+		}
+	}
+	return nil
+}
+
+// match maps the model's answer to a class id, ignoring case
+func (n *QuestionClassifierNode) match(answer string) string {
+	if n.Fallback != "" && strings.EqualFold(answer, n.Fallback) {
+		return n.Fallback
+	}
+	for _, c := range n.Classes {
+		if strings.EqualFold(answer, c.ID) {
+			return c.ID
+		}
+	}
+	return ""
+}
+
+// defaultClass is used without a model: the fallback, else the first class
+func (n *QuestionClassifierNode) defaultClass() string {
+	if n.Fallback != "" {
+		return n.Fallback
+	}
+	return n.Classes[0].ID
+}
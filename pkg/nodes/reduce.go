@@ -0,0 +1,92 @@
+package nodes
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// reduceResults folds loop results with one of the built-in reducers.
+// Nil results (e.g. an iteration whose selected output was skipped) are ignored.
+func reduceResults(reducer string, results []interface{}, separator string) (interface{}, error) {
+	switch reducer {
+	case "concat":
+		parts := make([]string, 0, len(results))
+		for _, r := range results {
+			if r == nil {
+				continue
+			}
+			parts = append(parts, fmt.Sprintf("%v", r))
+		}
+		return strings.Join(parts, separator), nil
+
+	case "sum":
+		var intSum int64
+		var floatSum float64
+		isFloat := false
+		for i, r := range results {
+			if r == nil {
+				continue
+			}
+			switch v := r.(type) {
+			case int:
+				intSum += int64(v)
+			case int64:
+				intSum += v
+			case float64:
+				floatSum += v
+				isFloat = true
+			case string:
+				f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+				if err != nil {
+					return nil, fmt.Errorf("sum reducer: result %d is not a number: %q", i, v)
+				}
+				floatSum += f
+				isFloat = true
+			default:
+				return nil, fmt.Errorf("sum reducer: result %d is not a number (%T)", i, r)
+			}
+		}
+		if isFloat {
+			return floatSum + float64(intSum), nil
+		}
+		return intSum, nil
+
+	case "flatten":
+		flat := make([]interface{}, 0, len(results))
+		for _, r := range results {
+			if r == nil {
+				continue
+			}
+			rv := reflect.ValueOf(r)
+			if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+				flat = append(flat, r)
+				continue
+			}
+			for j := 0; j < rv.Len(); j++ {
+				flat = append(flat, rv.Index(j).Interface())
+			}
+		}
+		return flat, nil
+
+	case "merge":
+		merged := make(map[string]interface{})
+		for i, r := range results {
+			if r == nil {
+				continue
+			}
+			m, ok := r.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("merge reducer: result %d is not an object (%T)", i, r)
+			}
+			for k, v := range m {
+				merged[k] = v
+			}
+		}
+		return merged, nil
+
+	default:
+		return nil, fmt.Errorf("unknown reducer: %s", reducer)
+	}
+}
@@ -0,0 +1,130 @@
+package nodes
+
+import (
+	"dify-vnext-go/pkg/engine"
+	"dify-vnext-go/pkg/textsplit"
+	"fmt"
+)
+
+const (
+	defaultChunkSize    = 1000
+	defaultChunkOverlap = 100
+)
+
+// TextSplitterNode cuts the `text` input into chunks for a Loop or for indexing.
+//
+//	config:
+//	  mode: recursive        # recursive (characters), token or markdown
+//	  chunk_size: 500        # characters, or tokens in token mode
+//	  chunk_overlap: 50
+//	  separators: ["\n\n", "\n", ". ", " ", ""]   # optional
+//	  tokenizer: approx      # token mode: approx, whitespace, chars or a registered tokenizer
+//
+// Outputs `chunks` (maps with text, index, start, end and, where known, tokens and
+// headings; offsets are bytes into the input), `texts` (the chunk texts, ready for a
+// Loop's `list` input) and `count`.
+type TextSplitterNode struct {
+	BaseNode
+	Mode         string
+	ChunkSize    int
+	ChunkOverlap int
+	Separators   []string
+	Tokenizer    string
+}
+
+func NewTextSplitterNode(id string, config map[string]interface{}) *TextSplitterNode {
+	mode, _ := config["mode"].(string)
+	if mode == "" {
+		mode = "recursive"
+	}
+	tokenizer, _ := config["tokenizer"].(string)
+	var separators []string
+	if list, ok := config["separators"].([]interface{}); ok {
+		for _, sep := range list {
+			if s, ok := sep.(string); ok {
+				separators = append(separators, s)
+			}
+		}
+	}
+	return &TextSplitterNode{
+		BaseNode:     NewBaseNode(id, "TextSplitter"),
+		Mode:         mode,
+		ChunkSize:    configInt(config, "chunk_size", defaultChunkSize),
+		ChunkOverlap: configInt(config, "chunk_overlap", defaultChunkOverlap),
+		Separators:   separators,
+		Tokenizer:    tokenizer,
+	}
+}
+
+func (n *TextSplitterNode) Execute(ctx *engine.NodeContext) (map[string]interface{}, error) {
+	input, ok := ctx.Inputs["text"]
+	if !ok || input == nil {
+		return nil, fmt.Errorf("[%s] missing input 'text'", n.ID())
+	}
+	text, ok := input.(string)
+	if !ok {
+		text = fmt.Sprintf("%v", input)
+	}
+
+	splitter := &textsplit.Splitter{
+		ChunkSize:    n.ChunkSize,
+		ChunkOverlap: n.ChunkOverlap,
+		Separators:   n.Separators,
+	}
+	tokenizer := n.Tokenizer
+	if tokenizer == "" && n.Mode == "token" {
+		tokenizer = "approx"
+	}
+	if tokenizer != "" {
+		t, err := textsplit.GetTokenizer(tokenizer)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] %w", n.ID(), err)
+		}
+		splitter.Tokenizer = t
+	}
+
+	var (
+		chunks []textsplit.Chunk
+		err    error
+	)
+	switch n.Mode {
+	case "recursive", "token":
+		chunks, err = splitter.Split(text)
+	case "markdown":
+		chunks, err = splitter.SplitMarkdown(text)
+	default:
+		return nil, fmt.Errorf("[%s] unknown mode '%s'", n.ID(), n.Mode)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("[%s] %w", n.ID(), err)
+	}
+	fmt.Printf("[%s] Split %d characters into %d chunks (%s)\n", n.ID(), len(text), len(chunks), n.Mode)
+
+	items := make([]interface{}, len(chunks))
+	texts := make([]interface{}, len(chunks))
+	for i, c := range chunks {
+		item := map[string]interface{}{
+			"index": c.Index,
+			"text":  c.Text,
+			"start": c.Start,
+			"end":   c.End,
+		}
+		if splitter.Tokenizer != nil {
+			item["tokens"] = c.Tokens
+		}
+		if c.Headings != nil {
+			headings := make([]interface{}, len(c.Headings))
+			for j, h := range c.Headings {
+				headings[j] = h
+			}
+			item["headings"] = headings
+		}
+		items[i] = item
+		texts[i] = c.Text
+	}
+	return map[string]interface{}{
+		"chunks": items,
+		"texts":  texts,
+		"count":  len(chunks),
+	}, nil
+}
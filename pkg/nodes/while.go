@@ -0,0 +1,161 @@
+package nodes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"dify-vnext-go/pkg/dsl"
+	"dify-vnext-go/pkg/engine"
+)
+
+// WhileNode re-runs a sub-workflow until a break condition holds or max_iterations is reached.
+// Loop variables are seeded from the node inputs and persist across iterations.
+//
+// break_condition is either a condition over the iteration's outputs or memory
+//
+//	break_condition:
+//	  variable: "{{ memory.score }}"
+//	  operator: gte          # any IfElse operator, e.g. equals, contains, gt, lte, empty
+//	  value: 8
+//
+// or a template that resolves to a boolean, e.g. break_condition: "{{ critic.approved }}".
+type WhileNode struct {
+	BaseNode
+	SubWorkflow    *dsl.WorkflowDefinition
+	MaxIterations  int
+	BreakCondition *Condition
+	BreakWhen      string                 // Template resolving to a boolean, used instead of BreakCondition
+	Updates        map[string]interface{} // Loop variable -> template resolved after each iteration
+}
+
+func NewWhileNode(id string, config map[string]interface{}, dir string) *WhileNode {
+	node := &WhileNode{
+		BaseNode:      NewBaseNode(id, "While"),
+		MaxIterations: configInt(config, "max_iterations", 10),
+	}
+
+	subWf, err := parseSubWorkflow(config, dir)
+	if err != nil {
+		fmt.Printf("Error: %v in WhileNode config\n", err)
+		return node
+	}
+	node.SubWorkflow = subWf
+
+	switch cond := config["break_condition"].(type) {
+	case map[string]interface{}:
+		v, _ := cond["variable"].(string)
+		op, _ := cond["operator"].(string)
+		node.BreakCondition = &Condition{Variable: v, Operator: op, Value: conditionString(cond["value"])}
+	case string:
+		node.BreakWhen = cond
+	}
+
+	if updates, ok := config["update"].(map[string]interface{}); ok {
+		node.Updates = updates
+	}
+
+	return node
+}
+
+func (n *WhileNode) Execute(ctx *engine.NodeContext) (map[string]interface{}, error) {
+	if n.SubWorkflow == nil {
+		return nil, fmt.Errorf("while node %s has no sub_workflow", n.ID())
+	}
+	if n.MaxIterations <= 0 {
+		return nil, fmt.Errorf("max_iterations must be positive, got %d", n.MaxIterations)
+	}
+
+	// All iterations share one scope so loop variables (and anything the
+	// sub-workflow writes to memory) carry over to the next iteration.
+	loopMem := ctx.Memory.NewChild()
+	for k, v := range ctx.Inputs {
+		loopMem.Set(k, v)
+	}
+
+	fmt.Printf("[%s] Starting While loop (max %d iterations)...\n", n.ID(), n.MaxIterations)
+
+	iterations := 0
+	completed := false
+	for iterations < n.MaxIterations {
+		if err := ctx.Ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		loopMem.Set("loop_index", iterations)
+		subEngine := newSubEngine(ctx.Engine, n.SubWorkflow, loopMem)
+		if err := subEngine.Run(ctx.Ctx, nil); err != nil {
+			return nil, fmt.Errorf("iteration %d failed: %w", iterations, rejectInterrupt("While", err))
+		}
+		iterations++
+
+		// Resolve all updates before assigning any, so every template sees the same iteration state
+		resolved := make(map[string]interface{}, len(n.Updates))
+		for name, tmpl := range n.Updates {
+			val, err := subEngine.ResolveValue(tmpl)
+			if err != nil {
+				return nil, fmt.Errorf("failed to update loop variable %s: %w", name, err)
+			}
+			resolved[name] = val
+		}
+		for name, val := range resolved {
+			loopMem.Set(name, val)
+		}
+
+		done, err := n.shouldBreak(subEngine)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			fmt.Printf("[%s] Break condition met after %d iteration(s).\n", n.ID(), iterations)
+			completed = true
+			break
+		}
+	}
+
+	if !completed {
+		fmt.Printf("[%s] Reached max_iterations (%d).\n", n.ID(), n.MaxIterations)
+	}
+
+	outputs := make(map[string]interface{})
+	for k := range ctx.Inputs {
+		outputs[k], _ = loopMem.Get(k)
+	}
+	for k := range n.Updates {
+		outputs[k], _ = loopMem.Get(k)
+	}
+	outputs["iterations"] = iterations
+	outputs["completed"] = completed
+
+	return outputs, nil
+}
+
+// shouldBreak evaluates the break condition against the finished iteration
+func (n *WhileNode) shouldBreak(subEngine *engine.Engine) (bool, error) {
+	switch {
+	case n.BreakCondition != nil:
+		val, err := subEngine.ResolveValue(n.BreakCondition.Variable)
+		if err != nil {
+			return false, fmt.Errorf("failed to evaluate break condition: %w", err)
+		}
+		return n.BreakCondition.Evaluate(conditionString(val)), nil
+	case n.BreakWhen != "":
+		val, err := subEngine.ResolveValue(n.BreakWhen)
+		if err != nil {
+			return false, fmt.Errorf("failed to evaluate break condition: %w", err)
+		}
+		switch v := val.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return false, fmt.Errorf("break condition %q resolved to %q, not a boolean", n.BreakWhen, v)
+			}
+			return b, nil
+		default:
+			return false, fmt.Errorf("break condition %q resolved to %v, not a boolean", n.BreakWhen, val)
+		}
+	}
+	return false, nil
+}
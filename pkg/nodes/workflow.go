@@ -0,0 +1,153 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"dify-vnext-go/pkg/dsl"
+	"dify-vnext-go/pkg/engine"
+	"dify-vnext-go/pkg/tools"
+)
+
+// maxWorkflowDepth guards against workflows that (indirectly) call themselves
+const maxWorkflowDepth = 16
+
+type workflowDepthKey struct{}
+
+var (
+	workflowRegistryMu sync.RWMutex
+	workflowRegistry   = make(map[string]*dsl.WorkflowDefinition)
+)
+
+// RegisterWorkflow makes a workflow callable by name from Workflow nodes
+func RegisterWorkflow(name string, wf *dsl.WorkflowDefinition) {
+	workflowRegistryMu.Lock()
+	defer workflowRegistryMu.Unlock()
+	workflowRegistry[name] = wf
+}
+
+// RegisterWorkflowDir registers every YAML file in dir under its file name without extension
+func RegisterWorkflowDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read workflow dir: %w", err)
+	}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		wf, err := dsl.Parse(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to load workflow %s: %w", entry.Name(), err)
+		}
+		RegisterWorkflow(strings.TrimSuffix(entry.Name(), ext), wf)
+	}
+	return nil
+}
+
+func lookupWorkflow(name string) (*dsl.WorkflowDefinition, bool) {
+	workflowRegistryMu.RLock()
+	defer workflowRegistryMu.RUnlock()
+	wf, ok := workflowRegistry[name]
+	return wf, ok
+}
+
+// WorkflowNode runs another workflow as a building block.
+// Its inputs become the child workflow's memory and its End node outputs become the node outputs.
+type WorkflowNode struct {
+	BaseNode
+	Name     string // Registered workflow name, resolved at execution time
+	Path     string // Workflow file, loaded when the node is created; relative to the including workflow's file
+	Workflow *dsl.WorkflowDefinition
+	LoadErr  error // Why Path could not be loaded, reported by Execute
+}
+
+func NewWorkflowNode(id string, config map[string]interface{}, dir string) *WorkflowNode {
+	name, _ := config["name"].(string)
+	path, _ := config["path"].(string)
+	path = resolvePath(dir, path)
+	node := &WorkflowNode{
+		BaseNode: NewBaseNode(id, "Workflow"),
+		Name:     name,
+		Path:     path,
+	}
+
+	if path != "" {
+		wf, err := dsl.Parse(path)
+		if err != nil {
+			fmt.Printf("Error loading workflow %s for WorkflowNode %s: %v\n", path, id, err)
+			node.LoadErr = fmt.Errorf("failed to load workflow %s: %w", path, err)
+			return node
+		}
+		node.Workflow = wf
+	}
+
+	return node
+}
+
+func (n *WorkflowNode) Execute(ctx *engine.NodeContext) (map[string]interface{}, error) {
+	if n.LoadErr != nil {
+		return nil, n.LoadErr
+	}
+	wf := n.Workflow
+	if wf == nil && n.Name != "" {
+		registered, ok := lookupWorkflow(n.Name)
+		if !ok {
+			return nil, fmt.Errorf("workflow not registered: %s", n.Name)
+		}
+		wf = registered
+	}
+	if wf == nil {
+		return nil, fmt.Errorf("workflow node %s needs a loadable 'path' or a registered 'name'", n.ID())
+	}
+
+	depth, _ := ctx.Ctx.Value(workflowDepthKey{}).(int)
+	if depth >= maxWorkflowDepth {
+		return nil, fmt.Errorf("workflow nesting exceeds %d levels (recursive workflow?)", maxWorkflowDepth)
+	}
+	runCtx := context.WithValue(ctx.Ctx, workflowDepthKey{}, depth+1)
+
+	fmt.Printf("[%s] Running workflow: %s\n", n.ID(), wf.Name)
+
+	childMem := ctx.Memory.NewChild()
+	for k, v := range ctx.Inputs {
+		childMem.Set(k, v)
+	}
+
+	// ctx.Fork hands a resume on to a HumanInput that suspended inside the child
+	subEngine := withNodes(ctx.Fork(wf, childMem), wf)
+	if len(wf.ToolProviders) > 0 {
+		// The child's providers are visible to the child only, for the duration of this run
+		registry := subEngine.Tools().Child()
+		closeProviders, err := tools.RegisterProviders(ctx.Ctx, registry, wf.ToolProviders)
+		if err != nil {
+			return nil, fmt.Errorf("workflow %s: failed to register tool providers: %w", wf.Name, err)
+		}
+		defer closeProviders()
+		subEngine.SetTools(registry)
+	}
+	if err := subEngine.Run(runCtx, nil); err != nil {
+		return nil, fmt.Errorf("workflow %s failed: %w", wf.Name, err)
+	}
+
+	// Expose the outputs of the child's End node(s); a skipped End node has no outputs
+	outputs := make(map[string]interface{})
+	childOutputs := subEngine.GetOutputs()
+	for _, nodeDef := range wf.Nodes {
+		if nodeDef.Type != "End" {
+			continue
+		}
+		for k, v := range childOutputs[nodeDef.ID] {
+			outputs[k] = v
+		}
+	}
+
+	fmt.Printf("[%s] Workflow %s completed.\n", n.ID(), wf.Name)
+
+	return outputs, nil
+}
@@ -0,0 +1,270 @@
+// Package secrets resolves credentials for workflows and redacts them from anything the
+// engine emits or persists.
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrNotFound is returned when no store holds the requested secret
+var ErrNotFound = errors.New("secret not found")
+
+// KeyEnv holds the base64-encoded 32-byte key for encrypted secret files
+const KeyEnv = "VNEXT_SECRETS_KEY"
+
+// encryptedHeader starts every encrypted secrets file
+const encryptedHeader = "VNEXT-SECRETS-V1"
+
+// Store looks up secrets by name
+type Store interface {
+	Get(name string) (string, error)
+}
+
+// EnvStore reads secrets from environment variables, optionally with a name prefix
+type EnvStore struct {
+	Prefix string
+}
+
+func (s EnvStore) Get(name string) (string, error) {
+	if v, ok := os.LookupEnv(s.Prefix + name); ok {
+		return v, nil
+	}
+	return "", ErrNotFound
+}
+
+// MapStore holds secrets in memory
+type MapStore map[string]string
+
+func (s MapStore) Get(name string) (string, error) {
+	if v, ok := s[name]; ok {
+		return v, nil
+	}
+	return "", ErrNotFound
+}
+
+// Chain queries stores in order and returns the first hit
+type Chain []Store
+
+func (c Chain) Get(name string) (string, error) {
+	for _, s := range c {
+		v, err := s.Get(name)
+		if err == nil {
+			return v, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return "", err
+		}
+	}
+	return "", ErrNotFound
+}
+
+// LoadDotenv reads a dotenv file: KEY=VALUE lines, optional "export" prefixes,
+// single or double quoted values and # comments.
+func LoadDotenv(path string) (MapStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dotenv file: %w", err)
+	}
+	return parseDotenv(data, path)
+}
+
+func parseDotenv(data []byte, path string) (MapStore, error) {
+	store := MapStore{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNo)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(value, `"`):
+			end := closingQuote(value, '"')
+			if end < 0 {
+				return nil, fmt.Errorf("%s:%d: unterminated quote", path, lineNo)
+			}
+			value = strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(value[1:end])
+		case strings.HasPrefix(value, "'"):
+			end := closingQuote(value, '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("%s:%d: unterminated quote", path, lineNo)
+			}
+			value = value[1:end]
+		default:
+			// Unquoted values may carry a trailing comment
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		store[key] = value
+	}
+	return store, scanner.Err()
+}
+
+// closingQuote returns the index of the quote closing s[0], skipping backslash escapes in double quotes
+func closingQuote(s string, quote byte) int {
+	for i := 1; i < len(s); i++ {
+		if quote == '"' && s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == quote {
+			return i
+		}
+	}
+	return -1
+}
+
+// LoadFile loads a secrets file, detecting whether it is encrypted or a dotenv file.
+// Encrypted files are decrypted with the key from VNEXT_SECRETS_KEY.
+func LoadFile(path string) (MapStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+	if !bytes.HasPrefix(data, []byte(encryptedHeader)) {
+		return parseDotenv(data, path)
+	}
+	key, err := KeyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return decrypt(data, key)
+}
+
+// KeyFromEnv decodes the encryption key from VNEXT_SECRETS_KEY
+func KeyFromEnv() ([]byte, error) {
+	encoded := os.Getenv(KeyEnv)
+	if encoded == "" {
+		return nil, fmt.Errorf("%s is not set", KeyEnv)
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s must be a base64-encoded 32-byte key", KeyEnv)
+	}
+	return key, nil
+}
+
+// GenerateKey returns a new random key, base64-encoded for VNEXT_SECRETS_KEY
+func GenerateKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// LoadEncryptedFile decrypts a secrets file written by SaveEncryptedFile.
+// A missing file yields an empty store.
+func LoadEncryptedFile(path string, key []byte) (MapStore, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return MapStore{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+	return decrypt(data, key)
+}
+
+// SaveEncryptedFile encrypts secrets with AES-256-GCM and writes them atomically
+func SaveEncryptedFile(path string, key []byte, secrets MapStore) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := gcm.Seal(nonce, nonce, plain, []byte(encryptedHeader))
+	content := encryptedHeader + "\n" + base64.StdEncoding.EncodeToString(sealed) + "\n"
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".secrets-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func decrypt(data []byte, key []byte) (MapStore, error) {
+	header, body, _ := strings.Cut(string(data), "\n")
+	if header != encryptedHeader {
+		return nil, fmt.Errorf("not an encrypted secrets file")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(body))
+	if err != nil {
+		return nil, fmt.Errorf("corrupt secrets file: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("corrupt secrets file")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, []byte(encryptedHeader))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets file (wrong key?)")
+	}
+	store := MapStore{}
+	if err := json.Unmarshal(plain, &store); err != nil {
+		return nil, fmt.Errorf("corrupt secrets file: %w", err)
+	}
+	return store, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Names returns the secret names in a store, sorted
+func (s MapStore) Names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
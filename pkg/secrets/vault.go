@@ -0,0 +1,312 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secret values in redacted output
+const Redacted = "[REDACTED]"
+
+// minRedactLength keeps very short values from redacting unrelated text
+const minRedactLength = 4
+
+// Vault resolves secrets from a Store and remembers every value it handed out,
+// so those values can be redacted wherever the engine emits or persists data.
+type Vault struct {
+	store Store
+
+	mu       sync.RWMutex
+	values   map[string]string // secret name -> value
+	replacer *strings.Replacer
+}
+
+// NewVault creates a vault backed by store
+func NewVault(store Store) *Vault {
+	return &Vault{
+		store:  store,
+		values: make(map[string]string),
+	}
+}
+
+// Default resolves secrets from the environment
+var Default = NewVault(EnvStore{})
+
+// Lookup returns a secret and marks its value for redaction
+func (v *Vault) Lookup(name string) (string, error) {
+	value, err := v.store.Get(name)
+	if err != nil {
+		if err == ErrNotFound {
+			return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return "", err
+	}
+	v.remember(name, value)
+	return value, nil
+}
+
+// Get is Lookup for optional secrets: a missing secret yields ""
+func (v *Vault) Get(name string) string {
+	value, _ := v.Lookup(name)
+	return value
+}
+
+func (v *Vault) remember(name, value string) {
+	if len(value) < minRedactLength {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.values[name] == value {
+		return
+	}
+	v.values[name] = value
+
+	// Redact encoded forms too, e.g. a value echoed back in a query string.
+	// Longer values first, so a secret containing another is replaced whole.
+	seen := make(map[string]bool)
+	var vals []string
+	for _, val := range v.values {
+		for _, form := range []string{val, url.QueryEscape(val), url.PathEscape(val)} {
+			if !seen[form] {
+				seen[form] = true
+				vals = append(vals, form)
+			}
+		}
+	}
+	sort.Slice(vals, func(i, j int) bool { return len(vals[i]) > len(vals[j]) })
+	pairs := make([]string, 0, 2*len(vals))
+	for _, val := range vals {
+		pairs = append(pairs, val, Redacted)
+	}
+	v.replacer = strings.NewReplacer(pairs...)
+}
+
+// Redact replaces resolved secret values in s
+func (v *Vault) Redact(s string) string {
+	v.mu.RLock()
+	r := v.replacer
+	v.mu.RUnlock()
+	if r == nil {
+		return s
+	}
+	return r.Replace(s)
+}
+
+// RedactValue returns a copy of val with secret values replaced in every string it contains.
+// Maps and slices are copied; other values are returned unchanged.
+func (v *Vault) RedactValue(val interface{}) interface{} {
+	v.mu.RLock()
+	empty := v.replacer == nil
+	v.mu.RUnlock()
+	if empty {
+		return val
+	}
+	return v.redactValue(val)
+}
+
+func (v *Vault) redactValue(val interface{}) interface{} {
+	switch t := val.(type) {
+	case string:
+		return v.Redact(t)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			out[k] = v.redactValue(item)
+		}
+		return out
+	case map[string]string:
+		out := make(map[string]string, len(t))
+		for k, item := range t {
+			out[k] = v.Redact(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, item := range t {
+			out[i] = v.redactValue(item)
+		}
+		return out
+	case []string:
+		out := make([]string, len(t))
+		for i, item := range t {
+			out[i] = v.Redact(item)
+		}
+		return out
+	default:
+		return val
+	}
+}
+
+// Contains reports whether any string in val contains a resolved secret value
+func (v *Vault) Contains(val interface{}) bool {
+	v.mu.RLock()
+	r := v.replacer
+	v.mu.RUnlock()
+	if r == nil {
+		return false
+	}
+	switch t := val.(type) {
+	case string:
+		return r.Replace(t) != t
+	case map[string]interface{}:
+		for _, item := range t {
+			if v.Contains(item) {
+				return true
+			}
+		}
+	case map[string]string:
+		for _, item := range t {
+			if v.Contains(item) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range t {
+			if v.Contains(item) {
+				return true
+			}
+		}
+	case []string:
+		for _, item := range t {
+			if v.Contains(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RedactMap is RedactValue for the common map case
+func (v *Vault) RedactMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	return v.RedactValue(m).(map[string]interface{})
+}
+
+var refPattern = regexp.MustCompile(`\{\{\s*secrets\.([A-Za-z0-9_\-]+)\s*\}\}|\$\{([A-Za-z0-9_]+)\}`)
+
+// Expand replaces {{ secrets.NAME }} and ${NAME} references in s.
+// Used for configuration resolved outside a running workflow, such as tool provider auth.
+func (v *Vault) Expand(s string) (string, error) {
+	var firstErr error
+	out := refPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		m := refPattern.FindStringSubmatch(ref)
+		name := m[1]
+		if name == "" {
+			name = m[2]
+		}
+		value, err := v.Lookup(name)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return value
+	})
+	return out, firstErr
+}
+
+type vaultKey struct{}
+
+// WithVault attaches a vault to ctx
+func WithVault(ctx context.Context, v *Vault) context.Context {
+	return context.WithValue(ctx, vaultKey{}, v)
+}
+
+// FromContext returns the vault attached to ctx, or Default
+func FromContext(ctx context.Context) *Vault {
+	if ctx != nil {
+		if v, ok := ctx.Value(vaultKey{}).(*Vault); ok {
+			return v
+		}
+	}
+	return Default
+}
+
+// Lookup resolves a secret from the vault attached to ctx
+func Lookup(ctx context.Context, name string) (string, error) {
+	return FromContext(ctx).Lookup(name)
+}
+
+// Get resolves an optional secret from the vault attached to ctx; a missing secret yields ""
+func Get(ctx context.Context, name string) string {
+	return FromContext(ctx).Get(name)
+}
+
+// Writer redacts secret values from everything written through it. Output is passed on
+// line by line so a value split across writes is still caught; call Flush to emit a
+// trailing partial line.
+type Writer struct {
+	vault *Vault
+	out   io.Writer
+	mu    sync.Mutex
+	buf   []byte
+}
+
+// NewWriter wraps out with redaction
+func NewWriter(out io.Writer, v *Vault) *Writer {
+	return &Writer{vault: v, out: out}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	if i := bytes.LastIndexByte(w.buf, '\n'); i >= 0 {
+		lines := w.buf[:i+1]
+		if _, err := io.WriteString(w.out, w.vault.Redact(string(lines))); err != nil {
+			return 0, err
+		}
+		w.buf = append(w.buf[:0], w.buf[i+1:]...)
+	}
+	return len(p), nil
+}
+
+// Flush writes any buffered partial line
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(w.out, w.vault.Redact(string(w.buf)))
+	w.buf = w.buf[:0]
+	return err
+}
+
+// RedactStdout routes os.Stdout through a redacting pipe so fmt.Print output from nodes
+// is scrubbed. The returned function restores os.Stdout and waits for pending output.
+func RedactStdout(v *Vault) (restore func(), err error) {
+	orig := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	os.Stdout = w
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rw := NewWriter(orig, v)
+		io.Copy(rw, r)
+		rw.Flush()
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			os.Stdout = orig
+			w.Close()
+			<-done
+			r.Close()
+		})
+	}, nil
+}
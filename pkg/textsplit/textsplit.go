@@ -0,0 +1,265 @@
+// Package textsplit cuts long text into overlapping chunks sized for LLM prompts and
+// embedding, keeping each chunk's position in the source text.
+package textsplit
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultSeparators are tried in order: paragraphs, lines, sentences, words, characters
+var DefaultSeparators = []string{"\n\n", "\n", ". ", " ", ""}
+
+// Chunk is a piece of the source text. Start and End are byte offsets, so
+// text[Start:End] == Chunk.Text.
+type Chunk struct {
+	Index    int
+	Text     string
+	Start    int
+	End      int
+	Tokens   int      // Set when a tokenizer measures the chunks
+	Headings []string // Enclosing Markdown headings, outermost first
+}
+
+// Splitter splits text recursively: it cuts on the first separator that occurs, packs the
+// pieces into chunks of at most ChunkSize, and cuts pieces that are still too long on the
+// next separator. Consecutive chunks share up to ChunkOverlap of text.
+type Splitter struct {
+	ChunkSize    int
+	ChunkOverlap int
+	Separators   []string  // Defaults to DefaultSeparators
+	Tokenizer    Tokenizer // Measures size in tokens; nil measures characters
+}
+
+type span struct{ start, end int }
+
+// Validate checks the size settings
+func (s *Splitter) Validate() error {
+	if s.ChunkSize <= 0 {
+		return fmt.Errorf("chunk_size must be positive")
+	}
+	if s.ChunkOverlap < 0 || s.ChunkOverlap >= s.ChunkSize {
+		return fmt.Errorf("chunk_overlap must be at least 0 and less than chunk_size")
+	}
+	return nil
+}
+
+func (s *Splitter) length(text string) int {
+	if s.Tokenizer != nil {
+		return s.Tokenizer.Count(text)
+	}
+	return utf8.RuneCountInString(text)
+}
+
+func (s *Splitter) separators() []string {
+	if len(s.Separators) > 0 {
+		return s.Separators
+	}
+	return DefaultSeparators
+}
+
+// Split cuts text into chunks
+func (s *Splitter) Split(text string) ([]Chunk, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	spans := s.splitSpan(text, span{0, len(text)}, s.separators())
+	return s.chunks(text, spans, nil), nil
+}
+
+// SplitMarkdown cuts text at Markdown headings first, so no chunk spans two sections, and
+// records the heading path of each chunk. Sections longer than ChunkSize are split further.
+func (s *Splitter) SplitMarkdown(text string) ([]Chunk, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	var out []Chunk
+	for _, sec := range markdownSections(text) {
+		spans := []span{sec.span}
+		if s.length(text[sec.start:sec.end]) > s.ChunkSize {
+			spans = s.splitSpan(text, sec.span, s.separators())
+		}
+		out = append(out, s.chunks(text, spans, sec.headings)...)
+	}
+	for i := range out {
+		out[i].Index = i
+	}
+	return out, nil
+}
+
+// chunks trims the spans and turns them into chunks
+func (s *Splitter) chunks(text string, spans []span, headings []string) []Chunk {
+	var out []Chunk
+	last := span{-1, -1}
+	for _, sp := range spans {
+		sp = trimSpan(text, sp)
+		if sp.start >= sp.end || sp == last {
+			continue
+		}
+		last = sp
+		c := Chunk{Index: len(out), Text: text[sp.start:sp.end], Start: sp.start, End: sp.end, Headings: headings}
+		if s.Tokenizer != nil {
+			c.Tokens = s.Tokenizer.Count(c.Text)
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func (s *Splitter) splitSpan(text string, sp span, seps []string) []span {
+	sep, rest := seps[len(seps)-1], []string(nil)
+	for i, candidate := range seps {
+		if candidate == "" || strings.Contains(text[sp.start:sp.end], candidate) {
+			sep, rest = candidate, seps[i+1:]
+			break
+		}
+	}
+
+	var out, fitting []span
+	for _, piece := range cut(text, sp, sep) {
+		if s.length(text[piece.start:piece.end]) <= s.ChunkSize {
+			fitting = append(fitting, piece)
+			continue
+		}
+		out = append(out, s.merge(text, fitting)...)
+		fitting = nil
+		if len(rest) == 0 {
+			out = append(out, piece) // Nothing left to cut on
+		} else {
+			out = append(out, s.splitSpan(text, piece, rest)...)
+		}
+	}
+	return append(out, s.merge(text, fitting)...)
+}
+
+// cut splits a span after each occurrence of sep, so the pieces are contiguous
+func cut(text string, sp span, sep string) []span {
+	var pieces []span
+	if sep == "" {
+		for i := sp.start; i < sp.end; {
+			_, size := utf8.DecodeRuneInString(text[i:sp.end])
+			pieces = append(pieces, span{i, i + size})
+			i += size
+		}
+		return pieces
+	}
+	start := sp.start
+	for {
+		idx := strings.Index(text[start:sp.end], sep)
+		if idx < 0 {
+			break
+		}
+		end := start + idx + len(sep)
+		pieces = append(pieces, span{start, end})
+		start = end
+	}
+	if start < sp.end {
+		pieces = append(pieces, span{start, sp.end})
+	}
+	return pieces
+}
+
+// merge packs consecutive pieces into chunks of at most ChunkSize, starting each chunk
+// with the trailing pieces of the previous one that fit in ChunkOverlap
+func (s *Splitter) merge(text string, pieces []span) []span {
+	var (
+		out   []span
+		cur   []span
+		sizes []int
+		total int
+	)
+	for _, p := range pieces {
+		n := s.length(text[p.start:p.end])
+		if total+n > s.ChunkSize && len(cur) > 0 {
+			out = append(out, span{cur[0].start, cur[len(cur)-1].end})
+			for len(cur) > 0 && (total > s.ChunkOverlap || total+n > s.ChunkSize) {
+				total -= sizes[0]
+				cur, sizes = cur[1:], sizes[1:]
+			}
+		}
+		cur = append(cur, p)
+		sizes = append(sizes, n)
+		total += n
+	}
+	if len(cur) > 0 {
+		out = append(out, span{cur[0].start, cur[len(cur)-1].end})
+	}
+	return out
+}
+
+func trimSpan(text string, sp span) span {
+	for sp.start < sp.end {
+		r, size := utf8.DecodeRuneInString(text[sp.start:sp.end])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		sp.start += size
+	}
+	for sp.end > sp.start {
+		r, size := utf8.DecodeLastRuneInString(text[sp.start:sp.end])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		sp.end -= size
+	}
+	return sp
+}
+
+type section struct {
+	span
+	headings []string
+}
+
+// markdownSections cuts text at ATX headings outside fenced code blocks. The text before
+// the first heading is a section without headings.
+func markdownSections(text string) []section {
+	var (
+		sections []section
+		path     []string
+		levels   []int
+		start    int
+		inFence  bool
+		offset   int
+	)
+	current := []string(nil)
+	for _, line := range strings.SplitAfter(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		} else if level, title, ok := markdownHeading(trimmed); ok && !inFence {
+			if offset > start {
+				sections = append(sections, section{span{start, offset}, current})
+			}
+			for len(levels) > 0 && levels[len(levels)-1] >= level {
+				levels, path = levels[:len(levels)-1], path[:len(path)-1]
+			}
+			levels = append(levels, level)
+			path = append(path, title)
+			current = append([]string(nil), path...)
+			start = offset
+		}
+		offset += len(line)
+	}
+	if len(text) > start {
+		sections = append(sections, section{span{start, len(text)}, current})
+	}
+	return sections
+}
+
+// markdownHeading parses an ATX heading line ("## Title")
+func markdownHeading(line string) (int, string, bool) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(line) && line[level] != ' ' && line[level] != '\t') {
+		return 0, "", false
+	}
+	title := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(line[level:]), "#"))
+	if title == "" {
+		return 0, "", false
+	}
+	return level, title, true
+}
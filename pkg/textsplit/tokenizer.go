@@ -0,0 +1,84 @@
+package textsplit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer counts the tokens in a text. Chunk sizes in token mode are measured with it,
+// so it should match (or overestimate) the tokenizer of the model the chunks are sent to.
+type Tokenizer interface {
+	Count(text string) int
+}
+
+// TokenizerFunc adapts a function to the Tokenizer interface
+type TokenizerFunc func(text string) int
+
+func (f TokenizerFunc) Count(text string) int {
+	return f(text)
+}
+
+var (
+	tokenizersMu sync.RWMutex
+	tokenizers   = map[string]Tokenizer{
+		"approx":     TokenizerFunc(approxTokens),
+		"whitespace": TokenizerFunc(func(text string) int { return len(strings.Fields(text)) }),
+		"chars":      TokenizerFunc(utf8.RuneCountInString),
+	}
+)
+
+// RegisterTokenizer makes a tokenizer available to TextSplitter nodes by name
+func RegisterTokenizer(name string, t Tokenizer) {
+	tokenizersMu.Lock()
+	defer tokenizersMu.Unlock()
+	tokenizers[name] = t
+}
+
+// GetTokenizer returns a registered tokenizer
+func GetTokenizer(name string) (Tokenizer, error) {
+	tokenizersMu.RLock()
+	defer tokenizersMu.RUnlock()
+	t, ok := tokenizers[name]
+	if !ok {
+		names := make([]string, 0, len(tokenizers))
+		for n := range tokenizers {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown tokenizer '%s' (available: %s)", name, strings.Join(names, ", "))
+	}
+	return t, nil
+}
+
+// approxTokens estimates BPE token counts without a vocabulary: a word costs one token per
+// four characters (at least one), every other symbol and every CJK character costs one.
+// It slightly overestimates English text, which keeps chunks within model limits.
+func approxTokens(text string) int {
+	tokens, word := 0, 0
+	flush := func() {
+		if word > 0 {
+			tokens += (word + 3) / 4
+			word = 0
+		}
+	}
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			flush()
+			tokens++
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word++
+		default:
+			flush()
+			tokens++
+		}
+	}
+	flush()
+	return tokens
+}
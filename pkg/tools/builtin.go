@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+)
+
+func init() {
+	// Provider IDs match the ones used by existing workflows
+	Default.Register("math", &CalculatorTool{})
+	Default.Register("google", &SearchTool{ToolName: "google_search"})
+}
+
+// intArg reads an integer argument; JSON-decoded arguments arrive as float64
+func intArg(args map[string]interface{}, key string) (int, bool) {
+	switch v := args[key].(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	default:
+		return 0, false
+	}
+}
+
+// CalculatorTool evaluates arithmetic expressions with the safe Calculator parser
+type CalculatorTool struct{}
+
+func (t *CalculatorTool) Name() string { return "calculator" }
+
+func (t *CalculatorTool) Description() string {
+	return "Evaluates an arithmetic expression (+ - * / % ^, parentheses, math functions and constants)."
+}
+
+func (t *CalculatorTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"expression": map[string]interface{}{
+				"type":        "string",
+				"description": "Expression to evaluate, e.g. \"sqrt(2) * (3 + 4)\"",
+			},
+			"precise": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Use exact decimal arithmetic",
+			},
+			"precision": map[string]interface{}{
+				"type":        "integer",
+				"description": "Decimal places for non-integer results in precise mode",
+			},
+		},
+		"required": []string{"expression"},
+	}
+}
+
+func (t *CalculatorTool) Invoke(ctx context.Context, args map[string]interface{}) (map[string]interface{}, error) {
+	expression, _ := args["expression"].(string)
+
+	// The expression may come from an LLM, so it is parsed as arithmetic rather than run as code
+	precise, _ := args["precise"].(bool)
+	calc := &Calculator{Precise: precise}
+	if p, ok := intArg(args, "precision"); ok {
+		calc.Precision = p
+	}
+	result, value, err := calc.Evaluate(expression)
+	if err != nil {
+		return nil, fmt.Errorf("calculation failed: %w", err)
+	}
+
+	return map[string]interface{}{
+		"text":   result,
+		"result": value,
+	}, nil
+}
@@ -0,0 +1,597 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Calculator evaluates arithmetic expressions without running any script.
+// Supported: numbers, + - * / % ^ (or **), parentheses, constants (pi, e, tau, phi)
+// and common math functions. In precise mode + - * / % and integer powers are exact
+// decimals; functions and fractional powers fall back to float64. Exact values are
+// bounded (see maxExactBits), so an expression like 9^9^9^9 fails instead of
+// exhausting memory.
+type Calculator struct {
+	Precise   bool
+	Precision int // Decimal places when formatting precise results
+}
+
+// Bounds on exact arithmetic in precise mode
+const (
+	maxExactBits     = 1 << 16 // Numerator plus denominator bits of any exact value (about 19,700 digits)
+	maxExactExponent = 1024    // Larger integer powers are computed in float64
+	maxExactLiteral  = 19000   // Largest decimal exponent in a number literal, e.g. 1e19000
+)
+
+// maxCalcDepth bounds nested parentheses, calls and unary signs, so a pathological
+// expression fails instead of overflowing the stack
+const maxCalcDepth = 256
+
+// CalcError reports an invalid expression together with the offending position
+type CalcError struct {
+	Pos int // Character offset in the expression
+	Msg string
+}
+
+func (e *CalcError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos+1)
+}
+
+// calcValue holds a float64, or an exact rational in precise mode
+type calcValue struct {
+	f float64
+	r *big.Rat
+}
+
+func (v calcValue) float() float64 {
+	if v.r != nil {
+		f, _ := v.r.Float64()
+		return f
+	}
+	return v.f
+}
+
+type calcTokenKind int
+
+const (
+	tokNumber calcTokenKind = iota
+	tokIdent
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+	tokEOF
+)
+
+type calcToken struct {
+	kind calcTokenKind
+	text string
+	pos  int
+}
+
+func tokenize(expr string) ([]calcToken, error) {
+	src := []rune(expr)
+	var tokens []calcToken
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case isDigit(c) || c == '.':
+			start := i
+			for i < len(src) && (isDigit(src[i]) || src[i] == '.') {
+				i++
+			}
+			// Exponent part, e.g. 1.5e-3
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				j := i + 1
+				if j < len(src) && (src[j] == '+' || src[j] == '-') {
+					j++
+				}
+				if j < len(src) && isDigit(src[j]) {
+					for j < len(src) && isDigit(src[j]) {
+						j++
+					}
+					i = j
+				}
+			}
+			tokens = append(tokens, calcToken{tokNumber, string(src[start:i]), start})
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(src) && (isDigit(src[i]) || unicode.IsLetter(src[i]) || src[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, calcToken{tokIdent, strings.ToLower(string(src[start:i])), start})
+		case c == '*' && i+1 < len(src) && src[i+1] == '*':
+			tokens = append(tokens, calcToken{tokOp, "^", i})
+			i += 2
+		case strings.ContainsRune("+-*/%^", c):
+			tokens = append(tokens, calcToken{tokOp, string(c), i})
+			i++
+		case c == '(':
+			tokens = append(tokens, calcToken{tokLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, calcToken{tokRParen, ")", i})
+			i++
+		case c == ',':
+			tokens = append(tokens, calcToken{tokComma, ",", i})
+			i++
+		default:
+			return nil, &CalcError{Pos: i, Msg: fmt.Sprintf("unexpected character %q", c)}
+		}
+	}
+	tokens = append(tokens, calcToken{tokEOF, "", len(src)})
+	return tokens, nil
+}
+
+// isDigit accepts ASCII digits only; other Unicode digits are not valid in numbers
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+// calcParser is a recursive-descent parser that evaluates while parsing:
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/" | "%") unary }
+//	unary   = ("+" | "-") unary | power
+//	power   = primary [ "^" unary ]        (right-associative, binds tighter than unary minus)
+//	primary = number | constant | name "(" [ expr { "," expr } ] ")" | "(" expr ")"
+type calcParser struct {
+	calc   *Calculator
+	tokens []calcToken
+	pos    int
+	depth  int
+}
+
+// Evaluate parses and evaluates expr, returning the formatted result and its float value
+func (c *Calculator) Evaluate(expr string) (string, float64, error) {
+	if strings.TrimSpace(expr) == "" {
+		return "", 0, &CalcError{Pos: 0, Msg: "empty expression"}
+	}
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return "", 0, err
+	}
+	p := &calcParser{calc: c, tokens: tokens}
+	v, err := p.expr()
+	if err != nil {
+		return "", 0, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return "", 0, &CalcError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+	}
+	// Exact values beyond float64 range (e.g. 2^1024) are rejected too: the float result
+	// would be +Inf, which cannot be encoded as JSON
+	f := v.float()
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", 0, &CalcError{Pos: 0, Msg: "result is not a finite number"}
+	}
+	return c.format(v), f, nil
+}
+
+func (c *Calculator) format(v calcValue) string {
+	if v.r != nil {
+		if v.r.IsInt() {
+			return v.r.Num().String()
+		}
+		precision := c.Precision
+		if precision <= 0 {
+			precision = 20
+		}
+		s := v.r.FloatString(precision)
+		s = strings.TrimRight(s, "0")
+		return strings.TrimSuffix(s, ".")
+	}
+	if math.Abs(v.f) < 1e21 {
+		return strconv.FormatFloat(v.f, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(v.f, 'g', -1, 64)
+}
+
+func (p *calcParser) peek() calcToken {
+	return p.tokens[p.pos]
+}
+
+func (p *calcParser) next() calcToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *calcParser) expr() (calcValue, error) {
+	left, err := p.term()
+	if err != nil {
+		return left, err
+	}
+	for {
+		tok := p.peek()
+		if tok.kind != tokOp || (tok.text != "+" && tok.text != "-") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.term()
+		if err != nil {
+			return left, err
+		}
+		left, err = p.binary(tok, left, right)
+		if err != nil {
+			return left, err
+		}
+	}
+}
+
+func (p *calcParser) term() (calcValue, error) {
+	left, err := p.unary()
+	if err != nil {
+		return left, err
+	}
+	for {
+		tok := p.peek()
+		if tok.kind != tokOp || (tok.text != "*" && tok.text != "/" && tok.text != "%") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return left, err
+		}
+		left, err = p.binary(tok, left, right)
+		if err != nil {
+			return left, err
+		}
+	}
+}
+
+func (p *calcParser) unary() (calcValue, error) {
+	tok := p.peek()
+	// Every level of nesting passes through unary
+	if p.depth++; p.depth > maxCalcDepth {
+		return calcValue{}, &CalcError{Pos: tok.pos, Msg: "expression is nested too deeply"}
+	}
+	defer func() { p.depth-- }()
+	if tok.kind == tokOp && (tok.text == "-" || tok.text == "+") {
+		p.next()
+		v, err := p.unary()
+		if err != nil || tok.text == "+" {
+			return v, err
+		}
+		if v.r != nil {
+			return calcValue{r: new(big.Rat).Neg(v.r)}, nil
+		}
+		return calcValue{f: -v.f}, nil
+	}
+	return p.power()
+}
+
+func (p *calcParser) power() (calcValue, error) {
+	base, err := p.primary()
+	if err != nil {
+		return base, err
+	}
+	tok := p.peek()
+	if tok.kind != tokOp || tok.text != "^" {
+		return base, nil
+	}
+	p.next()
+	exp, err := p.unary()
+	if err != nil {
+		return base, err
+	}
+	return p.binary(tok, base, exp)
+}
+
+func (p *calcParser) primary() (calcValue, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		return p.number(tok)
+	case tokLParen:
+		v, err := p.expr()
+		if err != nil {
+			return v, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return v, &CalcError{Pos: closing.pos, Msg: "expected ')'"}
+		}
+		return v, nil
+	case tokIdent:
+		if p.peek().kind == tokLParen {
+			return p.call(tok)
+		}
+		c, ok := calcConstants[tok.text]
+		if !ok {
+			return calcValue{}, &CalcError{Pos: tok.pos, Msg: fmt.Sprintf("unknown constant %q", tok.text)}
+		}
+		return p.fromFloat(c), nil
+	case tokEOF:
+		return calcValue{}, &CalcError{Pos: tok.pos, Msg: "unexpected end of expression"}
+	default:
+		return calcValue{}, &CalcError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+	}
+}
+
+func (p *calcParser) number(tok calcToken) (calcValue, error) {
+	if p.calc.Precise {
+		// big.Rat expands the exponent, so 1e999999999 must be rejected before parsing
+		if e := strings.IndexAny(tok.text, "eE"); e >= 0 {
+			if exp, err := strconv.Atoi(tok.text[e+1:]); err != nil || exp > maxExactLiteral || exp < -maxExactLiteral {
+				return calcValue{}, &CalcError{Pos: tok.pos, Msg: fmt.Sprintf("number %q is too large", tok.text)}
+			}
+		}
+		r, ok := new(big.Rat).SetString(tok.text)
+		if !ok {
+			return calcValue{}, &CalcError{Pos: tok.pos, Msg: fmt.Sprintf("invalid number %q", tok.text)}
+		}
+		return p.exact(tok, r)
+	}
+	f, err := strconv.ParseFloat(tok.text, 64)
+	if err != nil {
+		return calcValue{}, &CalcError{Pos: tok.pos, Msg: fmt.Sprintf("invalid number %q", tok.text)}
+	}
+	return calcValue{f: f}, nil
+}
+
+func (p *calcParser) fromFloat(f float64) calcValue {
+	if p.calc.Precise && !math.IsNaN(f) && !math.IsInf(f, 0) {
+		// Go through the shortest decimal form so round(2.345, 2) stays 2.35, not 2.3500000000000000888
+		if r, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64)); ok {
+			return calcValue{r: r}
+		}
+	}
+	return calcValue{f: f}
+}
+
+func (p *calcParser) call(name calcToken) (calcValue, error) {
+	p.next() // "("
+	var args []calcValue
+	if p.peek().kind != tokRParen {
+		for {
+			v, err := p.expr()
+			if err != nil {
+				return v, err
+			}
+			args = append(args, v)
+			if p.peek().kind != tokComma {
+				break
+			}
+			p.next()
+		}
+	}
+	if closing := p.next(); closing.kind != tokRParen {
+		return calcValue{}, &CalcError{Pos: closing.pos, Msg: fmt.Sprintf("expected ')' to close %s(", name.text)}
+	}
+
+	fn, ok := calcFunctions[name.text]
+	if !ok {
+		return calcValue{}, &CalcError{Pos: name.pos, Msg: fmt.Sprintf("unknown function %q", name.text)}
+	}
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return calcValue{}, &CalcError{Pos: name.pos, Msg: fmt.Sprintf("%s() takes %s, got %d", name.text, fn.arity(), len(args))}
+	}
+
+	floats := make([]float64, len(args))
+	for i, a := range args {
+		floats[i] = a.float()
+	}
+	result, err := fn.eval(floats)
+	if err != nil {
+		return calcValue{}, &CalcError{Pos: name.pos, Msg: fmt.Sprintf("%s(): %v", name.text, err)}
+	}
+	return p.fromFloat(result), nil
+}
+
+func (p *calcParser) binary(op calcToken, a, b calcValue) (calcValue, error) {
+	if a.r != nil && b.r != nil {
+		return p.binaryExact(op, a.r, b.r)
+	}
+	x, y := a.float(), b.float()
+	switch op.text {
+	case "+":
+		return calcValue{f: x + y}, nil
+	case "-":
+		return calcValue{f: x - y}, nil
+	case "*":
+		return calcValue{f: x * y}, nil
+	case "/":
+		if y == 0 {
+			return calcValue{}, &CalcError{Pos: op.pos, Msg: "division by zero"}
+		}
+		return calcValue{f: x / y}, nil
+	case "%":
+		if y == 0 {
+			return calcValue{}, &CalcError{Pos: op.pos, Msg: "modulo by zero"}
+		}
+		return calcValue{f: math.Mod(x, y)}, nil
+	default: // "^"
+		r := math.Pow(x, y)
+		if math.IsNaN(r) {
+			return calcValue{}, &CalcError{Pos: op.pos, Msg: "power is not a real number"}
+		}
+		return calcValue{f: r}, nil
+	}
+}
+
+func (p *calcParser) binaryExact(op calcToken, x, y *big.Rat) (calcValue, error) {
+	switch op.text {
+	case "+":
+		return p.exact(op, new(big.Rat).Add(x, y))
+	case "-":
+		return p.exact(op, new(big.Rat).Sub(x, y))
+	case "*":
+		return p.exact(op, new(big.Rat).Mul(x, y))
+	case "/":
+		if y.Sign() == 0 {
+			return calcValue{}, &CalcError{Pos: op.pos, Msg: "division by zero"}
+		}
+		return p.exact(op, new(big.Rat).Quo(x, y))
+	case "%":
+		if y.Sign() == 0 {
+			return calcValue{}, &CalcError{Pos: op.pos, Msg: "modulo by zero"}
+		}
+		// x - y*trunc(x/y), matching the sign convention of math.Mod
+		q := new(big.Rat).Quo(x, y)
+		t := new(big.Int).Quo(q.Num(), q.Denom())
+		return p.exact(op, new(big.Rat).Sub(x, new(big.Rat).Mul(y, new(big.Rat).SetInt(t))))
+	default: // "^"
+		if y.IsInt() && y.Num().IsInt64() && abs64(y.Num().Int64()) <= maxExactExponent {
+			n := y.Num().Int64()
+			if n < 0 && x.Sign() == 0 {
+				return calcValue{}, &CalcError{Pos: op.pos, Msg: "division by zero"}
+			}
+			// Check the size before computing it: the result has about n times the bits of x
+			if int64(x.Num().BitLen()+x.Denom().BitLen()-1)*abs64(n) > maxExactBits {
+				return calcValue{}, &CalcError{Pos: op.pos, Msg: "result is too large"}
+			}
+			exp := big.NewInt(abs64(n))
+			result := new(big.Rat).SetFrac(new(big.Int).Exp(x.Num(), exp, nil), new(big.Int).Exp(x.Denom(), exp, nil))
+			if n < 0 {
+				result.Inv(result)
+			}
+			return p.exact(op, result)
+		}
+		return p.binary(op, calcValue{f: ratFloat(x)}, calcValue{f: ratFloat(y)})
+	}
+}
+
+// exact wraps an exact result, rejecting values beyond maxExactBits
+func (p *calcParser) exact(tok calcToken, r *big.Rat) (calcValue, error) {
+	if r.Num().BitLen()+r.Denom().BitLen() > maxExactBits {
+		return calcValue{}, &CalcError{Pos: tok.pos, Msg: "result is too large"}
+	}
+	return calcValue{r: r}, nil
+}
+
+func ratFloat(r *big.Rat) float64 {
+	f, _ := r.Float64()
+	return f
+}
+
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+var calcConstants = map[string]float64{
+	"pi":  math.Pi,
+	"e":   math.E,
+	"tau": 2 * math.Pi,
+	"phi": math.Phi,
+}
+
+type calcFunc struct {
+	minArgs, maxArgs int // maxArgs < 0 means variadic
+	eval             func(args []float64) (float64, error)
+}
+
+func (f calcFunc) arity() string {
+	switch {
+	case f.maxArgs < 0:
+		return fmt.Sprintf("at least %d argument(s)", f.minArgs)
+	case f.minArgs == f.maxArgs:
+		return fmt.Sprintf("%d argument(s)", f.minArgs)
+	default:
+		return fmt.Sprintf("%d to %d arguments", f.minArgs, f.maxArgs)
+	}
+}
+
+func unaryFunc(fn func(float64) float64) calcFunc {
+	return calcFunc{1, 1, func(a []float64) (float64, error) { return fn(a[0]), nil }}
+}
+
+func domainFunc(fn func(float64) float64, valid func(float64) bool, msg string) calcFunc {
+	return calcFunc{1, 1, func(a []float64) (float64, error) {
+		if !valid(a[0]) {
+			return 0, fmt.Errorf("%s", msg)
+		}
+		return fn(a[0]), nil
+	}}
+}
+
+var calcFunctions = map[string]calcFunc{
+	"abs":   unaryFunc(math.Abs),
+	"ceil":  unaryFunc(math.Ceil),
+	"floor": unaryFunc(math.Floor),
+	"trunc": unaryFunc(math.Trunc),
+	"sign": unaryFunc(func(x float64) float64 {
+		switch {
+		case x > 0:
+			return 1
+		case x < 0:
+			return -1
+		}
+		return 0
+	}),
+	"sqrt":  domainFunc(math.Sqrt, func(x float64) bool { return x >= 0 }, "argument must not be negative"),
+	"cbrt":  unaryFunc(math.Cbrt),
+	"exp":   unaryFunc(math.Exp),
+	"ln":    domainFunc(math.Log, func(x float64) bool { return x > 0 }, "argument must be positive"),
+	"log2":  domainFunc(math.Log2, func(x float64) bool { return x > 0 }, "argument must be positive"),
+	"log10": domainFunc(math.Log10, func(x float64) bool { return x > 0 }, "argument must be positive"),
+	"log": {1, 2, func(a []float64) (float64, error) {
+		// log(x) is base 10, log(x, base) uses the given base
+		if a[0] <= 0 {
+			return 0, fmt.Errorf("argument must be positive")
+		}
+		if len(a) == 1 {
+			return math.Log10(a[0]), nil
+		}
+		if a[1] <= 0 || a[1] == 1 {
+			return 0, fmt.Errorf("base must be positive and not 1")
+		}
+		return math.Log(a[0]) / math.Log(a[1]), nil
+	}},
+	"sin":   unaryFunc(math.Sin),
+	"cos":   unaryFunc(math.Cos),
+	"tan":   unaryFunc(math.Tan),
+	"asin":  domainFunc(math.Asin, func(x float64) bool { return x >= -1 && x <= 1 }, "argument must be in [-1, 1]"),
+	"acos":  domainFunc(math.Acos, func(x float64) bool { return x >= -1 && x <= 1 }, "argument must be in [-1, 1]"),
+	"atan":  unaryFunc(math.Atan),
+	"sinh":  unaryFunc(math.Sinh),
+	"cosh":  unaryFunc(math.Cosh),
+	"tanh":  unaryFunc(math.Tanh),
+	"atan2": {2, 2, func(a []float64) (float64, error) { return math.Atan2(a[0], a[1]), nil }},
+	"hypot": {2, 2, func(a []float64) (float64, error) { return math.Hypot(a[0], a[1]), nil }},
+	"pow":   {2, 2, func(a []float64) (float64, error) { return math.Pow(a[0], a[1]), nil }},
+	"round": {1, 2, func(a []float64) (float64, error) {
+		if len(a) == 1 {
+			return math.Round(a[0]), nil
+		}
+		scale := math.Pow(10, math.Trunc(a[1]))
+		return math.Round(a[0]*scale) / scale, nil
+	}},
+	"min": {1, -1, func(a []float64) (float64, error) {
+		m := a[0]
+		for _, x := range a[1:] {
+			m = math.Min(m, x)
+		}
+		return m, nil
+	}},
+	"max": {1, -1, func(a []float64) (float64, error) {
+		m := a[0]
+		for _, x := range a[1:] {
+			m = math.Max(m, x)
+		}
+		return m, nil
+	}},
+	"factorial": {1, 1, func(a []float64) (float64, error) {
+		n := a[0]
+		if n < 0 || n != math.Trunc(n) || n > 170 {
+			return 0, fmt.Errorf("argument must be an integer in [0, 170]")
+		}
+		result := 1.0
+		for i := 2.0; i <= n; i++ {
+			result *= i
+		}
+		return result, nil
+	}},
+}
@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCalculator(t *testing.T) {
+	nested := func(depth int) string {
+		return strings.Repeat("(", depth) + "1" + strings.Repeat(")", depth)
+	}
+	tests := []struct {
+		expr    string
+		precise bool
+		want    string // Formatted result, or the error message when err is set
+		err     bool
+	}{
+		// Precedence and associativity
+		{expr: "1 + 2 * 3", want: "7"},
+		{expr: "(1 + 2) * 3", want: "9"},
+		{expr: "10 - 4 - 3", want: "3"},
+		{expr: "2 * 3 % 4", want: "2"},
+		{expr: "-2^2", want: "-4"},
+		{expr: "(-2)^2", want: "4"},
+		{expr: "2^3^2", want: "512"},
+		{expr: "2**10", want: "1024"},
+		{expr: "2^-1", want: "0.5"},
+		{expr: "--3", want: "3"},
+
+		// Modulo keeps the sign of the dividend, in both modes
+		{expr: "10 % 3", want: "1"},
+		{expr: "-7 % 3", want: "-1"},
+		{expr: "-7 % 3", precise: true, want: "-1"},
+		{expr: "7.5 % 2", precise: true, want: "1.5"},
+
+		// Functions and constants
+		{expr: "sqrt(16) + abs(-3)", want: "7"},
+		{expr: "max(1, 5, 3) - min(4, 2)", want: "3"},
+		{expr: "log(8, 2)", want: "3"},
+		{expr: "log(1000)", want: "3"},
+		{expr: "factorial(5)", want: "120"},
+		{expr: "PI", want: "3.141592653589793"},
+		{expr: "round(2.345, 2)", precise: true, want: "2.35"},
+
+		// Exact decimals
+		{expr: "0.1 + 0.2", want: "0.30000000000000004"},
+		{expr: "0.1 + 0.2", precise: true, want: "0.3"},
+		{expr: "2^100", precise: true, want: "1267650600228229401496703205376"},
+		{expr: "1/4", precise: true, want: "0.25"},
+
+		// Errors, with 1-based character positions
+		{expr: "  ", err: true, want: "empty expression at position 1"},
+		{expr: "1 +", err: true, want: "unexpected end of expression at position 4"},
+		{expr: "1 2", err: true, want: `unexpected "2" at position 3`},
+		{expr: "2 * (3 + 4", err: true, want: "expected ')' at position 11"},
+		{expr: "1 / 0", err: true, want: "division by zero at position 3"},
+		{expr: "1 % 0", precise: true, err: true, want: "modulo by zero at position 3"},
+		{expr: "1 $ 2", err: true, want: "unexpected character '$' at position 3"},
+		{expr: "é + 1 $", err: true, want: "unexpected character '$' at position 7"},
+		{expr: "1 + ١", err: true, want: "unexpected character '١' at position 5"},
+		{expr: "x + 1", err: true, want: `unknown constant "x" at position 1`},
+		{expr: "1 + foo(2)", err: true, want: `unknown function "foo" at position 5`},
+		{expr: "sqrt(-1)", err: true, want: "sqrt(): argument must not be negative at position 1"},
+		{expr: "atan2(1)", err: true, want: "atan2() takes 2 argument(s), got 1 at position 1"},
+		{expr: "(-8)^0.5", err: true, want: "power is not a real number at position 5"},
+
+		// Deep nesting is bounded
+		{expr: nested(100), want: "1"},
+		{expr: nested(10000), err: true, want: "expression is nested too deeply at position 257"},
+		{expr: strings.Repeat("-", 10000) + "1", err: true, want: "expression is nested too deeply at position 257"},
+
+		// Overflow
+		{expr: "2^1024", err: true, want: "result is not a finite number at position 1"},
+		{expr: "2^1024", precise: true, err: true, want: "result is not a finite number at position 1"},
+		{expr: "1e400", precise: true, err: true, want: "result is not a finite number at position 1"},
+		{expr: "1e999999999", precise: true, err: true, want: `number "1e999999999" is too large at position 1`},
+		{expr: "(10^1000)^20", precise: true, err: true, want: "result is too large at position 10"},
+		{expr: "9^9^9^9", precise: true, err: true, want: "result is not a finite number at position 1"},
+	}
+	for _, tt := range tests {
+		calc := &Calculator{Precise: tt.precise}
+		got, _, err := calc.Evaluate(tt.expr)
+		name := tt.expr
+		if len(name) > 20 {
+			name = name[:20] + "..."
+		}
+		switch {
+		case tt.err && err == nil:
+			t.Errorf("%s (precise=%v) = %s, want error %q", name, tt.precise, got, tt.want)
+		case tt.err && err.Error() != tt.want:
+			t.Errorf("%s (precise=%v) error = %q, want %q", name, tt.precise, err, tt.want)
+		case !tt.err && err != nil:
+			t.Errorf("%s (precise=%v) failed: %v", name, tt.precise, err)
+		case !tt.err && got != tt.want:
+			t.Errorf("%s (precise=%v) = %s, want %s", name, tt.precise, got, tt.want)
+		}
+	}
+}
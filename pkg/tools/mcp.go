@@ -0,0 +1,559 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"dify-vnext-go/pkg/egress"
+)
+
+// MCPProtocolVersion is the Model Context Protocol revision requested during initialization
+const MCPProtocolVersion = "2025-06-18"
+
+// mcpInitTimeout bounds the handshake and tool listing at startup
+const mcpInitTimeout = 30 * time.Second
+
+type rpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      *int64      `json:"id,omitempty"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("MCP error %d: %s", e.Code, e.Message)
+}
+
+// rpcMessage is any message received from the server: a response, a notification or a request
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// mcpTransport exchanges JSON-RPC messages with an MCP server
+type mcpTransport interface {
+	// call sends a request and waits for the response with the same ID
+	call(ctx context.Context, req *rpcRequest) (*rpcMessage, error)
+	notify(ctx context.Context, method string, params interface{}) error
+	Close() error
+}
+
+// MCPClient talks to a single MCP server
+type MCPClient struct {
+	transport  mcpTransport
+	nextID     int64
+	ServerName string
+}
+
+// NewMCPStdioClient starts command and speaks MCP over its stdin/stdout.
+// The server's stderr is forwarded to ours.
+func NewMCPStdioClient(command []string, env map[string]string) (*MCPClient, error) {
+	if len(command) == 0 {
+		return nil, fmt.Errorf("MCP command must not be empty")
+	}
+	t, err := newStdioTransport(command, env)
+	if err != nil {
+		return nil, err
+	}
+	return &MCPClient{transport: t}, nil
+}
+
+// NewMCPHTTPClient speaks MCP over the streamable HTTP transport at url.
+// Requests use the egress client of the context they are made under; the session is
+// ended with the client of the request that opened it.
+func NewMCPHTTPClient(url string, headers map[string]string) *MCPClient {
+	return &MCPClient{transport: &httpTransport{
+		url:     url,
+		headers: headers,
+	}}
+}
+
+// Initialize performs the MCP handshake
+func (c *MCPClient) Initialize(ctx context.Context) error {
+	var result struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ServerInfo      struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"serverInfo"`
+	}
+	err := c.request(ctx, "initialize", map[string]interface{}{
+		"protocolVersion": MCPProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo": map[string]interface{}{
+			"name":    "dify-vnext-go",
+			"version": "0.1.0",
+		},
+	}, &result)
+	if err != nil {
+		return fmt.Errorf("MCP initialize failed: %w", err)
+	}
+	c.ServerName = result.ServerInfo.Name
+	if ht, ok := c.transport.(*httpTransport); ok {
+		ht.setProtocolVersion(result.ProtocolVersion)
+	}
+	return c.transport.notify(ctx, "notifications/initialized", nil)
+}
+
+// MCPToolInfo is a tool as described by tools/list
+type MCPToolInfo struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+}
+
+// ListTools returns every tool the server offers, following pagination cursors
+func (c *MCPClient) ListTools(ctx context.Context) ([]MCPToolInfo, error) {
+	var all []MCPToolInfo
+	cursor := ""
+	for {
+		params := map[string]interface{}{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page struct {
+			Tools      []MCPToolInfo `json:"tools"`
+			NextCursor string        `json:"nextCursor"`
+		}
+		if err := c.request(ctx, "tools/list", params, &page); err != nil {
+			return nil, fmt.Errorf("MCP tools/list failed: %w", err)
+		}
+		all = append(all, page.Tools...)
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			return all, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// MCPCallResult is the result of tools/call
+type MCPCallResult struct {
+	Content           []map[string]interface{} `json:"content"`
+	StructuredContent interface{}              `json:"structuredContent,omitempty"`
+	IsError           bool                     `json:"isError"`
+}
+
+// Text joins the text content blocks of the result
+func (r *MCPCallResult) Text() string {
+	var parts []string
+	for _, block := range r.Content {
+		if block["type"] == "text" {
+			if text, ok := block["text"].(string); ok {
+				parts = append(parts, text)
+			}
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// CallTool invokes a tool on the server
+func (c *MCPClient) CallTool(ctx context.Context, name string, args map[string]interface{}) (*MCPCallResult, error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	var result MCPCallResult
+	err := c.request(ctx, "tools/call", map[string]interface{}{
+		"name":      name,
+		"arguments": args,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Close shuts down the transport, stopping a stdio server process
+func (c *MCPClient) Close() error {
+	return c.transport.Close()
+}
+
+func (c *MCPClient) request(ctx context.Context, method string, params interface{}, out interface{}) error {
+	id := atomic.AddInt64(&c.nextID, 1)
+	resp, err := c.transport.call(ctx, &rpcRequest{JSONRPC: "2.0", ID: &id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, out)
+}
+
+// RegisterMCP initializes client and registers every tool it lists under provider.
+// It returns the number of tools registered.
+func RegisterMCP(ctx context.Context, reg *Registry, provider string, client *MCPClient) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, mcpInitTimeout)
+	defer cancel()
+
+	if err := client.Initialize(ctx); err != nil {
+		return 0, err
+	}
+	infos, err := client.ListTools(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, info := range infos {
+		if err := reg.Register(provider, &MCPTool{info: info, client: client}); err != nil {
+			return 0, err
+		}
+	}
+	return len(infos), nil
+}
+
+// MCPTool exposes a tool served by an MCP server
+type MCPTool struct {
+	info   MCPToolInfo
+	client *MCPClient
+}
+
+func (t *MCPTool) Name() string { return t.info.Name }
+
+func (t *MCPTool) Description() string { return t.info.Description }
+
+func (t *MCPTool) Parameters() map[string]interface{} {
+	if t.info.InputSchema == nil {
+		return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	return t.info.InputSchema
+}
+
+func (t *MCPTool) Invoke(ctx context.Context, args map[string]interface{}) (map[string]interface{}, error) {
+	result, err := t.client.CallTool(ctx, t.info.Name, args)
+	if err != nil {
+		return nil, fmt.Errorf("tool %s: %w", t.info.Name, err)
+	}
+	text := result.Text()
+	if result.IsError {
+		return nil, fmt.Errorf("tool %s failed: %s", t.info.Name, text)
+	}
+
+	content := make([]interface{}, len(result.Content))
+	for i, block := range result.Content {
+		content[i] = block
+	}
+	outputs := map[string]interface{}{
+		"text":    text,
+		"content": content,
+	}
+	if result.StructuredContent != nil {
+		outputs["structured"] = result.StructuredContent
+	}
+	return outputs, nil
+}
+
+// stdioTransport exchanges newline-delimited JSON-RPC messages with a child process
+type stdioTransport struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	pending map[string]chan *rpcMessage
+	done    chan struct{}
+	readErr error
+}
+
+func newStdioTransport(command []string, env map[string]string) (*stdioTransport, error) {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start MCP server: %w", err)
+	}
+
+	t := &stdioTransport{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[string]chan *rpcMessage),
+		done:    make(chan struct{}),
+	}
+	go t.readLoop(stdout)
+	return t, nil
+}
+
+func (t *stdioTransport) readLoop(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var msg rpcMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			continue
+		}
+		if msg.Method != "" {
+			// Server-initiated request or notification
+			if len(msg.ID) > 0 {
+				t.replyToServer(&msg)
+			}
+			continue
+		}
+		t.mu.Lock()
+		ch, ok := t.pending[string(msg.ID)]
+		delete(t.pending, string(msg.ID))
+		t.mu.Unlock()
+		if ok {
+			ch <- &msg
+		}
+	}
+
+	t.mu.Lock()
+	t.readErr = scanner.Err()
+	if t.readErr == nil {
+		t.readErr = fmt.Errorf("MCP server closed its output")
+	}
+	t.mu.Unlock()
+	close(t.done)
+}
+
+// replyToServer answers pings and rejects other server requests, since the client offers no capabilities
+func (t *stdioTransport) replyToServer(msg *rpcMessage) {
+	reply := map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID}
+	if msg.Method == "ping" {
+		reply["result"] = map[string]interface{}{}
+	} else {
+		reply["error"] = rpcError{Code: -32601, Message: "method not found: " + msg.Method}
+	}
+	t.write(reply)
+}
+
+func (t *stdioTransport) write(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err = t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (t *stdioTransport) call(ctx context.Context, req *rpcRequest) (*rpcMessage, error) {
+	key := fmt.Sprint(*req.ID)
+	ch := make(chan *rpcMessage, 1)
+	t.mu.Lock()
+	t.pending[key] = ch
+	t.mu.Unlock()
+
+	if err := t.write(req); err != nil {
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+		return nil, fmt.Errorf("failed to write to MCP server: %w", err)
+	}
+
+	select {
+	case msg := <-ch:
+		return msg, nil
+	case <-t.done:
+		return nil, t.readErr
+	case <-ctx.Done():
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+		t.write(rpcRequest{JSONRPC: "2.0", Method: "notifications/cancelled", Params: map[string]interface{}{"requestId": *req.ID}})
+		return nil, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) notify(ctx context.Context, method string, params interface{}) error {
+	return t.write(rpcRequest{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (t *stdioTransport) Close() error {
+	t.stdin.Close()
+	exited := make(chan error, 1)
+	go func() { exited <- t.cmd.Wait() }()
+	select {
+	case <-exited:
+	case <-time.After(2 * time.Second):
+		t.cmd.Process.Kill()
+		<-exited
+	}
+	return nil
+}
+
+// httpTransport implements the streamable HTTP transport: each message is POSTed and the
+// response arrives either as a JSON body or on a server-sent event stream
+type httpTransport struct {
+	url     string
+	headers map[string]string
+
+	mu              sync.Mutex
+	sessionID       string
+	sessionClient   *http.Client // Egress client the session was opened with, reused by Close
+	protocolVersion string
+}
+
+func (t *httpTransport) setProtocolVersion(v string) {
+	t.mu.Lock()
+	t.protocolVersion = v
+	t.mu.Unlock()
+}
+
+func (t *httpTransport) post(ctx context.Context, msg interface{}) (*http.Response, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	t.mu.Lock()
+	if t.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	if t.protocolVersion != "" {
+		req.Header.Set("MCP-Protocol-Version", t.protocolVersion)
+	}
+	t.mu.Unlock()
+
+	client := egress.ClientFrom(ctx)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("MCP request failed: %w", err)
+	}
+	if sid := resp.Header.Get("Mcp-Session-Id"); sid != "" {
+		t.mu.Lock()
+		if sid != t.sessionID {
+			t.sessionID, t.sessionClient = sid, client
+		}
+		t.mu.Unlock()
+	}
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("MCP server returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+func (t *httpTransport) call(ctx context.Context, req *rpcRequest) (*rpcMessage, error) {
+	resp, err := t.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	wantID := fmt.Sprint(*req.ID)
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		return readSSEResponse(resp.Body, wantID)
+	}
+
+	var msg rpcMessage
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		return nil, fmt.Errorf("invalid MCP response: %w", err)
+	}
+	return &msg, nil
+}
+
+// readSSEResponse reads events until the response to wantID arrives; notifications are skipped
+func readSSEResponse(r io.Reader, wantID string) (*rpcMessage, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var data []string
+	for {
+		more := scanner.Scan()
+		line := scanner.Text()
+		if !more || line == "" {
+			// Blank line (or end of stream) dispatches the buffered event
+			if len(data) > 0 {
+				var msg rpcMessage
+				if err := json.Unmarshal([]byte(strings.Join(data, "\n")), &msg); err == nil &&
+					msg.Method == "" && string(msg.ID) == wantID {
+					return &msg, nil
+				}
+				data = nil
+			}
+			if !more {
+				break
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "data:") {
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("MCP event stream failed: %w", err)
+	}
+	return nil, fmt.Errorf("MCP event stream ended without a response")
+}
+
+func (t *httpTransport) notify(ctx context.Context, method string, params interface{}) error {
+	resp, err := t.post(ctx, rpcRequest{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Close ends the session on the server when one was assigned, under the egress policy
+// the session was opened with
+func (t *httpTransport) Close() error {
+	t.mu.Lock()
+	sid, client := t.sessionID, t.sessionClient
+	t.mu.Unlock()
+	if sid == "" {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Mcp-Session-Id", sid)
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,226 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"dify-vnext-go/pkg/dsl"
+	"dify-vnext-go/pkg/egress"
+)
+
+// The test binary doubles as a stand-in MCP server: with VNEXT_MCP_TEST_SERVER set it
+// serves MCP over stdin/stdout instead of running the tests
+func TestMain(m *testing.M) {
+	if os.Getenv("VNEXT_MCP_TEST_SERVER") != "" {
+		serveTestMCP()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// serveTestMCP answers initialize, a two-page tools/list and tools/call for echo
+// (text content), add (structured content) and fail (isError). Before answering a call
+// it pings the client, as servers may.
+func serveTestMCP() {
+	in := bufio.NewScanner(os.Stdin)
+	out := json.NewEncoder(os.Stdout)
+	tools := []map[string]interface{}{
+		{"name": "echo", "description": "Echo the message", "inputSchema": map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"message": map[string]interface{}{"type": "string"}},
+			"required":   []string{"message"},
+		}},
+		{"name": "add", "description": "Add two numbers"},
+		{"name": "fail", "description": "Always fails"},
+	}
+	for in.Scan() {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params struct {
+				Cursor    string                 `json:"cursor"`
+				Name      string                 `json:"name"`
+				Arguments map[string]interface{} `json:"arguments"`
+			} `json:"params"`
+		}
+		if err := json.Unmarshal(in.Bytes(), &req); err != nil || req.ID == nil || req.Method == "" {
+			continue // Notifications and the client's replies to our requests
+		}
+		reply := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "initialize":
+			reply["result"] = map[string]interface{}{
+				"protocolVersion": MCPProtocolVersion,
+				"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+				"serverInfo":      map[string]interface{}{"name": "stand-in", "version": "1.0"},
+			}
+		case "tools/list":
+			if req.Params.Cursor == "" {
+				reply["result"] = map[string]interface{}{"tools": tools[:2], "nextCursor": "page2"}
+			} else {
+				reply["result"] = map[string]interface{}{"tools": tools[2:]}
+			}
+		case "tools/call":
+			out.Encode(map[string]interface{}{"jsonrpc": "2.0", "id": "server-ping", "method": "ping"})
+			out.Encode(map[string]interface{}{"jsonrpc": "2.0", "method": "notifications/message", "params": map[string]interface{}{"level": "info", "data": "calling " + req.Params.Name}})
+			args := req.Params.Arguments
+			switch req.Params.Name {
+			case "echo":
+				greeting := os.Getenv("VNEXT_MCP_TEST_GREETING")
+				reply["result"] = map[string]interface{}{"content": []interface{}{
+					map[string]interface{}{"type": "text", "text": greeting + " " + fmt.Sprint(args["message"])},
+					map[string]interface{}{"type": "image", "data": "AAAA", "mimeType": "image/png"},
+					map[string]interface{}{"type": "text", "text": "done"},
+				}}
+			case "add":
+				a, _ := args["a"].(float64)
+				b, _ := args["b"].(float64)
+				reply["result"] = map[string]interface{}{
+					"content":           []interface{}{map[string]interface{}{"type": "text", "text": fmt.Sprint(a + b)}},
+					"structuredContent": map[string]interface{}{"sum": a + b},
+				}
+			case "fail":
+				reply["result"] = map[string]interface{}{
+					"content": []interface{}{map[string]interface{}{"type": "text", "text": "something broke"}},
+					"isError": true,
+				}
+			default:
+				reply["error"] = map[string]interface{}{"code": -32602, "message": "unknown tool " + req.Params.Name}
+			}
+		default:
+			reply["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+		}
+		out.Encode(reply)
+	}
+}
+
+func TestMCPStdio(t *testing.T) {
+	reg := NewRegistry()
+	closeProviders, err := RegisterProviders(context.Background(), reg, []dsl.ToolProviderDefinition{{
+		ID:      "stub",
+		Type:    "mcp",
+		Command: []string{os.Args[0], "-test.run=^$"},
+		Env:     map[string]string{"VNEXT_MCP_TEST_SERVER": "1", "VNEXT_MCP_TEST_GREETING": "hello"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeProviders()
+
+	// tools/list follows the cursor to the second page
+	var names []string
+	for _, info := range reg.List() {
+		names = append(names, info.Provider+"/"+info.Name)
+	}
+	if want := []string{"stub/add", "stub/echo", "stub/fail"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("tools = %v, want %v", names, want)
+	}
+	echo, _ := reg.Lookup("stub", "echo")
+	if required := echo.Parameters()["required"]; !reflect.DeepEqual(required, []interface{}{"message"}) {
+		t.Errorf("echo schema lost its required list: %v", echo.Parameters())
+	}
+	add, _ := reg.Lookup("stub", "add")
+	if add.Parameters()["type"] != "object" {
+		t.Errorf("a tool without inputSchema should get an empty object schema, got %v", add.Parameters())
+	}
+
+	ctx := context.Background()
+	t.Run("text content", func(t *testing.T) {
+		out, err := reg.Call(ctx, "stub", "echo", map[string]interface{}{"message": "world"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out["text"] != "hello world\ndone" {
+			t.Errorf("text = %q, want the text blocks joined", out["text"])
+		}
+		if content, _ := out["content"].([]interface{}); len(content) != 3 {
+			t.Errorf("content = %v, want all 3 blocks", out["content"])
+		}
+		if _, ok := out["structured"]; ok {
+			t.Errorf("unexpected structured output: %v", out["structured"])
+		}
+	})
+
+	t.Run("structured content", func(t *testing.T) {
+		out, err := reg.Call(ctx, "stub", "add", map[string]interface{}{"a": 2, "b": 3.5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out["text"] != "5.5" {
+			t.Errorf("text = %q, want 5.5", out["text"])
+		}
+		if !reflect.DeepEqual(out["structured"], map[string]interface{}{"sum": 5.5}) {
+			t.Errorf("structured = %v, want {sum: 5.5}", out["structured"])
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		if _, err := reg.Call(ctx, "stub", "fail", nil); err == nil || !strings.Contains(err.Error(), "something broke") {
+			t.Errorf("isError result: got %v", err)
+		}
+		if _, err := reg.Call(ctx, "stub", "echo", nil); err == nil || !strings.Contains(err.Error(), "missing argument 'message'") {
+			t.Errorf("missing required argument: got %v", err)
+		}
+		tool := &MCPTool{info: MCPToolInfo{Name: "missing"}, client: echo.(*MCPTool).client}
+		if _, err := tool.Invoke(ctx, nil); err == nil || !strings.Contains(err.Error(), "unknown tool missing") {
+			t.Errorf("JSON-RPC error: got %v", err)
+		}
+	})
+
+	if name := echo.(*MCPTool).client.ServerName; name != "stand-in" {
+		t.Errorf("ServerName = %q, want stand-in", name)
+	}
+}
+
+func TestMCPHTTPCloseUsesRegistrationPolicy(t *testing.T) {
+	deleted := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deleted <- r.Header.Get("Mcp-Session-Id")
+			return
+		}
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Mcp-Session-Id", "session-1")
+		if req.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		result := map[string]interface{}{"tools": []interface{}{map[string]interface{}{"name": "noop"}}}
+		if req.Method == "initialize" {
+			result = map[string]interface{}{"protocolVersion": MCPProtocolVersion, "serverInfo": map[string]interface{}{"name": "http"}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	defer srv.Close()
+
+	// The default policy blocks the loopback test server; only the registration context allows it
+	ctx := egress.WithClient(context.Background(), (&egress.Policy{AllowPrivate: true}).Client(nil))
+	client := NewMCPHTTPClient(srv.URL, nil)
+	if _, err := RegisterMCP(ctx, NewRegistry(), "remote", client); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	select {
+	case sid := <-deleted:
+		if sid != "session-1" {
+			t.Errorf("DELETE for session %q, want session-1", sid)
+		}
+	default:
+		t.Error("session was not ended on the server")
+	}
+}
@@ -0,0 +1,557 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"dify-vnext-go/pkg/egress"
+	"gopkg.in/yaml.v3"
+)
+
+// maxRefDepth bounds $ref expansion so recursive schemas terminate
+const maxRefDepth = 8
+
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// OpenAPIAuth holds credentials applied to every request of an OpenAPI provider
+type OpenAPIAuth struct {
+	Type     string // "bearer", "basic" or "api_key"
+	Token    string
+	Username string
+	Password string
+	Name     string // Header or query parameter name for api_key
+	In       string // "header" (default) or "query" for api_key
+	Value    string
+}
+
+// openAPITimeout bounds a single operation call
+const openAPITimeout = 60 * time.Second
+
+// OpenAPIOptions configures tools generated from an OpenAPI document
+type OpenAPIOptions struct {
+	BaseURL string // Overrides the first server URL of the spec
+	Auth    *OpenAPIAuth
+	Client  *http.Client // Defaults to the egress client of the calling context
+}
+
+// RegisterOpenAPI loads an OpenAPI 3 document (JSON or YAML) and registers every operation
+// as a tool under provider. It returns the number of tools registered.
+func RegisterOpenAPI(reg *Registry, provider, specPath string, opts OpenAPIOptions) (int, error) {
+	data, err := os.ReadFile(specPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read OpenAPI spec: %w", err)
+	}
+	ops, err := ParseOpenAPI(data, opts)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", specPath, err)
+	}
+	for _, op := range ops {
+		if err := reg.Register(provider, op); err != nil {
+			return 0, err
+		}
+	}
+	return len(ops), nil
+}
+
+// ParseOpenAPI builds one tool per operation of an OpenAPI 3 document
+func ParseOpenAPI(data []byte, opts OpenAPIOptions) ([]*OpenAPITool, error) {
+	// JSON is a subset of YAML, so one decoder handles both formats
+	var spec map[string]interface{}
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	version, _ := spec["openapi"].(string)
+	if !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q, expected 3.x", version)
+	}
+
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		if servers, ok := spec["servers"].([]interface{}); ok && len(servers) > 0 {
+			if server, ok := servers[0].(map[string]interface{}); ok {
+				baseURL = serverURL(server)
+			}
+		}
+	}
+	if baseURL == "" {
+		return nil, fmt.Errorf("no server URL in spec, set base_url")
+	}
+
+	paths, _ := spec["paths"].(map[string]interface{})
+	var ops []*OpenAPITool
+	for _, path := range sortedKeys(paths) {
+		item, ok := resolveRef(spec, paths[path], 0).(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, method := range openAPIMethods {
+			opDef, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			op := newOpenAPITool(spec, path, method, item, opDef)
+			op.baseURL = strings.TrimRight(baseURL, "/")
+			op.auth = opts.Auth
+			op.client = opts.Client
+			ops = append(ops, op)
+		}
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("spec defines no operations")
+	}
+	return ops, nil
+}
+
+// serverURL substitutes server variables with their defaults
+func serverURL(server map[string]interface{}) string {
+	u, _ := server["url"].(string)
+	vars, _ := server["variables"].(map[string]interface{})
+	for name, v := range vars {
+		if def, ok := v.(map[string]interface{}); ok {
+			u = strings.ReplaceAll(u, "{"+name+"}", fmt.Sprint(def["default"]))
+		}
+	}
+	return u
+}
+
+// openAPIParam is a parameter sent in the path, query or a header
+type openAPIParam struct {
+	Name     string
+	In       string
+	Required bool
+	Desc     string
+	Schema   map[string]interface{}
+}
+
+// OpenAPITool invokes a single OpenAPI operation
+type OpenAPITool struct {
+	name        string
+	description string
+	method      string
+	path        string
+	params      []openAPIParam
+	// bodyProps lists arguments sent as fields of a JSON object body.
+	// When nil and hasBody is set, the "body" argument is sent as-is.
+	bodyProps   []string
+	hasBody     bool
+	contentType string
+	parameters  map[string]interface{}
+
+	baseURL string
+	auth    *OpenAPIAuth
+	client  *http.Client
+}
+
+var nonIdentChars = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+func newOpenAPITool(spec map[string]interface{}, path, method string, item, opDef map[string]interface{}) *OpenAPITool {
+	name, _ := opDef["operationId"].(string)
+	if name == "" {
+		name = method + "_" + path
+	}
+	name = strings.Trim(nonIdentChars.ReplaceAllString(name, "_"), "_")
+
+	description, _ := opDef["summary"].(string)
+	if d, ok := opDef["description"].(string); ok && d != "" {
+		if description != "" {
+			description += ". "
+		}
+		description += d
+	}
+	if description == "" {
+		description = strings.ToUpper(method) + " " + path
+	}
+
+	t := &OpenAPITool{
+		name:        name,
+		description: description,
+		method:      strings.ToUpper(method),
+		path:        path,
+	}
+
+	properties := map[string]interface{}{}
+	var required []interface{}
+
+	// Operation parameters override path-level ones with the same name and location
+	seen := map[string]int{}
+	for _, list := range []interface{}{item["parameters"], opDef["parameters"]} {
+		raw, _ := list.([]interface{})
+		for _, r := range raw {
+			p, ok := resolveRef(spec, r, 0).(map[string]interface{})
+			if !ok {
+				continue
+			}
+			param := openAPIParam{}
+			param.Name, _ = p["name"].(string)
+			param.In, _ = p["in"].(string)
+			param.Required, _ = p["required"].(bool)
+			param.Desc, _ = p["description"].(string)
+			if param.In == "cookie" || param.Name == "" {
+				continue
+			}
+			if param.In == "path" {
+				param.Required = true
+			}
+			schema, _ := expandRefs(spec, p["schema"], 0).(map[string]interface{})
+			if schema == nil {
+				schema = map[string]interface{}{"type": "string"}
+			}
+			param.Schema = schema
+
+			key := param.In + ":" + param.Name
+			if i, ok := seen[key]; ok {
+				t.params[i] = param
+			} else {
+				seen[key] = len(t.params)
+				t.params = append(t.params, param)
+			}
+		}
+	}
+	for _, param := range t.params {
+		prop := copySchema(param.Schema)
+		if param.Desc != "" {
+			prop["description"] = param.Desc
+		} else if _, ok := prop["description"]; !ok {
+			prop["description"] = fmt.Sprintf("%s parameter", param.In)
+		}
+		properties[param.Name] = prop
+		if param.Required {
+			required = append(required, param.Name)
+		}
+	}
+
+	if rb, ok := resolveRef(spec, opDef["requestBody"], 0).(map[string]interface{}); ok {
+		content, _ := rb["content"].(map[string]interface{})
+		contentType, media := pickMediaType(content)
+		if contentType != "" {
+			t.hasBody = true
+			t.contentType = contentType
+			bodyRequired, _ := rb["required"].(bool)
+			schema, _ := expandRefs(spec, media["schema"], 0).(map[string]interface{})
+
+			// Object bodies are flattened into top-level arguments unless a field collides with a parameter
+			props, _ := schema["properties"].(map[string]interface{})
+			flatten := len(props) > 0
+			for prop := range props {
+				if _, clash := properties[prop]; clash {
+					flatten = false
+				}
+			}
+			if flatten {
+				bodyReq := map[string]bool{}
+				if req, ok := schema["required"].([]interface{}); ok {
+					for _, r := range req {
+						if s, ok := r.(string); ok {
+							bodyReq[s] = true
+						}
+					}
+				}
+				for _, prop := range sortedKeys(props) {
+					properties[prop] = props[prop]
+					t.bodyProps = append(t.bodyProps, prop)
+					if bodyRequired && bodyReq[prop] {
+						required = append(required, prop)
+					}
+				}
+			} else {
+				if schema == nil {
+					schema = map[string]interface{}{}
+				}
+				bodySchema := copySchema(schema)
+				if _, ok := bodySchema["description"]; !ok {
+					bodySchema["description"] = "Request body"
+				}
+				properties["body"] = bodySchema
+				if bodyRequired {
+					required = append(required, "body")
+				}
+			}
+		}
+	}
+
+	t.parameters = map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		t.parameters["required"] = required
+	}
+	return t
+}
+
+// pickMediaType prefers JSON, then form encoding, then whatever the spec lists first
+func pickMediaType(content map[string]interface{}) (string, map[string]interface{}) {
+	for _, ct := range []string{"application/json", "application/x-www-form-urlencoded"} {
+		if media, ok := content[ct].(map[string]interface{}); ok {
+			return ct, media
+		}
+	}
+	for _, ct := range sortedKeys(content) {
+		if strings.HasSuffix(ct, "+json") || strings.HasPrefix(ct, "text/") {
+			media, _ := content[ct].(map[string]interface{})
+			return ct, media
+		}
+	}
+	return "", nil
+}
+
+// resolveRef follows a local "#/..." reference; other values are returned unchanged
+func resolveRef(spec map[string]interface{}, v interface{}, depth int) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	ref, ok := m["$ref"].(string)
+	if !ok || depth > maxRefDepth {
+		return v
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		// Remote references are not fetched
+		return map[string]interface{}{}
+	}
+	var cur interface{} = spec
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return map[string]interface{}{}
+		}
+		cur = obj[part]
+	}
+	return resolveRef(spec, cur, depth+1)
+}
+
+// expandRefs returns a copy of a schema with local references inlined, so it can be
+// handed to an LLM on its own. Recursion stops at maxRefDepth.
+func expandRefs(spec map[string]interface{}, v interface{}, depth int) interface{} {
+	if depth > maxRefDepth {
+		return map[string]interface{}{}
+	}
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if _, ok := val["$ref"]; ok {
+			return expandRefs(spec, resolveRef(spec, val, 0), depth+1)
+		}
+		out := make(map[string]interface{}, len(val))
+		for k, child := range val {
+			out[k] = expandRefs(spec, child, depth)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, child := range val {
+			out[i] = expandRefs(spec, child, depth)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func copySchema(schema map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(schema))
+	for k, v := range schema {
+		out[k] = v
+	}
+	return out
+}
+
+func (t *OpenAPITool) Name() string { return t.name }
+
+func (t *OpenAPITool) Description() string { return t.description }
+
+func (t *OpenAPITool) Parameters() map[string]interface{} { return t.parameters }
+
+func (t *OpenAPITool) Invoke(ctx context.Context, args map[string]interface{}) (map[string]interface{}, error) {
+	path := t.path
+	query := url.Values{}
+	header := http.Header{}
+	for _, p := range t.params {
+		v, ok := args[p.Name]
+		if !ok || v == nil {
+			continue
+		}
+		switch p.In {
+		case "path":
+			path = strings.ReplaceAll(path, "{"+p.Name+"}", url.PathEscape(paramString(v)))
+		case "query":
+			if list, ok := v.([]interface{}); ok {
+				for _, item := range list {
+					query.Add(p.Name, paramString(item))
+				}
+			} else {
+				query.Set(p.Name, paramString(v))
+			}
+		case "header":
+			header.Set(p.Name, paramString(v))
+		}
+	}
+
+	var body io.Reader
+	if t.hasBody {
+		var payload interface{}
+		if t.bodyProps != nil {
+			obj := map[string]interface{}{}
+			for _, prop := range t.bodyProps {
+				if v, ok := args[prop]; ok && v != nil {
+					obj[prop] = v
+				}
+			}
+			if len(obj) > 0 {
+				payload = obj
+			}
+		} else {
+			payload = args["body"]
+		}
+		if payload != nil {
+			encoded, err := encodeBody(t.contentType, payload)
+			if err != nil {
+				return nil, fmt.Errorf("tool %s: %w", t.name, err)
+			}
+			body = bytes.NewReader(encoded)
+			header.Set("Content-Type", t.contentType)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, openAPITimeout)
+	defer cancel()
+
+	u := t.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, t.method, u, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header = header
+	req.Header.Set("Accept", "application/json")
+	if err := applyAuth(req, t.auth); err != nil {
+		return nil, fmt.Errorf("tool %s: %w", t.name, err)
+	}
+
+	fmt.Printf("[%s] %s %s\n", t.name, t.method, req.URL.Path)
+	client := t.client
+	if client == nil {
+		client = egress.ClientFrom(ctx)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("tool %s: %s returned %d: %s", t.name, t.method, resp.StatusCode, truncate(string(respBody), 512))
+	}
+
+	outputs := map[string]interface{}{
+		"status_code": resp.StatusCode,
+		"body":        string(respBody),
+		"text":        string(respBody),
+	}
+	var parsed interface{}
+	if len(respBody) > 0 && json.Unmarshal(respBody, &parsed) == nil {
+		outputs["json"] = parsed
+	}
+	return outputs, nil
+}
+
+func encodeBody(contentType string, payload interface{}) ([]byte, error) {
+	switch {
+	case contentType == "application/x-www-form-urlencoded":
+		obj, ok := payload.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("form body must be an object")
+		}
+		form := url.Values{}
+		for k, v := range obj {
+			form.Set(k, paramString(v))
+		}
+		return []byte(form.Encode()), nil
+	case strings.HasPrefix(contentType, "text/"):
+		return []byte(paramString(payload)), nil
+	default:
+		// A body passed as a JSON string is sent verbatim
+		if s, ok := payload.(string); ok && json.Valid([]byte(s)) {
+			return []byte(s), nil
+		}
+		return json.Marshal(payload)
+	}
+}
+
+func applyAuth(req *http.Request, auth *OpenAPIAuth) error {
+	if auth == nil {
+		return nil
+	}
+	switch auth.Type {
+	case "", "none":
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+auth.Token)
+	case "basic":
+		req.SetBasicAuth(auth.Username, auth.Password)
+	case "api_key":
+		name := auth.Name
+		if name == "" {
+			name = "X-API-Key"
+		}
+		if auth.In == "query" {
+			q := req.URL.Query()
+			q.Set(name, auth.Value)
+			req.URL.RawQuery = q.Encode()
+		} else {
+			req.Header.Set(name, auth.Value)
+		}
+	default:
+		return fmt.Errorf("unsupported auth type: %s", auth.Type)
+	}
+	return nil
+}
+
+func paramString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		// Whole numbers decoded from JSON should not be rendered in exponent form
+		if val == float64(int64(val)) {
+			return fmt.Sprintf("%d", int64(val))
+		}
+		return fmt.Sprint(val)
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(val)
+		return string(b)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
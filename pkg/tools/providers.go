@@ -0,0 +1,160 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"dify-vnext-go/pkg/dsl"
+	"dify-vnext-go/pkg/secrets"
+)
+
+// RegisterProviders registers the tool providers declared in a workflow. Credentials may
+// reference secrets as {{ secrets.NAME }} or ${NAME}, resolved from the vault of ctx.
+// Startup requests, such as MCP tool listing, use the egress client of ctx.
+// Either all providers are registered or, on error, none are.
+// The returned function releases provider resources such as MCP server processes.
+func RegisterProviders(ctx context.Context, reg *Registry, defs []dsl.ToolProviderDefinition) (func(), error) {
+	// Register into a staging registry first, so a failing provider leaves reg unchanged
+	staged := NewRegistry()
+	var clients []*MCPClient
+	closeAll := func() {
+		for _, c := range clients {
+			c.Close()
+		}
+	}
+
+	vault := secrets.FromContext(ctx)
+	for _, def := range defs {
+		if def.ID == "" {
+			closeAll()
+			return nil, fmt.Errorf("tool provider without id")
+		}
+		switch def.Type {
+		case "openapi":
+			if def.Spec == "" {
+				closeAll()
+				return nil, fmt.Errorf("tool provider %s: spec is required", def.ID)
+			}
+			auth, err := authFromDefinition(vault, def.Auth)
+			if err != nil {
+				closeAll()
+				return nil, fmt.Errorf("tool provider %s: %w", def.ID, err)
+			}
+			opts := OpenAPIOptions{
+				BaseURL: def.BaseURL,
+				Auth:    auth,
+			}
+			if _, err := RegisterOpenAPI(staged, def.ID, resolvePath(def.Dir, def.Spec), opts); err != nil {
+				closeAll()
+				return nil, fmt.Errorf("tool provider %s: %w", def.ID, err)
+			}
+		case "mcp":
+			env, err := expandMap(vault, def.Env)
+			if err != nil {
+				closeAll()
+				return nil, fmt.Errorf("tool provider %s: %w", def.ID, err)
+			}
+			headers, err := expandMap(vault, def.Headers)
+			if err != nil {
+				closeAll()
+				return nil, fmt.Errorf("tool provider %s: %w", def.ID, err)
+			}
+			var client *MCPClient
+			switch {
+			case len(def.Command) > 0:
+				c, err := NewMCPStdioClient(def.Command, env)
+				if err != nil {
+					closeAll()
+					return nil, fmt.Errorf("tool provider %s: %w", def.ID, err)
+				}
+				client = c
+			case def.URL != "":
+				client = NewMCPHTTPClient(def.URL, headers)
+			default:
+				closeAll()
+				return nil, fmt.Errorf("tool provider %s: mcp needs command or url", def.ID)
+			}
+			clients = append(clients, client)
+			if _, err := RegisterMCP(ctx, staged, def.ID, client); err != nil {
+				closeAll()
+				return nil, fmt.Errorf("tool provider %s: %w", def.ID, err)
+			}
+		case "search":
+			apiKey, err := vault.Expand(def.APIKey)
+			if err != nil {
+				closeAll()
+				return nil, fmt.Errorf("tool provider %s: %w", def.ID, err)
+			}
+			endpoint := def.URL
+			if def.Backend == "fixture" {
+				endpoint = resolvePath(def.Dir, endpoint)
+			}
+			backend, err := NewSearchBackend(def.Backend, endpoint, apiKey)
+			if err != nil {
+				closeAll()
+				return nil, fmt.Errorf("tool provider %s: %w", def.ID, err)
+			}
+			tool := &SearchTool{ToolName: "search", Backend: backend, DefaultCount: def.Count, Engine: def.Engine}
+			if err := staged.Register(def.ID, tool); err != nil {
+				closeAll()
+				return nil, fmt.Errorf("tool provider %s: %w", def.ID, err)
+			}
+		default:
+			closeAll()
+			return nil, fmt.Errorf("tool provider %s: unknown type '%s'", def.ID, def.Type)
+		}
+	}
+	if err := reg.addAll(staged); err != nil {
+		closeAll()
+		return nil, err
+	}
+	return closeAll, nil
+}
+
+// resolvePath resolves a relative file path in a provider definition against the directory
+// of the workflow file that declares it
+func resolvePath(dir, path string) string {
+	if path != "" && !filepath.IsAbs(path) && dir != "" {
+		return filepath.Join(dir, path)
+	}
+	return path
+}
+
+func authFromDefinition(vault *secrets.Vault, def *dsl.AuthDefinition) (*OpenAPIAuth, error) {
+	if def == nil {
+		return nil, nil
+	}
+	auth := &OpenAPIAuth{Type: def.Type, Name: def.Name, In: def.In}
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&auth.Token, def.Token},
+		{&auth.Username, def.Username},
+		{&auth.Password, def.Password},
+		{&auth.Value, def.Value},
+	} {
+		v, err := vault.Expand(f.src)
+		if err != nil {
+			return nil, err
+		}
+		*f.dst = v
+	}
+	return auth, nil
+}
+
+func expandMap(vault *secrets.Vault, m map[string]string) (map[string]string, error) {
+	if m == nil {
+		return nil, nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		expanded, err := vault.Expand(v)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = expanded
+	}
+	return out, nil
+}
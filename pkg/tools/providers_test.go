@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dify-vnext-go/pkg/dsl"
+)
+
+func TestRegisterProvidersResolvesPathsAgainstWorkflowDir(t *testing.T) {
+	dir := t.TempDir()
+	spec := `{"openapi": "3.0.0", "info": {"title": "ping", "version": "1"},
+		"servers": [{"url": "https://example.com"}],
+		"paths": {"/ping": {"get": {"operationId": "ping", "responses": {"200": {"description": "ok"}}}}}}`
+	if err := os.MkdirAll(filepath.Join(dir, "specs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "specs", "ping.json"), []byte(spec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fixture := `{"*": [{"title": "Hit", "url": "https://example.com/hit"}]}`
+	if err := os.WriteFile(filepath.Join(dir, "search.json"), []byte(fixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	wf := &dsl.WorkflowDefinition{ToolProviders: []dsl.ToolProviderDefinition{
+		{ID: "api", Type: "openapi", Spec: "specs/ping.json"},
+		{ID: "web", Type: "search", Backend: "fixture", URL: "search.json"},
+	}}
+	wf.SetDir(dir)
+
+	reg := NewRegistry()
+	closeProviders, err := RegisterProviders(context.Background(), reg, wf.ToolProviders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeProviders()
+	if _, err := reg.Lookup("api", "ping"); err != nil {
+		t.Errorf("openapi tool from a spec relative to the workflow: %v", err)
+	}
+	out, err := reg.Call(context.Background(), "web", "search", map[string]interface{}{"query": "anything"})
+	if err != nil {
+		t.Fatalf("fixture relative to the workflow: %v", err)
+	}
+	if results, _ := out["results"].([]interface{}); len(results) != 1 {
+		t.Errorf("fixture search returned %v", out)
+	}
+}
@@ -0,0 +1,445 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"dify-vnext-go/pkg/egress"
+	"dify-vnext-go/pkg/secrets"
+)
+
+const (
+	// DefaultSearchCount is the number of results returned when count is not set
+	DefaultSearchCount = 3
+	// MaxSearchCount caps the count argument
+	MaxSearchCount = 20
+)
+
+// SearchResult is a single web search hit
+type SearchResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet"`
+}
+
+// SearchOptions are per-call settings passed to a backend
+type SearchOptions struct {
+	Count  int
+	Engine string // SerpApi engine, e.g. "google", "bing", "duckduckgo"
+}
+
+// SearchBackend runs a query against a search service
+type SearchBackend interface {
+	Name() string
+	Search(ctx context.Context, query string, opts SearchOptions) ([]SearchResult, error)
+}
+
+// NewSearchBackend creates a backend by name. endpoint and apiKey are optional where the backend has defaults;
+// for "fixture" endpoint is the path of the fixture file.
+func NewSearchBackend(name, endpoint, apiKey string) (SearchBackend, error) {
+	switch name {
+	case "serpapi":
+		return &SerpApiBackend{APIKey: apiKey, Endpoint: endpoint}, nil
+	case "searxng":
+		if endpoint == "" {
+			return nil, fmt.Errorf("searxng backend requires an endpoint URL")
+		}
+		return &SearxNGBackend{Endpoint: endpoint}, nil
+	case "bing":
+		return &BingBackend{APIKey: apiKey, Endpoint: endpoint}, nil
+	case "tavily":
+		return &TavilyBackend{APIKey: apiKey, Endpoint: endpoint}, nil
+	case "fixture":
+		if endpoint == "" {
+			return nil, fmt.Errorf("fixture backend requires a file path")
+		}
+		return &FixtureBackend{Path: endpoint}, nil
+	default:
+		return nil, fmt.Errorf("unknown search backend: %s", name)
+	}
+}
+
+// SearchBackendFromEnv picks a backend from VNEXT_SEARCH_BACKEND, or from whichever API key is set.
+// API keys are resolved from the secret vault of ctx. It returns nil when nothing is configured.
+func SearchBackendFromEnv(ctx context.Context) (SearchBackend, error) {
+	vault := secrets.FromContext(ctx)
+	serpKey := vault.Get("SERPAPI_API_KEY")
+	tavilyKey := vault.Get("TAVILY_API_KEY")
+	bingKey := vault.Get("BING_SEARCH_API_KEY")
+
+	name := os.Getenv("VNEXT_SEARCH_BACKEND")
+	if name == "" {
+		switch {
+		case serpKey != "":
+			name = "serpapi"
+		case tavilyKey != "":
+			name = "tavily"
+		case bingKey != "":
+			name = "bing"
+		case os.Getenv("SEARXNG_URL") != "":
+			name = "searxng"
+		case os.Getenv("VNEXT_SEARCH_FIXTURE") != "":
+			name = "fixture"
+		default:
+			return nil, nil
+		}
+	}
+	switch name {
+	case "serpapi":
+		return NewSearchBackend(name, "", serpKey)
+	case "tavily":
+		return NewSearchBackend(name, os.Getenv("TAVILY_ENDPOINT"), tavilyKey)
+	case "bing":
+		return NewSearchBackend(name, os.Getenv("BING_SEARCH_ENDPOINT"), bingKey)
+	case "searxng":
+		return NewSearchBackend(name, os.Getenv("SEARXNG_URL"), "")
+	case "fixture":
+		return NewSearchBackend(name, os.Getenv("VNEXT_SEARCH_FIXTURE"), "")
+	default:
+		return NewSearchBackend(name, "", "")
+	}
+}
+
+// SearchTool searches the web through a pluggable backend
+type SearchTool struct {
+	ToolName string
+	// Backend serves the queries. When nil it is resolved from the environment and secrets on each call,
+	// and a mock result is returned if nothing is configured.
+	Backend      SearchBackend
+	DefaultCount int
+	Engine       string
+}
+
+func (t *SearchTool) Name() string { return t.ToolName }
+
+func (t *SearchTool) Description() string {
+	return "Searches the web and returns the top results as text and as a list of title/url/snippet objects."
+}
+
+func (t *SearchTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"query": map[string]interface{}{
+				"type":        "string",
+				"description": "Search query",
+			},
+			"count": map[string]interface{}{
+				"type":        "integer",
+				"description": fmt.Sprintf("Number of results (1-%d)", MaxSearchCount),
+			},
+			"engine": map[string]interface{}{
+				"type":        "string",
+				"description": "Search engine for backends that offer several, e.g. \"google\" or \"bing\"",
+			},
+		},
+		"required": []string{"query"},
+	}
+}
+
+func (t *SearchTool) Invoke(ctx context.Context, args map[string]interface{}) (map[string]interface{}, error) {
+	query, _ := args["query"].(string)
+
+	opts := SearchOptions{Count: t.DefaultCount, Engine: t.Engine}
+	if opts.Count <= 0 {
+		opts.Count = DefaultSearchCount
+	}
+	if n, ok := intArg(args, "count"); ok && n > 0 {
+		opts.Count = n
+	}
+	if opts.Count > MaxSearchCount {
+		opts.Count = MaxSearchCount
+	}
+	if engine, ok := args["engine"].(string); ok && engine != "" {
+		opts.Engine = engine
+	}
+
+	backend := t.Backend
+	if backend == nil {
+		var err error
+		if backend, err = SearchBackendFromEnv(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if backend == nil {
+		fmt.Printf("[%s] WARNING: no search backend configured. Using Mock response.\n", t.Name())
+		return map[string]interface{}{
+			"text":    fmt.Sprintf("Mock Search Results for '%s': [Real Search requires API Key]", query),
+			"results": []interface{}{},
+		}, nil
+	}
+
+	fmt.Printf("[%s] Searching via %s: %s\n", t.Name(), backend.Name(), query)
+	results, err := backend.Search(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) > opts.Count {
+		results = results[:opts.Count]
+	}
+
+	var text strings.Builder
+	list := make([]interface{}, len(results))
+	for i, res := range results {
+		fmt.Fprintf(&text, "%d. %s: %s\n", i+1, res.Title, res.Snippet)
+		list[i] = map[string]interface{}{
+			"title":   res.Title,
+			"url":     res.URL,
+			"snippet": res.Snippet,
+		}
+	}
+	if len(results) == 0 {
+		text.WriteString("No results found.")
+	}
+
+	return map[string]interface{}{
+		"text":    text.String(),
+		"results": list,
+	}, nil
+}
+
+// getJSON performs a request and decodes a JSON response into out
+func getJSON(req *http.Request, out interface{}) error {
+	resp, err := egress.ClientFrom(req.Context()).Do(req)
+	if err != nil {
+		return fmt.Errorf("search request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read body: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("search request returned %d: %s", resp.StatusCode, truncate(string(body), 512))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+type SerpApiResponse struct {
+	OrganicResults []struct {
+		Title   string `json:"title"`
+		Link    string `json:"link"`
+		Snippet string `json:"snippet"`
+	} `json:"organic_results"`
+	Error string `json:"error"`
+}
+
+// SerpApiBackend queries SerpApi, which fronts Google, Bing, DuckDuckGo and others
+type SerpApiBackend struct {
+	APIKey   string
+	Endpoint string // Defaults to https://serpapi.com/search
+}
+
+func (b *SerpApiBackend) Name() string { return "serpapi" }
+
+func (b *SerpApiBackend) Search(ctx context.Context, query string, opts SearchOptions) ([]SearchResult, error) {
+	if b.APIKey == "" {
+		return nil, fmt.Errorf("serpapi backend requires an API key")
+	}
+	endpoint := b.Endpoint
+	if endpoint == "" {
+		endpoint = "https://serpapi.com/search"
+	}
+	engine := opts.Engine
+	if engine == "" {
+		engine = "google"
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("q", query)
+	q.Set("api_key", b.APIKey)
+	q.Set("engine", engine)
+	q.Set("num", fmt.Sprint(opts.Count))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	var serpResp SerpApiResponse
+	if err := getJSON(req, &serpResp); err != nil {
+		return nil, err
+	}
+	if serpResp.Error != "" {
+		return nil, fmt.Errorf("SerpApi error: %s", serpResp.Error)
+	}
+
+	var results []SearchResult
+	for _, r := range serpResp.OrganicResults {
+		results = append(results, SearchResult{Title: r.Title, URL: r.Link, Snippet: r.Snippet})
+	}
+	return results, nil
+}
+
+// SearxNGBackend queries a SearxNG instance through its JSON API
+type SearxNGBackend struct {
+	Endpoint string // Base URL of the instance
+}
+
+func (b *SearxNGBackend) Name() string { return "searxng" }
+
+func (b *SearxNGBackend) Search(ctx context.Context, query string, opts SearchOptions) ([]SearchResult, error) {
+	u, err := url.Parse(strings.TrimRight(b.Endpoint, "/") + "/search")
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("q", query)
+	q.Set("format", "json")
+	if opts.Engine != "" {
+		q.Set("engines", opts.Engine)
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	var resp struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := getJSON(req, &resp); err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	for _, r := range resp.Results {
+		results = append(results, SearchResult{Title: r.Title, URL: r.URL, Snippet: r.Content})
+	}
+	return results, nil
+}
+
+// BingBackend queries the Bing Web Search API
+type BingBackend struct {
+	APIKey   string
+	Endpoint string // Defaults to https://api.bing.microsoft.com/v7.0/search
+}
+
+func (b *BingBackend) Name() string { return "bing" }
+
+func (b *BingBackend) Search(ctx context.Context, query string, opts SearchOptions) ([]SearchResult, error) {
+	if b.APIKey == "" {
+		return nil, fmt.Errorf("bing backend requires an API key")
+	}
+	endpoint := b.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.bing.microsoft.com/v7.0/search"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("q", query)
+	q.Set("count", fmt.Sprint(opts.Count))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Ocp-Apim-Subscription-Key", b.APIKey)
+	var resp struct {
+		WebPages struct {
+			Value []struct {
+				Name    string `json:"name"`
+				URL     string `json:"url"`
+				Snippet string `json:"snippet"`
+			} `json:"value"`
+		} `json:"webPages"`
+	}
+	if err := getJSON(req, &resp); err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	for _, r := range resp.WebPages.Value {
+		results = append(results, SearchResult{Title: r.Name, URL: r.URL, Snippet: r.Snippet})
+	}
+	return results, nil
+}
+
+// TavilyBackend queries Tavily or any service implementing its /search API
+type TavilyBackend struct {
+	APIKey   string
+	Endpoint string // Defaults to https://api.tavily.com/search
+}
+
+func (b *TavilyBackend) Name() string { return "tavily" }
+
+func (b *TavilyBackend) Search(ctx context.Context, query string, opts SearchOptions) ([]SearchResult, error) {
+	endpoint := b.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.tavily.com/search"
+	}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"query":       query,
+		"max_results": opts.Count,
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+b.APIKey)
+	}
+	var resp struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := getJSON(req, &resp); err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	for _, r := range resp.Results {
+		results = append(results, SearchResult{Title: r.Title, URL: r.URL, Snippet: r.Content})
+	}
+	return results, nil
+}
+
+// FixtureBackend serves canned results from a JSON file, for offline runs and demos.
+// The file maps queries to result lists; the "*" entry answers any other query.
+type FixtureBackend struct {
+	Path string
+}
+
+func (b *FixtureBackend) Name() string { return "fixture" }
+
+func (b *FixtureBackend) Search(ctx context.Context, query string, opts SearchOptions) ([]SearchResult, error) {
+	data, err := os.ReadFile(b.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read search fixture: %w", err)
+	}
+	var fixture map[string][]SearchResult
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("invalid search fixture %s: %w", b.Path, err)
+	}
+	if results, ok := fixture[query]; ok {
+		return results, nil
+	}
+	return fixture["*"], nil
+}
@@ -0,0 +1,235 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Tool is a capability that a ToolNode, or an LLM through function calling, can invoke
+type Tool interface {
+	Name() string
+	Description() string
+	// Parameters returns a JSON Schema object describing the arguments
+	Parameters() map[string]interface{}
+	Invoke(ctx context.Context, args map[string]interface{}) (map[string]interface{}, error)
+}
+
+// Info describes a registered tool
+type Info struct {
+	Provider    string                 `json:"provider"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// Registry holds tools grouped by provider
+type Registry struct {
+	mu        sync.RWMutex
+	parent    *Registry                  // Tools visible through this registry too; nil for a root registry
+	providers map[string]map[string]Tool // provider -> tool name -> tool
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		providers: make(map[string]map[string]Tool),
+	}
+}
+
+// Child creates an empty registry layered over r. It sees r's tools, while tools registered
+// in it, such as a workflow's tool_providers, stay out of r.
+func (r *Registry) Child() *Registry {
+	child := NewRegistry()
+	child.parent = r
+	return child
+}
+
+// Default holds the built-in tools, registered on init. Engines look tools up in a child
+// of Default holding the workflow's tool_providers (see Registry.Child).
+var Default = NewRegistry()
+
+// Register adds a tool to the Default registry
+func Register(provider string, t Tool) error {
+	return Default.Register(provider, t)
+}
+
+// Register adds a tool under a provider; names must be unique per provider
+func (r *Registry) Register(provider string, t Tool) error {
+	if provider == "" || t.Name() == "" {
+		return fmt.Errorf("tool provider and name must not be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tools, ok := r.providers[provider]
+	if !ok {
+		tools = make(map[string]Tool)
+		r.providers[provider] = tools
+	}
+	if _, exists := tools[t.Name()]; exists {
+		return fmt.Errorf("tool already registered: %s/%s", provider, t.Name())
+	}
+	tools[t.Name()] = t
+	return nil
+}
+
+// addAll registers every tool of from (not of its parent), or none if any name is taken
+func (r *Registry) addAll(from *Registry) error {
+	from.mu.RLock()
+	defer from.mu.RUnlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for provider, tools := range from.providers {
+		for name := range tools {
+			if _, exists := r.providers[provider][name]; exists {
+				return fmt.Errorf("tool already registered: %s/%s", provider, name)
+			}
+		}
+	}
+	for provider, tools := range from.providers {
+		if r.providers[provider] == nil {
+			r.providers[provider] = make(map[string]Tool, len(tools))
+		}
+		for name, t := range tools {
+			r.providers[provider][name] = t
+		}
+	}
+	return nil
+}
+
+// visible returns the tools seen through r; r's own tools shadow its parent's
+func (r *Registry) visible() map[string]map[string]Tool {
+	providers := make(map[string]map[string]Tool)
+	if r.parent != nil {
+		providers = r.parent.visible()
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for provider, tools := range r.providers {
+		if providers[provider] == nil {
+			providers[provider] = make(map[string]Tool, len(tools))
+		}
+		for name, t := range tools {
+			providers[provider][name] = t
+		}
+	}
+	return providers
+}
+
+// Lookup finds a tool by provider and name. With an empty provider the name must be unique across providers.
+func (r *Registry) Lookup(provider, name string) (Tool, error) {
+	providers := r.visible()
+
+	if provider != "" {
+		t, ok := providers[provider][name]
+		if !ok {
+			return nil, fmt.Errorf("unknown tool: %s/%s", provider, name)
+		}
+		return t, nil
+	}
+
+	var found Tool
+	var owners []string
+	for p, tools := range providers {
+		if t, ok := tools[name]; ok {
+			found = t
+			owners = append(owners, p)
+		}
+	}
+	switch len(owners) {
+	case 0:
+		return nil, fmt.Errorf("unknown tool: %s", name)
+	case 1:
+		return found, nil
+	default:
+		sort.Strings(owners)
+		return nil, fmt.Errorf("tool %s is ambiguous, set provider_id to one of: %s", name, strings.Join(owners, ", "))
+	}
+}
+
+// Call looks up a tool, checks its required arguments and invokes it
+func (r *Registry) Call(ctx context.Context, provider, name string, args map[string]interface{}) (map[string]interface{}, error) {
+	t, err := r.Lookup(provider, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRequired(t, args); err != nil {
+		return nil, err
+	}
+	return t.Invoke(ctx, args)
+}
+
+// List describes all registered tools, sorted by provider and name
+func (r *Registry) List() []Info {
+	var infos []Info
+	for provider, tools := range r.visible() {
+		for _, t := range tools {
+			infos = append(infos, Info{
+				Provider:    provider,
+				Name:        t.Name(),
+				Description: t.Description(),
+				Parameters:  t.Parameters(),
+			})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Provider != infos[j].Provider {
+			return infos[i].Provider < infos[j].Provider
+		}
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// FunctionSchemas returns the registered tools in the OpenAI function-calling "tools" format.
+// Function names are FunctionName(provider, name); use ParseFunctionName to map calls back.
+func (r *Registry) FunctionSchemas() []map[string]interface{} {
+	var schemas []map[string]interface{}
+	for _, info := range r.List() {
+		schemas = append(schemas, map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        FunctionName(info.Provider, info.Name),
+				"description": info.Description,
+				"parameters":  info.Parameters,
+			},
+		})
+	}
+	return schemas
+}
+
+// FunctionName joins provider and tool name into a function-calling compatible name
+func FunctionName(provider, name string) string {
+	return provider + "__" + name
+}
+
+// ParseFunctionName splits a name produced by FunctionName
+func ParseFunctionName(fn string) (provider, name string, ok bool) {
+	return strings.Cut(fn, "__")
+}
+
+func checkRequired(t Tool, args map[string]interface{}) error {
+	var required []string
+	switch req := t.Parameters()["required"].(type) {
+	case []string:
+		required = req
+	case []interface{}:
+		// Schemas decoded from JSON or YAML
+		for _, r := range req {
+			if name, ok := r.(string); ok {
+				required = append(required, name)
+			}
+		}
+	}
+	for _, name := range required {
+		if v, ok := args[name]; !ok || v == nil || v == "" {
+			return fmt.Errorf("tool %s: missing argument '%s'", t.Name(), name)
+		}
+	}
+	return nil
+}